@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 
@@ -33,6 +34,21 @@ type AI interface {
 	Stop()
 }
 
+// 可以在叫地主前获知自己手牌的 AI, 调用者在发牌后调用 SetHand
+type HandSetter interface {
+	// 设置自己的手牌(不含底牌)
+	SetHand(PokerSet)
+}
+
+// 可以输出搜索分析结果的 AI
+type Analyzer interface {
+	AI
+	// 建议出牌并返回搜索结果,结果中的候选动作和主要变例都是正则化的牌型
+	Analyze(tag string) (Kind, SearchResult)
+	// 同 Analyze, ctx 结束时立即停止搜索并按已有的结果建议出牌
+	AnalyzeContext(ctx context.Context, tag string) (Kind, SearchResult)
+}
+
 // 实现一个类似蒙特卡罗树搜索(MCTS)算法的 AI
 type mctsAI struct {
 	// 地主位置
@@ -41,27 +57,75 @@ type mctsAI struct {
 	lastPokers PokerSet
 	// 自己的位置
 	self Position
+	// 发牌后自己的手牌(不含底牌),未知时为空
+	hand PokerSet
 	// 叫地主分数
 	scores [NumPlayer]int
 	// 加倍倍数
 	multiples [NumPlayer]int
 	// 各玩家剩余牌
 	pokers [NumPlayer]PokerSet
+	// 开始出牌后的出牌记录,使用实际出的牌
+	history []Action
 	// 当前状态
 	root *Node
+	// 其他玩家的牌是否为采样得到的(见 trusteeAI)
+	sampled bool
+	// 配置
+	cfg MCTSConfig
+}
+
+// MCTS AI 配置
+type MCTSConfig struct {
+	// 随机数,为空时使用全局随机数. 指定后相同的输入得到相同的结果,但不能被多个 AI 共享
+	Rand *rand.Rand
+	// 每次搜索的迭代次数, 0 表示根据剩余牌数自动决定
+	Iterations int
+	// 局面评估,为空时使用基于规则的策略并只根据推演结果估值
+	Evaluator Evaluator
+	// 设置了 Evaluator 时推演结果在估值中的权重,其余为 Evaluator 估值的权重. 0 表示不推演
+	Alpha float64
+	// 难度,默认为 Expert. 搜索次数(包括指定的 Iterations)按难度参数的比例缩放
+	Difficulty Difficulty
+	// 出牌风格,默认为 StyleDefault
+	Style Style
+	// 思考时间策略, Max 大于 0 时按策略决定搜索和响应的时间,搜索次数仍然不超过 Iterations 决定的次数
+	Think ThinkPolicy
+	// 规则,零值表示 DefaultOptions
+	Options Options
+}
+
+// 配置的规则
+func (cfg MCTSConfig) options() Options {
+	if cfg.Options == (Options{}) {
+		return DefaultOptions
+	}
+	return cfg.Options
+}
+
+// 创建一个基于蒙特卡罗树搜索的 AI
+func NewMCTSAI() Analyzer {
+	return new(mctsAI)
+}
+
+// 使用指定配置创建一个基于蒙特卡罗树搜索的 AI
+func NewMCTSAIWithConfig(cfg MCTSConfig) Analyzer {
+	return &mctsAI{cfg: cfg}
 }
 
 func (ai *mctsAI) SetLandlord(pos Position)      { ai.landlord = pos }
 func (ai *mctsAI) SetLastPokers(pokers PokerSet) { ai.lastPokers = pokers }
 func (ai *mctsAI) SetSelf(pos Position)          { ai.self = pos }
+func (ai *mctsAI) SetHand(hand PokerSet)         { ai.hand = hand }
 
 func (ai *mctsAI) Rob(pos Position, score int)    { ai.scores[pos.Value()] = score }
 func (ai *mctsAI) Double(pos Position, multi int) { ai.multiples[pos.Value()] = multi }
 
 func (ai *mctsAI) Start(pokers [NumPlayer]PokerSet) {
 	copy(ai.pokers[:], pokers[:])
+	ai.history = nil
 	ai.root = new(Node)
-	ai.root.state = NewState(pokers, ai.landlord)
+	ai.root.state = NewStateWithOptions(pokers, ai.landlord, ai.cfg.options())
 	ai.root.action.player = ai.landlord.Prev()
 }
 
@@ -89,36 +153,138 @@ func (ai *mctsAI) Play(tag string, pos Position, kind Kind) {
 		kind:   kind,
 	})
 	ai.pokers[pos].Remove(kind.Pokers())
+	ai.history = append(ai.history, NewAction(pos, kind))
 }
 
-// 建议叫地主分数, 0 表示不叫
+// 建议叫地主分数, 0 表示不叫. 知道手牌时按牌力和风格决定,否则随机选择
 func (ai *mctsAI) RecommendRob() int {
-	return rand.Intn(4)
+	if ai.hand.Empty() {
+		return intn(ai.cfg.Rand, 4)
+	}
+	maxBid := 0
+	for _, score := range ai.scores {
+		if score > maxBid {
+			maxBid = score
+		}
+	}
+	return ai.cfg.Style.rob(ai.hand, maxBid)
 }
 
-// 建议加倍倍数, 0 表示不加倍, 2 表示加倍
+// 建议加倍倍数, 0 表示不加倍, 2 表示加倍. 知道手牌时按牌力和风格决定,否则随机选择
 func (ai *mctsAI) RecommendDouble() int {
-	return rand.Intn(2) * 2
+	if ai.hand.Empty() {
+		return intn(ai.cfg.Rand, 2) * 2
+	}
+	hand := ai.hand
+	if ai.self == ai.landlord {
+		hand.Add(ai.lastPokers)
+	}
+	if ai.cfg.Style.double(hand) {
+		return 2
+	}
+	return 0
 }
 
-// 建议出牌
+// 建议出牌. 只有一个合法出牌,能一手出完或者剩余的牌没有人管得上时不经搜索直接出牌
 func (ai *mctsAI) RecommendPlay(tag string) Kind {
+	kind, _ := ai.Analyze(tag)
+	return kind
+}
+
+// 建议出牌并返回搜索结果. 难度低于 Expert 时建议的出牌不一定是 result.Best
+func (ai *mctsAI) Analyze(tag string) (Kind, SearchResult) {
+	return ai.AnalyzeContext(context.Background(), tag)
+}
+
+// 同 Analyze, ctx 结束时立即停止搜索. 停止时还没有搜索结果则建议第一个提示
+func (ai *mctsAI) AnalyzeContext(ctx context.Context, tag string) (Kind, SearchResult) {
 	log.Debug().Any("current", ai.root).Print("mctsAI RecommendPlay")
-	const c = 30
-	var maxcnt int
-	numPokers := ai.root.state.NumPokers()
-	maxcnt = numPokers*numPokers*2 + 100
-	node := ai.root.Search(getLegalActions, rollout, 1, c, maxcnt)
+	result := ai.search(ctx, ai.root, ai.iterations())
+	if result.Shortcut {
+		return ai.realize(result.Best.action.player, result.Best.action.kind), result
+	}
+	node := result.Best
+	if node == nil && ctx.Err() != nil {
+		return ai.hint(), result
+	}
 	if node == nil {
 		panic("selected node is nil")
 	}
 	log.Debug().Any("node", node).Print("mctsAI RecommendPlay")
+	lead, _ := ai.root.lead()
+	action := pickAction(ai.cfg.Rand, ai.cfg.Difficulty, result, lead)
+	return ai.realize(action.player, action.kind), result
+}
 
+// 当前局面按配置和难度决定的搜索次数
+func (ai *mctsAI) iterations() int {
+	maxcnt := ai.cfg.Iterations
+	if maxcnt <= 0 {
+		numPokers := ai.root.state.NumPokers()
+		maxcnt = numPokers*numPokers*2 + 100
+	}
+	if budget := ai.cfg.Difficulty.Params().Budget; budget < 1 {
+		maxcnt = int(float64(maxcnt)*budget) + 1
+	}
+	return maxcnt
+}
+
+// 当前局面的第一个提示
+func (ai *mctsAI) hint() Kind {
+	lead, leader := ai.root.lead()
+	return FirstHint(ai.pokers[ai.self], lead, leader, ai.landlord, ai.self, ai.cfg.options())
+}
+
+// 从 root 开始搜索最多 maxcnt 次,不需要搜索时直接返回结果. ctx 结束时立即停止并返回当前的搜索结果
+func (ai *mctsAI) search(ctx context.Context, root *Node, maxcnt int) SearchResult {
+	const c = 30
 	var (
-		pos    = node.action.player
-		kind   = node.action.kind
-		pokers = ai.pokers[pos]
+		r        = ai.cfg.Rand
+		alpha    = 1.0
+		policy   = func(node *Node) ([]Action, float64, int) { return legalActions(node, r) }
+		simulate = func(root, leaf *Node) float64 { return rolloutWithRand(root, leaf, r) }
 	)
+	if ai.cfg.Evaluator != nil {
+		alpha = ai.cfg.Alpha
+		policy = NewPolicy(ai.cfg.Evaluator, ai.lastPokers)
+		if alpha == 0 {
+			simulate = nil
+		}
+	}
+	policy = ai.cfg.Style.policy(ai.self, policy)
+	simulate = ai.cfg.Style.rollout(ai.self, simulate)
+	plays := maxShortcutPlays
+	if ai.cfg.Difficulty.Params().NoTracking {
+		root = forgetfulRoot(r, root, ai.self)
+	}
+	if ai.sampled || ai.cfg.Difficulty.Params().NoTracking {
+		// 对手的牌是采样的,不能据此判断剩余的牌有没有人管得上
+		plays = 1
+	}
+	if action, ok := root.shortcutWithin(plays); ok {
+		return root.shortcutResult(action)
+	}
+	if ai.cfg.Think.Max > 0 {
+		return ai.cfg.Think.search(ctx, root, r, policy, simulate, alpha, c, maxcnt)
+	}
+	if ctx.Done() == nil {
+		return root.SearchWithRand(r, policy, simulate, alpha, c, maxcnt)
+	}
+	s := root.StartSearch(r, policy, simulate, alpha, c, maxcnt)
+	select {
+	case <-s.Done():
+	case <-ctx.Done():
+	}
+	return s.Stop()
+}
+
+// 将正则化的牌型还原成玩家手中实际的牌
+func (ai *mctsAI) realize(pos Position, kind Kind) Kind {
+	return realizeKind(ai.pokers[pos], kind)
+}
+
+// 将正则化的牌型还原成 pokers 中实际的牌
+func realizeKind(pokers PokerSet, kind Kind) Kind {
 	body := pokers.Find(kind.body)
 	pokers.Remove(body)
 	kicker := pokers.Find(kind.kicker)
@@ -1,8 +1,11 @@
 package ai
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,6 +27,11 @@ func init() {
 // 1. mcts 搜索过程增加路径长度惩罚,这个惩罚只能占非常小的分量
 
 func initPokers() ([NumPlayer]PokerSet, Position) {
+	return initPokersWithRand(nil)
+}
+
+// 使用随机数 r 发牌, r 为空时使用全局随机数
+func initPokersWithRand(r *rand.Rand) ([NumPlayer]PokerSet, Position) {
 	var pokers []poker.Poker
 	for suit := poker.Suit(0); suit < 4; suit++ {
 		for value := poker.P3; value <= poker.P8; value++ {
@@ -33,10 +41,10 @@ func initPokers() ([NumPlayer]PokerSet, Position) {
 	pokers = append(pokers, poker.Joker1)
 	pokers = append(pokers, poker.Joker2)
 
-	rand.Shuffle(len(pokers), func(i, j int) {
+	shuffle(r, len(pokers), func(i, j int) {
 		pokers[i], pokers[j] = pokers[j], pokers[i]
 	})
-	landlord := Position(rand.Intn(3))
+	landlord := Position(intn(r, 3))
 	var ret [NumPlayer]PokerSet
 	for i := 0; i < NumPlayer; i++ {
 		for j := 0; j < 8; j++ {
@@ -50,6 +58,17 @@ func initPokers() ([NumPlayer]PokerSet, Position) {
 	return ret, landlord
 }
 
+// 用固定的种子发一副需要搜索才能决定出牌的牌,搜索结果不会因为直接出牌而没有子树
+func initSearchPokers() ([NumPlayer]PokerSet, Position) {
+	for seed := int64(1); ; seed++ {
+		pokers, landlord := initPokersWithRand(rand.New(rand.NewSource(seed)))
+		root := NewNode(nil, Action{player: landlord.Prev()}, NewState(pokers, landlord))
+		if _, ok := root.shortcut(); !ok {
+			return pokers, landlord
+		}
+	}
+}
+
 func playout(t *testing.T, i int) {
 	pokers, landlord := initPokers()
 	var players [NumPlayer]*mctsAI
@@ -143,3 +162,103 @@ func TestPlayout(t *testing.T) {
 		playout(t, i)
 	}
 }
+
+func TestAnalyze(t *testing.T) {
+	pokers, landlord := initSearchPokers()
+	player := NewMCTSAIWithConfig(MCTSConfig{Rand: rand.New(rand.NewSource(1))})
+	player.SetSelf(landlord)
+	player.SetLandlord(landlord)
+	player.Start(pokers)
+
+	kind, result := player.Analyze(landlord.Role(landlord))
+	if result.Best == nil || len(result.Candidates) == 0 {
+		t.Fatalf("empty search result")
+	}
+	if !pokers[landlord].Contains(kind.Pokers()) {
+		t.Fatalf("recommended %v not in hand %v", kind, pokers[landlord])
+	}
+	if !result.Best.action.kind.Equal(kind) {
+		t.Fatalf("best %v mismatch recommended %v", result.Best.action.kind, kind)
+	}
+
+	var visits float64
+	for i, c := range result.Candidates {
+		if i > 0 && c.Visits > result.Candidates[i-1].Visits {
+			t.Fatalf("candidates not ordered by visits")
+		}
+		visits += c.Visits
+	}
+	if int64(visits) != result.Stats.NumIterations {
+		t.Fatalf("visits %v mismatch iterations %d", visits, result.Stats.NumIterations)
+	}
+	if len(result.PV) == 0 || !result.PV[0].Equal(result.Best.action) {
+		t.Fatalf("bad principal variation: %v", result.PV)
+	}
+	for i := 1; i < len(result.PV); i++ {
+		if result.PV[i].player != result.PV[i-1].player.Next() {
+			t.Fatalf("bad principal variation order: %v", result.PV)
+		}
+	}
+	if result.Stats.NumTreeNodes <= int64(len(result.Candidates)) {
+		t.Fatalf("bad tree size: %d", result.Stats.NumTreeNodes)
+	}
+	t.Logf("best: %v, pv: %v, stats: %+v", kind, result.PV, result.Stats)
+}
+
+func TestExport(t *testing.T) {
+	pokers, landlord := initSearchPokers()
+	player := &mctsAI{cfg: MCTSConfig{Rand: rand.New(rand.NewSource(1))}}
+	player.SetSelf(landlord)
+	player.SetLandlord(landlord)
+	player.Start(pokers)
+	player.RecommendPlay(landlord.Role(landlord))
+	root := player.root
+
+	var buf bytes.Buffer
+	if err := root.Export(&buf, ExportJSON, 2, 1, LabelAction|LabelN); err != nil {
+		t.Fatalf("export json: %v", err)
+	}
+	var tree struct {
+		Depth    int `json:"depth"`
+		Children []struct {
+			Depth    int      `json:"depth"`
+			N        *float64 `json:"n"`
+			Q        *float64 `json:"q"`
+			Children []struct {
+				Depth    int               `json:"depth"`
+				Children []json.RawMessage `json:"children"`
+			} `json:"children"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &tree); err != nil {
+		t.Fatalf("unmarshal exported json: %v", err)
+	}
+	if len(tree.Children) == 0 {
+		t.Fatalf("exported tree has no children")
+	}
+	for _, child := range tree.Children {
+		if child.Depth != root.depth+1 || child.N == nil || *child.N < 1 || child.Q != nil {
+			t.Fatalf("bad exported child: %+v", child)
+		}
+		for _, grandchild := range child.Children {
+			if len(grandchild.Children) != 0 {
+				t.Fatalf("exported tree exceeds depth limit")
+			}
+		}
+	}
+
+	buf.Reset()
+	if err := root.Export(&buf, ExportDOT, 1, 0); err != nil {
+		t.Fatalf("export dot: %v", err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph mcts {") ||
+		strings.Count(dot, " -> ") != len(root.children) ||
+		strings.Count(dot, "[color=red]") != 1 {
+		t.Fatalf("bad exported dot:\n%s", dot)
+	}
+
+	if err := root.Export(&buf, ExportFormat(-1), 1, 0); err != ErrUnknownExportFormat {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 后台搜索每次持有锁执行的迭代次数
+const anytimeBatch = 16
+
+// 可以随时查询当前最优解和停止的搜索(anytime search)
+//
+// 搜索在单独的 goroutine 中执行,直到达到最大迭代次数或者调用 Stop.
+// 停止前只能通过 Result 查询搜索结果,不能直接访问搜索树
+type AnytimeSearch struct {
+	node      *Node
+	r         *rand.Rand
+	policyFn  PolicyFunc
+	rolloutFn RolloutFunc
+	alpha     float64
+	cparam    float64
+	maxcnt    int
+
+	mu    sync.Mutex
+	stats SearchStats
+	start time.Time
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	result   SearchResult
+}
+
+// 在后台开始蒙特卡洛树搜索,参数同 SearchWithRand. maxcnt 小于等于 0 时不限迭代次数,直到调用 Stop
+func (node *Node) StartSearch(r *rand.Rand, policyFn PolicyFunc, rolloutFn RolloutFunc, alpha, cparam float64, maxcnt int) *AnytimeSearch {
+	s := &AnytimeSearch{
+		node:      node,
+		r:         r,
+		policyFn:  policyFn,
+		rolloutFn: rolloutFn,
+		alpha:     alpha,
+		cparam:    cparam,
+		maxcnt:    maxcnt,
+		start:     time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *AnytimeSearch) run() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		s.mu.Lock()
+		for i := 0; i < anytimeBatch && (s.maxcnt <= 0 || s.stats.NumIterations < int64(s.maxcnt)); i++ {
+			s.node.iterate(s.r, s.policyFn, s.rolloutFn, s.alpha, s.cparam, &s.stats)
+		}
+		finished := s.maxcnt > 0 && s.stats.NumIterations >= int64(s.maxcnt)
+		s.mu.Unlock()
+		if finished {
+			return
+		}
+	}
+}
+
+// 达到最大迭代次数或者停止后关闭
+func (s *AnytimeSearch) Done() <-chan struct{} { return s.done }
+
+// 当前的搜索结果. 搜索停止前 Best 只用于比较,不能访问其子树
+func (s *AnytimeSearch) Result() SearchResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resultLocked(nil)
+}
+
+func (s *AnytimeSearch) resultLocked(r *rand.Rand) SearchResult {
+	stats := s.stats
+	stats.TimeOfSearch = Duration(time.Since(s.start))
+	stats.NumTreeNodes = s.node.Size()
+	result := s.node.result(r)
+	result.Stats = stats
+	return result
+}
+
+// 停止搜索并返回最终结果,可以重复调用
+func (s *AnytimeSearch) Stop() SearchResult {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		s.result = s.resultLocked(s.r)
+	})
+	return s.result
+}
+
+// 思考时间策略
+//
+// 先搜索 Min 时间,然后根据当前搜索结果估计局面的关键程度,在 Min 和 Max 之间按比例决定总的思考时间.
+// 思考时间同时是最短的响应时间: 搜索提前结束时等待到思考时间结束,使 AI 的反应像人一样自然.
+// 不需要搜索就能决定出牌时(见 RecommendPlay)立即返回
+type ThinkPolicy struct {
+	Min time.Duration `json:"min"`
+	Max time.Duration `json:"max"`
+}
+
+// 局面的关键程度,取值范围 [0,1], 为以下两项的平均值:
+// 根节点访问次数分布的归一化熵,候选动作越难分辨越关键;
+// 剩余牌最少的玩家的牌数,越接近出完越关键
+func Criticality(result SearchResult, state State) float64 {
+	var entropy float64
+	if len(result.Candidates) > 1 {
+		var total float64
+		for _, c := range result.Candidates {
+			total += c.Visits
+		}
+		if total > 0 {
+			for _, c := range result.Candidates {
+				if c.Visits > 0 {
+					p := c.Visits / total
+					entropy -= p * math.Log(p)
+				}
+			}
+			entropy /= math.Log(float64(len(result.Candidates)))
+		}
+	}
+	const endgame = 10
+	least := endgame
+	for _, pokers := range state.pokers {
+		if n := pokers.Len(); n < least {
+			least = n
+		}
+	}
+	return (entropy + 1 - float64(least)/endgame) / 2
+}
+
+// 关键程度为 criticality 时的思考时间
+func (p ThinkPolicy) Duration(criticality float64) time.Duration {
+	if criticality < 0 {
+		criticality = 0
+	} else if criticality > 1 {
+		criticality = 1
+	}
+	return p.Min + time.Duration(criticality*float64(p.Max-p.Min))
+}
+
+// 按思考时间策略搜索,参数同 SearchWithRand, maxcnt 小于等于 0 时只受思考时间限制.
+// ctx 结束时立即停止并返回当前的搜索结果
+func (p ThinkPolicy) Search(ctx context.Context, node *Node, r *rand.Rand, policyFn PolicyFunc, rolloutFn RolloutFunc, alpha, cparam float64, maxcnt int) SearchResult {
+	if action, ok := node.shortcut(); ok {
+		return node.shortcutResult(action)
+	}
+	return p.search(ctx, node, r, policyFn, rolloutFn, alpha, cparam, maxcnt)
+}
+
+// 同 Search, 但不检查是否可以不经搜索决定出牌
+func (p ThinkPolicy) search(ctx context.Context, node *Node, r *rand.Rand, policyFn PolicyFunc, rolloutFn RolloutFunc, alpha, cparam float64, maxcnt int) SearchResult {
+	s := node.StartSearch(r, policyFn, rolloutFn, alpha, cparam, maxcnt)
+	defer s.Stop()
+
+	wait := func(d time.Duration) bool {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	if !wait(p.Min) {
+		return s.Stop()
+	}
+	// 搜索提前结束时使用最终结果估计关键程度
+	var result SearchResult
+	select {
+	case <-s.Done():
+		result = s.Stop()
+	default:
+		result = s.Result()
+	}
+	if d := p.Duration(Criticality(result, node.state)) - time.Since(s.start); d > 0 {
+		wait(d)
+	}
+	return s.Stop()
+}
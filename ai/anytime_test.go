@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func newTestRoot() *Node {
+	pokers, landlord := initSearchPokers()
+	root := new(Node)
+	root.state = NewState(pokers, landlord)
+	root.action.player = landlord.Prev()
+	return root
+}
+
+func TestAnytimeSearch(t *testing.T) {
+	var (
+		root    = newTestRoot()
+		r       = rand.New(rand.NewSource(1))
+		policy  = func(node *Node) ([]Action, float64, int) { return legalActions(node, r) }
+		rollout = func(root, leaf *Node) float64 { return rolloutWithRand(root, leaf, r) }
+	)
+	s := root.StartSearch(r, policy, rollout, 1, 30, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Result().Stats.NumIterations < 100 {
+		if time.Now().After(deadline) {
+			t.Fatalf("anytime search made no progress")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s.Result().Best == nil {
+		t.Fatalf("polled result should have a best move")
+	}
+	result := s.Stop()
+	select {
+	case <-s.Done():
+	default:
+		t.Fatalf("search should be done after stop")
+	}
+	var visits float64
+	for _, c := range result.Candidates {
+		visits += c.Visits
+	}
+	if result.Best == nil || int64(visits) != result.Stats.NumIterations {
+		t.Fatalf("visits %v mismatch iterations %d", visits, result.Stats.NumIterations)
+	}
+	if again := s.Stop(); again.Stats.NumIterations != result.Stats.NumIterations || again.Best != result.Best {
+		t.Fatalf("stop should return the same result")
+	}
+
+	// 达到最大迭代次数后自动结束
+	s = newTestRoot().StartSearch(r, policy, rollout, 1, 30, 50)
+	<-s.Done()
+	if n := s.Stop().Stats.NumIterations; n != 50 {
+		t.Fatalf("search should stop after 50 iterations, got %d", n)
+	}
+}
+
+func TestThinkPolicy(t *testing.T) {
+	var (
+		p       = ThinkPolicy{Min: 20 * time.Millisecond, Max: 100 * time.Millisecond}
+		r       = rand.New(rand.NewSource(1))
+		policy  = func(node *Node) ([]Action, float64, int) { return legalActions(node, r) }
+		rollout = func(root, leaf *Node) float64 { return rolloutWithRand(root, leaf, r) }
+	)
+	if p.Duration(-1) != p.Min || p.Duration(0.5) != 60*time.Millisecond || p.Duration(2) != p.Max {
+		t.Fatalf("bad think duration")
+	}
+
+	root := newTestRoot()
+	start := time.Now()
+	result := p.Search(context.Background(), root, r, policy, rollout, 1, 30, 200)
+	elapsed := time.Since(start)
+	if result.Best == nil || elapsed < p.Min || elapsed > p.Max+time.Second {
+		t.Fatalf("bad thinking time %v", elapsed)
+	}
+	if c := Criticality(result, root.state); c < 0 || c > 1 {
+		t.Fatalf("criticality %v out of range", c)
+	}
+
+	// 只能不出时立即返回
+	var pokers [NumPlayer]PokerSet
+	pokers[0] = mustParsePokerSet(t, "$ # 3")
+	pokers[1] = mustParsePokerSet(t, "4 5")
+	pokers[2] = mustParsePokerSet(t, "6 7")
+	root = new(Node)
+	root.state = NewState(pokers, 0)
+	root.action.player = 2
+	root = root.Move(Action{player: 0, kind: Classify(mustParsePokerSet(t, "$ #"), DefaultOptions)[0]})
+	start = time.Now()
+	result = p.Search(context.Background(), root, r, policy, rollout, 1, 30, 200)
+	if time.Since(start) >= p.Min || result.Best == nil || result.Best.action.kind.Len() != 0 {
+		t.Fatalf("forced pass should return immediately, got %v after %v", result.Best, time.Since(start))
+	}
+
+	// ctx 结束时立即返回
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	p.Search(ctx, newTestRoot(), r, policy, rollout, 1, 30, 0)
+	if elapsed := time.Since(start); elapsed >= p.Min {
+		t.Fatalf("canceled search should return immediately, got %v", elapsed)
+	}
+}
+
+// ctx 结束时 AnalyzeContext 停止搜索并仍然建议合法的出牌
+func TestAnalyzeContext(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	hands, lastPokers := dealFullDeck(r, 0)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	for _, ctx := range []context.Context{canceled, timeout} {
+		cfg := MCTSConfig{Rand: rand.New(rand.NewSource(1)), Iterations: 1 << 30}
+		bots := []Analyzer{NewMCTSAIWithConfig(cfg)}
+		trustee, err := NewTrusteeAI(Record{Self: 0, Landlord: 0, Hand: hands[0], LastPokers: lastPokers}, cfg)
+		if err != nil {
+			t.Fatalf("new trustee AI: %v", err)
+		}
+		bots = append(bots, trustee)
+		for _, bot := range bots {
+			bot.SetSelf(0)
+			bot.SetLandlord(0)
+			bot.SetLastPokers(lastPokers)
+			bot.Start(hands)
+			start := time.Now()
+			kind, _ := bot.AnalyzeContext(ctx, "L")
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("%T: analysis should stop with ctx, got %v", bot, elapsed)
+			}
+			if kind.Len() == 0 || !hands[0].Contains(kind.Pokers()) {
+				t.Fatalf("%T: bad play %v", bot, kind)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ai
+
+import "github.com/gopherd/landlord/poker"
+
+// 农民配合的权重参数
+const (
+	// 队友出的牌倾向于不管
+	weightPassOnPartner = 4.0
+	// 队友快出完牌时更倾向于不管
+	weightPassOnPartnerFinishing = 4.0
+	// 用普通牌型管队友的牌
+	weightBeatPartner = 0.5
+	// 用炸弹或火箭管队友的牌
+	weightBombPartner = 0.1
+	// 主动出牌时给快出完牌的队友送牌的最大加成
+	weightFeedPartner = 4.0
+	// 能一手出完所有牌
+	weightGoOut = 16.0
+)
+
+// 当前需要管的牌及出牌者,没有需要管的牌时返回空牌型和 BadPosition
+func (node *Node) lead() (Kind, Position) {
+	if node.action.kind.Len() > 0 {
+		return node.action.kind, node.action.player
+	}
+	if node.parent != nil && node.parent.action.kind.Len() > 0 {
+		return node.parent.action.kind, node.parent.action.player
+	}
+	return Kind{}, BadPosition
+}
+
+// 计算 player 出牌 kind 的配合权重
+//
+// 能一手出完的牌权重最高,地主和农民都一样; 地主的其他出牌权重总是 1;
+// 队友出的牌倾向于不管,尤其不用炸弹管队友,队友只剩 1~2 张牌时更不应该管;
+// 主动出牌时如果队友只剩 1 张牌则倾向于出小单张,只剩 2 张牌则倾向于出小对子,牌越小权重越高
+func cooperationWeight(state State, lead Kind, leader, player Position, kind Kind) float64 {
+	if kind.Len() > 0 && kind.Len() == state.pokers[player].Len() {
+		return weightGoOut
+	}
+	partner := player.Partner(state.landlord)
+	if !partner.Valid() {
+		return 1
+	}
+	var (
+		weight     = 1.0
+		numPartner = state.pokers[partner].Len()
+	)
+	if lead.Len() > 0 && leader == partner {
+		switch {
+		case kind.Len() == 0:
+			weight *= weightPassOnPartner
+			if numPartner <= 2 {
+				weight *= weightPassOnPartnerFinishing
+			}
+		case kind.IsBomb() || kind.IsRocket():
+			weight *= weightBombPartner
+		default:
+			weight *= weightBeatPartner
+		}
+	} else if lead.Len() == 0 && numPartner <= 2 {
+		typ := kind.Type()
+		if (numPartner == 1 && typ == poker.Single1) || (numPartner == 2 && typ == poker.Double1) {
+			small := float64(maxPokerValue-kind.minValue) / float64(maxPokerValue-minPokerValue)
+			weight *= 1 + weightFeedPartner*small
+		}
+	}
+	return weight
+}
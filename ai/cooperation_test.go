@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+// 构造一个残局: 从开始出牌的手牌 pokers 依次执行 history 中的出牌
+func newScenario(t *testing.T, pokers [NumPlayer]PokerSet, landlord, self Position, history ...Action) *mctsAI {
+	player := new(mctsAI)
+	player.SetSelf(self)
+	player.SetLandlord(landlord)
+	player.Start(pokers)
+	for _, action := range history {
+		if !player.pokers[action.player].Contains(action.kind.Pokers()) {
+			t.Fatalf("player %d doesn't have %v", action.player, action.kind)
+		}
+		player.Play(action.player.Role(landlord), action.player, action.kind)
+	}
+	return player
+}
+
+func single(suit poker.Suit, value poker.Value) Kind {
+	return NewKind(1, 1, 0, 0).extend(NewPokerSetWithPoker(poker.NewPoker(suit, value)), emptyPokerSet)
+}
+
+func TestCooperationWeight(t *testing.T) {
+	var (
+		landlord = Position(0)
+		n        = landlord.Next()
+		p        = landlord.Prev()
+		pokers   [NumPlayer]PokerSet
+	)
+	pokers[landlord] = mustParsePokerSet(t, "♠3♠4♠5")
+	pokers[n] = mustParsePokerSet(t, "♥3♥9♥K♥A")
+	pokers[p] = mustParsePokerSet(t, "♣4")
+	state := NewState(pokers, landlord)
+
+	if n.Partner(landlord) != p || p.Partner(landlord) != n || landlord.Partner(landlord).Valid() {
+		t.Fatalf("bad partner")
+	}
+
+	// 主动出牌时,队友只剩 1 张牌: 小单张权重更高
+	small := cooperationWeight(state, Kind{}, BadPosition, n, single(poker.Heart, poker.P3))
+	big := cooperationWeight(state, Kind{}, BadPosition, n, single(poker.Heart, poker.PMA))
+	if small <= big || big <= 1 {
+		t.Fatalf("small single %v should weigh more than big single %v", small, big)
+	}
+	if w := cooperationWeight(state, Kind{}, BadPosition, landlord, single(poker.Spade, poker.P3)); w != 1 {
+		t.Fatalf("landlord weight should be 1, got %v", w)
+	}
+	// 能一手出完时地主和农民的权重一样
+	out := pokers
+	out[landlord] = mustParsePokerSet(t, "♠3")
+	if w := cooperationWeight(NewState(out, landlord), Kind{}, BadPosition, landlord, single(poker.Spade, poker.P3)); w != weightGoOut {
+		t.Fatalf("landlord going out should weigh %v, got %v", weightGoOut, w)
+	}
+	if w := cooperationWeight(state, Kind{}, BadPosition, p, single(poker.Club, poker.P4)); w != weightGoOut {
+		t.Fatalf("farmer going out should weigh %v, got %v", weightGoOut, w)
+	}
+
+	// 队友出的牌: 不出的权重高于管上
+	lead := single(poker.Club, poker.P4)
+	pass := cooperationWeight(state, lead, p, n, Kind{})
+	beat := cooperationWeight(state, lead, p, n, single(poker.Heart, poker.PK))
+	if pass <= beat {
+		t.Fatalf("pass %v should weigh more than beating partner %v", pass, beat)
+	}
+	// 对手出的牌不受影响
+	if w := cooperationWeight(state, lead, landlord, n, Kind{}); w != 1 {
+		t.Fatalf("weight on landlord's lead should be 1, got %v", w)
+	}
+}
+
+// 队友只剩 1 张牌时,农民应该出一张比队友小的单牌送队友走
+func TestCooperationFeedPartner(t *testing.T) {
+	var (
+		landlord = Position(0)
+		n        = landlord.Next()
+		p        = landlord.Prev()
+		pokers   [NumPlayer]PokerSet
+	)
+	pokers[landlord] = mustParsePokerSet(t, "♠5♠K♠A♠2") |
+		mustParsePokerSet(t, "♥A♥2")
+	pokers[n] = mustParsePokerSet(t, "♣2♣3♣9♣Q") |
+		mustParsePokerSet(t, "♦9♦Q")
+	pokers[p] = mustParsePokerSet(t, "♣4")
+
+	for i := 0; i < 5; i++ {
+		player := newScenario(t, pokers, landlord, n,
+			Action{player: landlord, kind: single(poker.Spade, poker.P5)},
+			Action{player: n, kind: single(poker.Club, poker.PM2)},
+			Action{player: p},
+			Action{player: landlord},
+		)
+		player.cfg = MCTSConfig{Rand: rand.New(rand.NewSource(int64(i)))}
+		kind := player.RecommendPlay(n.Role(landlord))
+		if kind.Type() != poker.Single1 || kind.minValue != poker.P3 {
+			t.Fatalf("farmer should lead single 3 to partner, got %v", kind)
+		}
+	}
+}
+
+// 队友出牌后只剩 1 张牌并且一定能走掉时,农民不应该管队友的牌
+func TestCooperationDontOvertakePartner(t *testing.T) {
+	var (
+		landlord = Position(0)
+		n        = landlord.Next()
+		p        = landlord.Prev()
+		pokers   [NumPlayer]PokerSet
+	)
+	pokers[landlord] = mustParsePokerSet(t, "♠3♠A♠2") |
+		mustParsePokerSet(t, "♥2")
+	pokers[n] = mustParsePokerSet(t, "♣8♣9♣K")
+	pokers[p] = mustParsePokerSet(t, "♦4♦7")
+
+	for i := 0; i < 5; i++ {
+		player := newScenario(t, pokers, landlord, n,
+			Action{player: landlord, kind: single(poker.Spade, poker.P3)},
+			Action{player: n},
+			Action{player: p, kind: single(poker.Diamond, poker.P7)},
+			Action{player: landlord},
+		)
+		player.cfg = MCTSConfig{Rand: rand.New(rand.NewSource(int64(i)))}
+		kind := player.RecommendPlay(n.Role(landlord))
+		if kind.Len() != 0 {
+			t.Fatalf("farmer should pass on partner's lead, got %v", kind)
+		}
+	}
+}
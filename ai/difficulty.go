@@ -0,0 +1,189 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+// AI 难度
+type Difficulty int8
+
+const (
+	// 专家: 完整的搜索,总是选择访问次数最多的出牌. 零值,即 MCTSConfig 的默认难度
+	Expert Difficulty = iota
+	// 中级: 减少搜索次数,偶尔选择次优的出牌
+	Intermediate
+	// 初级: 很少的搜索次数,经常选择次优的出牌,并且不记牌
+	Beginner
+)
+
+var difficultyNames = [...]string{
+	Expert:       "expert",
+	Intermediate: "intermediate",
+	Beginner:     "beginner",
+}
+
+func (d Difficulty) String() string {
+	if d >= 0 && int(d) < len(difficultyNames) {
+		return difficultyNames[d]
+	}
+	return fmt.Sprintf("Difficulty(%d)", int8(d))
+}
+
+// 根据名称解析难度
+func ParseDifficulty(s string) (Difficulty, error) {
+	for d, name := range difficultyNames {
+		if s == name {
+			return Difficulty(d), nil
+		}
+	}
+	return Expert, fmt.Errorf("ai: unknown difficulty %q", s)
+}
+
+func (d Difficulty) MarshalText() ([]byte, error) {
+	if d < 0 || int(d) >= len(difficultyNames) {
+		return nil, fmt.Errorf("ai: unknown difficulty %d", int8(d))
+	}
+	return []byte(d.String()), nil
+}
+
+func (d *Difficulty) UnmarshalText(text []byte) error {
+	x, err := ParseDifficulty(string(text))
+	if err != nil {
+		return err
+	}
+	*d = x
+	return nil
+}
+
+// 难度参数
+type DifficultyParams struct {
+	// 搜索迭代次数的比例
+	Budget float64
+	// 按访问次数选择出牌的温度: 选择概率正比于 访问次数^(1/Temperature), 0 表示总是选择访问次数最多的出牌
+	Temperature float64
+	// 主动出牌时按先验概率改为选择其他出牌的概率
+	LeadMistake float64
+	// 不记牌: 不知道其他玩家的手牌和已经出过的牌,每次搜索前从自己手牌以外的牌中随机给其他玩家发牌.
+	// 否则同 Expert 一样在完全信息下搜索
+	NoTracking bool
+}
+
+var difficultyParams = [...]DifficultyParams{
+	Expert:       {Budget: 1},
+	Intermediate: {Budget: 0.2, Temperature: 0.5, LeadMistake: 0.05},
+	Beginner:     {Budget: 0.05, Temperature: 1, LeadMistake: 0.2, NoTracking: true},
+}
+
+// 难度对应的参数,未知的难度使用 Expert 的参数
+func (d Difficulty) Params() DifficultyParams {
+	if d >= 0 && int(d) < len(difficultyParams) {
+		return difficultyParams[d]
+	}
+	return difficultyParams[Expert]
+}
+
+// 不记牌时的搜索根节点: 复制 root 并把 self 以外玩家的手牌换成从 self 手牌以外的牌中随机抽取的同样张数的牌
+func forgetfulRoot(r *rand.Rand, root *Node, self Position) *Node {
+	var (
+		pool []poker.Value
+		hand = root.state.pokers[self]
+	)
+	for v := minPokerValue; v <= maxPokerValue; v++ {
+		for i := FullDeck.Count(v) - hand.Count(v); i > 0; i-- {
+			pool = append(pool, v)
+		}
+	}
+	shuffle(r, len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+
+	node := root.clone()
+	for i := range node.state.pokers {
+		pos := Position(i)
+		if pos == self {
+			continue
+		}
+		var pokers PokerSet
+		for n := root.state.pokers[pos].Len(); n > 0; n-- {
+			pokers.AddByValue(FullDeck, pool[len(pool)-1], 1)
+			pool = pool[:len(pool)-1]
+		}
+		node.state.pokers[pos] = pokers.Normalize()
+	}
+	return node
+}
+
+// 按难度从搜索结果中选择动作, lead 为需要管的牌. 主动出牌时才会按概率犯错
+func pickAction(r *rand.Rand, d Difficulty, result SearchResult, lead Kind) Action {
+	params := d.Params()
+	if params.Temperature <= 0 && params.LeadMistake <= 0 {
+		return result.Best.action
+	}
+	i := pickByVisits(r, result.Candidates, params.Temperature)
+	if lead.Len() == 0 && float64n(r) < params.LeadMistake {
+		i = pickMistake(r, result.Candidates, i)
+	}
+	return result.Candidates[i].Action
+}
+
+// 按访问次数和温度选择一个候选动作, temperature 为 0 时选择第一个(访问次数最多的)候选动作
+func pickByVisits(r *rand.Rand, candidates []Candidate, temperature float64) int {
+	if temperature <= 0 || len(candidates) < 2 || candidates[0].Visits <= 0 {
+		return 0
+	}
+	weights := make([]float64, len(candidates))
+	for i, c := range candidates {
+		if c.Visits > 0 {
+			weights[i] = math.Pow(c.Visits/candidates[0].Visits, 1/temperature)
+		}
+	}
+	return pickWeighted(r, weights)
+}
+
+// 按先验概率从 except 以外的候选动作中选择一个,没有其他候选动作时返回 except
+func pickMistake(r *rand.Rand, candidates []Candidate, except int) int {
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, c := range candidates {
+		if i != except {
+			weights[i] = c.Prior
+			total += c.Prior
+		}
+	}
+	if total <= 0 {
+		for i := range weights {
+			if i != except {
+				weights[i] = 1
+			}
+		}
+	}
+	if i := pickWeighted(r, weights); i >= 0 {
+		return i
+	}
+	return except
+}
+
+// 按权重随机选择,权重都为 0 时返回 -1
+func pickWeighted(r *rand.Rand, weights []float64) int {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return -1
+	}
+	x := float64n(r) * total
+	last := -1
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		last = i
+		if x -= w; x < 0 {
+			return i
+		}
+	}
+	return last
+}
@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func TestDifficulty(t *testing.T) {
+	for _, d := range []Difficulty{Expert, Intermediate, Beginner} {
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("marshal %v: %v", d, err)
+		}
+		var got Difficulty
+		if err := json.Unmarshal(data, &got); err != nil || got != d {
+			t.Fatalf("unmarshal %s: got %v, %v", data, got, err)
+		}
+	}
+	if _, err := ParseDifficulty("master"); err == nil {
+		t.Fatalf("parse unknown difficulty should fail")
+	}
+	var zero MCTSConfig
+	if zero.Difficulty != Expert || Expert.Params().Budget != 1 || Expert.Params().Temperature != 0 {
+		t.Fatalf("default difficulty should be expert with full strength")
+	}
+	for _, d := range []Difficulty{Intermediate, Beginner} {
+		if d.Params().Budget >= (d - 1).Params().Budget {
+			t.Fatalf("%v should search less than %v", d, d-1)
+		}
+	}
+}
+
+func TestPickByVisits(t *testing.T) {
+	candidates := []Candidate{{Visits: 90, Prior: 0.1}, {Visits: 10, Prior: 0.2}, {Visits: 0, Prior: 0.7}}
+	r := rand.New(rand.NewSource(1))
+	if i := pickByVisits(r, candidates, 0); i != 0 {
+		t.Fatalf("zero temperature should pick the most visited, got %d", i)
+	}
+	var counts [3]int
+	for i := 0; i < 1000; i++ {
+		counts[pickByVisits(r, candidates, 1)]++
+	}
+	if counts[2] != 0 || counts[1] < 50 || counts[1] > 150 {
+		t.Fatalf("temperature 1 should pick by visits, got %v", counts)
+	}
+	counts = [3]int{}
+	for i := 0; i < 1000; i++ {
+		counts[pickMistake(r, candidates, 0)]++
+	}
+	if counts[0] != 0 || counts[2] < counts[1] {
+		t.Fatalf("mistakes should avoid the best and follow priors, got %v", counts)
+	}
+	if i := pickMistake(r, candidates[:1], 0); i != 0 {
+		t.Fatalf("mistake with single candidate should keep it, got %d", i)
+	}
+}
+
+func TestForgetfulRoot(t *testing.T) {
+	pokers, landlord := initPokers()
+	root := new(Node)
+	root.state = NewState(pokers, landlord)
+	root.action.player = landlord.Prev()
+	node := forgetfulRoot(rand.New(rand.NewSource(1)), root, landlord)
+	if node.state.pokers[landlord] != root.state.pokers[landlord] {
+		t.Fatalf("own hand should not change")
+	}
+	for i := range pokers {
+		if n := node.state.pokers[i].Len(); n != pokers[i].Len() {
+			t.Fatalf("player %d should have %d pokers, got %d", i, pokers[i].Len(), n)
+		}
+		if node.state.pokers[i] != node.state.pokers[i].Normalize() {
+			t.Fatalf("player %d pokers should be normalized", i)
+		}
+	}
+	if node.parent != root.parent || node.action != root.action {
+		t.Fatalf("forgetful root should keep the history")
+	}
+}
+
+// 各难度的 AI 都能完成合法的牌局
+func TestDifficultyPlayout(t *testing.T) {
+	for _, d := range []Difficulty{Intermediate, Beginner} {
+		pokers, landlord := initPokers()
+		r := rand.New(rand.NewSource(int64(d)))
+		var players [NumPlayer]AI
+		for i := range players {
+			players[i] = NewMCTSAIWithConfig(MCTSConfig{Rand: r, Iterations: 200, Difficulty: d})
+			players[i].SetSelf(Position(i))
+			players[i].SetLandlord(landlord)
+			players[i].Start(pokers)
+		}
+		hands := pokers
+		for pos := landlord; ; pos = pos.Next() {
+			tag := pos.Role(landlord)
+			kind := players[pos].RecommendPlay(tag)
+			if !hands[pos].Remove(kind.Pokers()) {
+				t.Fatalf("%v: %v played %v not in hand %v", d, pos, kind, hands[pos])
+			}
+			for _, player := range players {
+				player.Play(tag, pos, kind)
+			}
+			if hands[pos].Empty() {
+				break
+			}
+		}
+	}
+}
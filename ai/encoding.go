@@ -0,0 +1,222 @@
+package ai
+
+import "github.com/gopherd/landlord/poker"
+
+// 特征编码: 将信息集编码成固定长度的 []float32, 将牌型编码成动作向量, 用于训练和使用神经网络.
+//
+// 一组牌编码为 4×15 的平面: 第 c 行第 v 列(下标 c*15+v)为 1 表示点数 3+v 的牌至少有 c+1 张,
+// 点数从 3 到大王共 15 种, 只使用各点数的张数, 与花色无关.
+//
+// 与座位有关的特征都按相对地主的角色排列: 依次为地主, 地主下家, 地主上家(即 Position.Role 的 L, N, P),
+// 所以只要角色相同,特征与实际座位号无关.
+//
+// 特征各部分的位置和长度如下:
+//
+//	FeatureHand       1 个平面     自己的手牌
+//	FeatureUnknown    1 个平面     其他两家剩余牌的并集(除自己手牌和已出的牌外的所有牌)
+//	FeaturePlayed     3 个平面     各角色已出的牌
+//	FeatureLastPokers 1 个平面     底牌
+//	FeatureLead       ActionSize   需要管的牌,主动出牌时全为 0
+//	FeatureHistory    HistoryLen 个平面  最近的出牌,最近的在前,不出时为全 0
+//	FeaturePasses     HistoryLen   最近的出牌是否为不出
+//	FeatureNumPokers  3×21         各角色剩余牌数(0~20)的 one-hot 编码
+//	FeatureRole       3            自己角色的 one-hot 编码
+//	FeatureLeader     3            需要管的牌的出牌者角色的 one-hot 编码, 主动出牌时全为 0
+//	FeatureBombs      MaxBombs+1   已出炸弹和火箭总数的 one-hot 编码, 超过 MaxBombs 时按 MaxBombs 计算
+//
+// 动作向量的各部分:
+//
+//	ActionBody        1 个平面     主干部分的牌
+//	ActionKicker      1 个平面     带牌部分的牌
+//	ActionPass        1            是否不出
+//	ActionBomb        1            是否为炸弹
+//	ActionRocket      1            是否为火箭
+const (
+	// 点数种类
+	NumValues = numPokerValue
+	// 一组牌的平面编码长度
+	PlaneSize = 4 * NumValues
+	// 特征中包含的最近出牌数
+	HistoryLen = 8
+	// 玩家最多的牌数
+	MaxHandPokers = 20
+	// 炸弹数编码的上限
+	MaxBombs = 14
+
+	ActionBody   = 0
+	ActionKicker = ActionBody + PlaneSize
+	ActionPass   = ActionKicker + PlaneSize
+	ActionBomb   = ActionPass + 1
+	ActionRocket = ActionBomb + 1
+	// 动作向量长度
+	ActionSize = ActionRocket + 1
+
+	FeatureHand       = 0
+	FeatureUnknown    = FeatureHand + PlaneSize
+	FeaturePlayed     = FeatureUnknown + PlaneSize
+	FeatureLastPokers = FeaturePlayed + NumPlayer*PlaneSize
+	FeatureLead       = FeatureLastPokers + PlaneSize
+	FeatureHistory    = FeatureLead + ActionSize
+	FeaturePasses     = FeatureHistory + HistoryLen*PlaneSize
+	FeatureNumPokers  = FeaturePasses + HistoryLen
+	FeatureRole       = FeatureNumPokers + NumPlayer*(MaxHandPokers+1)
+	FeatureLeader     = FeatureRole + NumPlayer
+	FeatureBombs      = FeatureLeader + NumPlayer
+	// 特征长度
+	FeatureSize = FeatureBombs + MaxBombs + 1
+)
+
+// 信息集: 玩家决策时可以看到的所有信息. 牌可以是正则化的,编码时只使用各点数的张数
+type InfoSet struct {
+	// 自己的位置
+	Self Position
+	// 地主位置
+	Landlord Position
+	// 自己的手牌
+	Hand PokerSet
+	// 底牌
+	LastPokers PokerSet
+	// 各玩家已出的牌
+	Played [NumPlayer]PokerSet
+	// 各玩家剩余牌数
+	NumPokers [NumPlayer]int
+	// 需要管的牌及出牌者,主动出牌时为空牌型和 BadPosition
+	Lead   Kind
+	Leader Position
+	// 已出的炸弹和火箭总数
+	Bombs int
+	// 出牌记录(含不出),从地主的第一手开始
+	History []Action
+}
+
+// 根据出牌记录 history 之后的状态 state 创建 self 视角的信息集
+func NewInfoSet(state State, self Position, lastPokers PokerSet, history []Action) InfoSet {
+	info := InfoSet{
+		Self:       self,
+		Landlord:   state.landlord,
+		Hand:       state.pokers[self],
+		LastPokers: lastPokers,
+		Leader:     BadPosition,
+		History:    history,
+	}
+	for i, pokers := range state.pokers {
+		info.NumPokers[i] = pokers.Len()
+	}
+	for _, action := range history {
+		kind := action.kind
+		if kind.Len() > 0 {
+			info.Played[action.player].Add(kind.Pokers())
+			info.Lead, info.Leader = kind, action.player
+			if kind.IsBomb() || kind.IsRocket() {
+				info.Bombs++
+			}
+		}
+		if action.player.Next() == info.Leader {
+			info.Lead, info.Leader = Kind{}, BadPosition
+		}
+	}
+	return info
+}
+
+// 从根节点到 node 的出牌记录,不包含没有父节点的节点的动作
+func (node *Node) History() []Action {
+	var history []Action
+	for ; node != nil && node.parent != nil; node = node.parent {
+		history = append(history, node.action)
+	}
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history
+}
+
+// 编码成长度为 FeatureSize 的特征
+func (info InfoSet) Features() []float32 {
+	f := make([]float32, FeatureSize)
+	encodePlane(f[FeatureHand:], info.Hand)
+
+	var unknown [NumValues]int
+	for v := range unknown {
+		value := minPokerValue + poker.Value(v)
+		unknown[v] = FullDeck.Count(value) - info.Hand.Count(value)
+		for _, played := range info.Played {
+			unknown[v] -= played.Count(value)
+		}
+	}
+	encodeCounts(f[FeatureUnknown:], unknown)
+
+	for i := range info.Played {
+		pos := Position(i)
+		role := pos.roleIndex(info.Landlord)
+		encodePlane(f[FeaturePlayed+role*PlaneSize:], info.Played[pos])
+		f[FeatureNumPokers+role*(MaxHandPokers+1)+clamp(info.NumPokers[pos], MaxHandPokers)] = 1
+	}
+	encodePlane(f[FeatureLastPokers:], info.LastPokers)
+	if info.Lead.Len() > 0 {
+		encodeKind(f[FeatureLead:], info.Lead)
+		f[FeatureLeader+info.Leader.roleIndex(info.Landlord)] = 1
+	}
+	for i := 0; i < HistoryLen && i < len(info.History); i++ {
+		kind := info.History[len(info.History)-1-i].kind
+		if kind.Len() == 0 {
+			f[FeaturePasses+i] = 1
+		} else {
+			encodePlane(f[FeatureHistory+i*PlaneSize:], kind.Pokers())
+		}
+	}
+	f[FeatureRole+info.Self.roleIndex(info.Landlord)] = 1
+	f[FeatureBombs+clamp(info.Bombs, MaxBombs)] = 1
+	return f
+}
+
+// 将牌型编码成长度为 ActionSize 的动作向量,空牌型表示不出
+func EncodeKind(kind Kind) []float32 {
+	f := make([]float32, ActionSize)
+	encodeKind(f, kind)
+	return f
+}
+
+func encodeKind(f []float32, kind Kind) {
+	if kind.Len() == 0 {
+		f[ActionPass] = 1
+		return
+	}
+	encodePlane(f[ActionBody:], kind.body)
+	encodePlane(f[ActionKicker:], kind.kicker)
+	if kind.IsRocket() {
+		f[ActionRocket] = 1
+	} else if kind.IsBomb() {
+		f[ActionBomb] = 1
+	}
+}
+
+func encodePlane(f []float32, pokers PokerSet) {
+	var counts [NumValues]int
+	for v := range counts {
+		counts[v] = pokers.Count(minPokerValue + poker.Value(v))
+	}
+	encodeCounts(f, counts)
+}
+
+func encodeCounts(f []float32, counts [NumValues]int) {
+	for v, n := range counts {
+		for c := 0; c < n && c < 4; c++ {
+			f[c*NumValues+v] = 1
+		}
+	}
+}
+
+// 相对地主的角色编号: 地主为 0, 地主下家为 1, 地主上家为 2
+func (pos Position) roleIndex(landlord Position) int {
+	return (int(pos) - int(landlord) + NumPlayer) % NumPlayer
+}
+
+func clamp(n, max int) int {
+	if n > max {
+		return max
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,166 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 测试用牌局: 地主出对 3,下家出对 5,上家不出,轮到地主管下家的对 5
+func encodingGame(t *testing.T, landlord Position) (State, PokerSet, []Action) {
+	t.Helper()
+	var hands [NumPlayer]PokerSet
+	for i, s := range []string{"33 789 JJ QQ 2 $ 444", "55 66 K A", "8 9 T 22 #"} {
+		pos := Position((i + int(landlord)) % NumPlayer)
+		pset, err := parsePokerSet(s, hands[0]|hands[1]|hands[2])
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		hands[pos] = pset
+	}
+	state := NewState(hands, landlord)
+	var history []Action
+	for i, s := range []string{"33", "55", ""} {
+		pos := Position((i + int(landlord)) % NumPlayer)
+		var kind Kind
+		if s != "" {
+			pokers, err := hands[pos].Select(s)
+			if err != nil {
+				t.Fatalf("select %q: %v", s, err)
+			}
+			kind = Classify(pokers, DefaultOptions)[0]
+		}
+		action := NewAction(pos, kind)
+		state = action.Do(state)
+		history = append(history, action)
+	}
+	return state, hands[landlord.Prev()], history
+}
+
+// 交换花色
+func swapSuits(pset PokerSet) PokerSet {
+	var ret PokerSet
+	for v := 0; v < NumValues; v++ {
+		block := uint64(pset) >> (v * 4) & 0xF
+		if v < NumValues-2 {
+			block = block>>2 | block&3<<2
+		}
+		ret |= PokerSet(block << (v * 4))
+	}
+	return ret
+}
+
+func TestFeatures(t *testing.T) {
+	state, last, history := encodingGame(t, 0)
+	info := NewInfoSet(state, 0, last, history)
+	if !info.Lead.Equal(history[1].kind) || info.Leader != 1 || info.Played[1].Len() != 2 || info.NumPokers[0] != 12 {
+		t.Fatalf("bad info set %+v", info)
+	}
+	f := info.Features()
+	if len(f) != FeatureSize {
+		t.Fatalf("features should have %d slots, got %d", FeatureSize, len(f))
+	}
+	sum := func(from, n int) int {
+		var total int
+		for _, x := range f[from : from+n] {
+			if x != 0 && x != 1 {
+				t.Fatalf("feature slot %d is %v", from, x)
+			}
+			total += int(x)
+		}
+		return total
+	}
+	if n := sum(FeatureHand, PlaneSize); n != info.Hand.Len() {
+		t.Fatalf("hand plane has %d pokers, want %d", n, info.Hand.Len())
+	}
+	if n := sum(FeatureUnknown, PlaneSize); n != FullDeck.Len()-info.Hand.Len()-4 {
+		t.Fatalf("unknown plane has %d pokers", n)
+	}
+	if sum(FeatureNumPokers, NumPlayer*(MaxHandPokers+1)) != NumPlayer || f[FeatureNumPokers+12] != 1 {
+		t.Fatalf("bad number of pokers")
+	}
+	if !reflect.DeepEqual(f[FeatureLead:FeatureLead+ActionSize], EncodeKind(info.Lead)) || sum(FeatureLeader, NumPlayer) != 1 || f[FeatureLeader+1] != 1 {
+		t.Fatalf("bad lead")
+	}
+	if f[FeaturePasses] != 1 || sum(FeatureHistory, PlaneSize) != 0 || sum(FeatureHistory+PlaneSize, PlaneSize) != 2 {
+		t.Fatalf("bad history")
+	}
+	if f[FeatureRole] != 1 || f[FeatureBombs] != 1 {
+		t.Fatalf("bad role or bombs")
+	}
+
+	// 两家都不出后主动出牌
+	history = []Action{history[0], NewAction(1, Kind{}), NewAction(2, Kind{})}
+	free := NewInfoSet(state, 0, last, history)
+	if free.Lead.Len() != 0 || free.Leader != BadPosition {
+		t.Fatalf("bad lead %v by %v", free.Lead, free.Leader)
+	}
+	f = free.Features()
+	if sum(FeatureLead, ActionSize) != 0 || sum(FeatureLeader, NumPlayer) != 0 || sum(FeaturePasses, HistoryLen) != 2 {
+		t.Fatalf("leading player should have no lead")
+	}
+}
+
+// 特征与花色及座位号无关,只与角色有关
+func TestFeaturesInvariance(t *testing.T) {
+	state, last, history := encodingGame(t, 0)
+	want := NewInfoSet(state, 1, last, history).Features()
+
+	// 交换所有牌的花色
+	swapped := NewInfoSet(state, 1, last, history)
+	swapped.Hand = swapSuits(swapped.Hand)
+	swapped.LastPokers = swapSuits(swapped.LastPokers)
+	for i := range swapped.Played {
+		swapped.Played[i] = swapSuits(swapped.Played[i])
+	}
+	swapped.History = nil
+	for _, action := range history {
+		kind := action.kind.extend(swapSuits(action.kind.body), swapSuits(action.kind.kicker))
+		swapped.History = append(swapped.History, NewAction(action.player, kind))
+	}
+	if !reflect.DeepEqual(swapped.Features(), want) {
+		t.Fatalf("features should not depend on suits")
+	}
+
+	// 所有座位轮转
+	for landlord := Position(1); landlord < NumPlayer; landlord++ {
+		state, last, history := encodingGame(t, landlord)
+		got := NewInfoSet(state, landlord.Next(), last, history).Features()
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("features should not depend on seats (landlord %v)", landlord)
+		}
+		other := NewInfoSet(state, landlord.Prev(), last, history).Features()
+		if reflect.DeepEqual(other, want) {
+			t.Fatalf("different roles should have different features")
+		}
+	}
+}
+
+func TestEncodeKind(t *testing.T) {
+	hand, err := ParsePokerSet("333 444 555 6666 77 8 9 T #$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]Kind)
+	for _, kind := range append(hand.MatchAll(Kind{}, Kind{}, DefaultOptions), Kind{}) {
+		f := EncodeKind(kind)
+		if len(f) != ActionSize {
+			t.Fatalf("action should have %d slots, got %d", ActionSize, len(f))
+		}
+		key := string(float32Bytes(f))
+		if other, ok := seen[key]; ok {
+			t.Fatalf("%v and %v have the same encoding", kind, other)
+		}
+		seen[key] = kind
+		if (f[ActionPass] == 1) != (kind.Len() == 0) || (f[ActionBomb] == 1) != kind.IsBomb() || (f[ActionRocket] == 1) != kind.IsRocket() {
+			t.Fatalf("bad flags of %v", kind)
+		}
+	}
+}
+
+func float32Bytes(f []float32) []byte {
+	b := make([]byte, len(f))
+	for i, x := range f {
+		b[i] = byte(x)
+	}
+	return b
+}
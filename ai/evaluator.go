@@ -0,0 +1,42 @@
+package ai
+
+// 局面评估: 根据决策玩家的信息集和所有合法出牌,返回各出牌的先验概率(可以不归一化)和以决策玩家视角计算的局面估值.
+// 估值的尺度与推演结果相同,即胜负乘以倍数. 同一个 Evaluator 可能被多个 AI 并发调用
+type Evaluator interface {
+	Evaluate(info InfoSet, kinds []Kind) (priors []float64, value float64)
+}
+
+// 使用局面评估创建策略函数, lastPokers 为底牌. 推演时选择先验概率最大的动作
+func NewPolicy(e Evaluator, lastPokers PokerSet) PolicyFunc {
+	return func(node *Node) ([]Action, float64, int) {
+		next, kinds := node.legalKinds()
+		if len(kinds) == 0 {
+			return nil, 0, -1
+		}
+		priors, value := e.Evaluate(NewInfoSet(node.state, next, lastPokers, node.History()), kinds)
+		var total float64
+		for _, p := range priors {
+			if p > 0 {
+				total += p
+			}
+		}
+		var (
+			actions = make([]Action, len(kinds))
+			index   int
+		)
+		for i, kind := range kinds {
+			prob := 1 / float64(len(kinds))
+			if len(priors) == len(kinds) && total > 0 {
+				prob = 0
+				if priors[i] > 0 {
+					prob = priors[i] / total
+				}
+			}
+			actions[i] = Action{player: next, kind: kind, prob: prob}
+			if prob > actions[index].prob {
+				index = i
+			}
+		}
+		return actions, value, index
+	}
+}
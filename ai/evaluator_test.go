@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"math"
+	"testing"
+)
+
+// 测试用的局面评估: 偏好牌数最多的出牌
+type largestEvaluator struct {
+	info InfoSet
+}
+
+func (e *largestEvaluator) Evaluate(info InfoSet, kinds []Kind) ([]float64, float64) {
+	e.info = info
+	priors := make([]float64, len(kinds))
+	for i, kind := range kinds {
+		priors[i] = float64(kind.Len())
+	}
+	return priors, 1
+}
+
+type uniformEvaluator struct{}
+
+func (uniformEvaluator) Evaluate(InfoSet, []Kind) ([]float64, float64) { return nil, 0 }
+
+func TestNewPolicy(t *testing.T) {
+	state, last, history := encodingGame(t, 0)
+	root := NewNode(nil, Action{player: Position(0).Prev()}, NewState([NumPlayer]PokerSet{}, 0))
+	node := root
+	for _, action := range history {
+		node = NewNode(node, action, state)
+	}
+
+	e := new(largestEvaluator)
+	actions, value, index := NewPolicy(e, last)(node)
+	if value != 1 || len(actions) == 0 || e.info.Self != 0 || e.info.Lead.Len() != 2 || len(e.info.History) != len(history) {
+		t.Fatalf("bad evaluation: %v, %+v", value, e.info)
+	}
+	// 需要管对 5, 可以出对 J, 对 Q 或不出
+	var total float64
+	for _, action := range actions {
+		total += action.prob
+		if action.player != 0 || action.prob > actions[index].prob {
+			t.Fatalf("bad action %v, best %v", action, actions[index])
+		}
+	}
+	if math.Abs(total-1) > 1e-9 || actions[index].kind.Len() != 2 || actions[len(actions)-1].prob != 0 {
+		t.Fatalf("priors should be normalized and prefer the largest play: %v", actions)
+	}
+
+	actions, _, _ = NewPolicy(uniformEvaluator{}, last)(node)
+	for _, action := range actions {
+		if action.prob != 1/float64(len(actions)) {
+			t.Fatalf("priors should be uniform without evaluation: %v", actions)
+		}
+	}
+}
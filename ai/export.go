@@ -0,0 +1,199 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 搜索树导出格式
+type ExportFormat int
+
+const (
+	ExportDOT  ExportFormat = iota // graphviz dot 格式
+	ExportJSON                     // 嵌套的 JSON 格式
+)
+
+var ErrUnknownExportFormat = errors.New("ai: unknown export format")
+
+// 导出节点时包含的标签,可以按位组合
+type ExportLabel uint
+
+const (
+	LabelHands  ExportLabel = 1 << iota // 各玩家剩余的牌
+	LabelAction                         // 到达节点的动作(出牌玩家和出的牌)
+	LabelN                              // 访问次数
+	LabelQ                              // 动作奖励
+	LabelP                              // 先验概率
+	LabelU                              // 置信上限
+
+	LabelStats = LabelN | LabelQ | LabelP | LabelU
+	LabelAll   = LabelHands | LabelAction | LabelStats
+)
+
+// 导出以当前节点为根的搜索树
+//
+// depth 为导出的最大深度(根节点深度为 0),小于 0 表示不限深度;
+// 访问次数少于 minVisits 的子节点(及其子树)不会导出;
+// labels 指定导出的标签,不指定时导出所有标签.
+// DOT 格式中每层访问次数最多的子节点的边会标记为红色
+func (node *Node) Export(w io.Writer, format ExportFormat, depth int, minVisits float64, labels ...ExportLabel) error {
+	var label ExportLabel
+	for _, l := range labels {
+		label |= l
+	}
+	if len(labels) == 0 {
+		label = LabelAll
+	}
+	e := exporter{
+		depth:     depth,
+		minVisits: minVisits,
+		label:     label,
+	}
+	switch format {
+	case ExportDOT:
+		return e.writeDOT(w, node)
+	case ExportJSON:
+		return e.writeJSON(w, node)
+	default:
+		return ErrUnknownExportFormat
+	}
+}
+
+type exporter struct {
+	depth     int
+	minVisits float64
+	label     ExportLabel
+	nextId    int
+}
+
+func (e *exporter) has(label ExportLabel) bool { return e.label&label != 0 }
+
+// 遍历需要导出的子节点
+func (e *exporter) children(root, node *Node) []*Node {
+	if e.depth >= 0 && node.depth-root.depth >= e.depth {
+		return nil
+	}
+	var children []*Node
+	for _, child := range node.children {
+		if child.n >= e.minVisits {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+func (e *exporter) writeDOT(w io.Writer, root *Node) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph mcts {\n")
+	var walk func(node *Node, name string)
+	walk = func(node *Node, name string) {
+		best := node.mostVisitedChild()
+		for _, child := range e.children(root, node) {
+			childName := e.writeDOTNode(&buf, child)
+			attr := ""
+			if child == best {
+				attr = "[color=red]"
+			}
+			fmt.Fprintf(&buf, "\t%s -> %s%s;\n", name, childName, attr)
+			walk(child, childName)
+		}
+	}
+	walk(root, e.writeDOTNode(&buf, root))
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (e *exporter) writeDOTNode(buf *bytes.Buffer, node *Node) string {
+	name := fmt.Sprintf("s_%d_%d", node.depth, e.nextId)
+	e.nextId++
+
+	var label bytes.Buffer
+	fmt.Fprintf(&label, "node: %s\\lchildren: %d\\l", name, len(node.children))
+	if e.has(LabelAction) {
+		fmt.Fprintf(&label, "pos: %d\\lplay: %v\\l", node.action.player, node.action.kind.Pokers())
+	}
+	if e.has(LabelN) {
+		fmt.Fprintf(&label, "N: %.4g\\l", node.n)
+	}
+	if e.has(LabelQ) {
+		fmt.Fprintf(&label, "Q: %.4g\\l", node.q)
+	}
+	if e.has(LabelP) {
+		fmt.Fprintf(&label, "P: %.4g\\l", node.p)
+	}
+	if e.has(LabelU) {
+		fmt.Fprintf(&label, "U: %.4g\\l", node.u)
+	}
+	if e.has(LabelHands) {
+		for i, pokers := range node.state.pokers {
+			fmt.Fprintf(&label, "p%d: %v\\l", i, pokers.StringWithoutSuit())
+		}
+	}
+
+	color := "blue"
+	if node.action.player == node.state.landlord {
+		color = "black"
+	}
+	fmt.Fprintf(buf, "\t%s [shape=box,color=%s,label=\"%s\"];\n", name, color, label.String())
+	return name
+}
+
+// JSON 格式导出的节点
+type exportedNode struct {
+	Depth    int             `json:"depth"`
+	Player   *Position       `json:"player,omitempty"`
+	Kind     *Kind           `json:"kind,omitempty"`
+	Hands    []string        `json:"hands,omitempty"`
+	N        *float64        `json:"n,omitempty"`
+	Q        *float64        `json:"q,omitempty"`
+	P        *float64        `json:"p,omitempty"`
+	U        *float64        `json:"u,omitempty"`
+	Children []*exportedNode `json:"children,omitempty"`
+}
+
+func (e *exporter) writeJSON(w io.Writer, root *Node) error {
+	var build func(node *Node) *exportedNode
+	build = func(node *Node) *exportedNode {
+		n := e.newExportedNode(node)
+		for _, child := range e.children(root, node) {
+			n.Children = append(n.Children, build(child))
+		}
+		return n
+	}
+	return json.NewEncoder(w).Encode(build(root))
+}
+
+func (e *exporter) newExportedNode(node *Node) *exportedNode {
+	n := &exportedNode{Depth: node.depth}
+	if e.has(LabelAction) {
+		player, kind := node.action.player, node.action.kind
+		n.Player = &player
+		n.Kind = &kind
+	}
+	if e.has(LabelHands) {
+		for _, pokers := range node.state.pokers {
+			n.Hands = append(n.Hands, pokers.StringWithoutSuit())
+		}
+	}
+	if e.has(LabelN) {
+		v := node.n
+		n.N = &v
+	}
+	if e.has(LabelQ) {
+		v := node.q
+		n.Q = &v
+	}
+	if e.has(LabelP) {
+		v := node.p
+		n.P = &v
+	}
+	if e.has(LabelU) {
+		v := node.u
+		n.U = &v
+	}
+	return n
+}
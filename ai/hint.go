@@ -0,0 +1,280 @@
+package ai
+
+import (
+	"sort"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+// 拆牌代价
+const (
+	costBreakPair     = 1   // 拆对子
+	costBreakTrio     = 10  // 拆三张
+	costBreakAirplane = 10  // 拆飞机
+	costBreakBomb     = 100 // 拆炸弹
+	costBreakRocket   = 100 // 拆火箭
+)
+
+// 出牌提示: 返回手牌 hand 针对 lead 的建议出牌列表,玩家每次点击提示时依次循环这些建议
+//
+// 建议按拆牌代价从小到大排列,代价相同时较小的牌在前;
+// 炸弹和火箭排在普通牌型之后,拆炸弹或火箭的牌型又排在炸弹和火箭之后,跟牌时不出总是排在最后.
+// 如果 lead 是队友出的牌(fromTeammate),那么不出排在最前面并且不会建议炸弹和火箭.
+// lead 为空表示主动出牌,此时不会建议不出
+func Hint(hand PokerSet, lead Kind, fromTeammate bool, opt Options) []Kind {
+	var (
+		kinds  = hand.MatchAll(Kind{}, lead, opt)
+		costs  = make(map[kindKey]int, len(kinds))
+		plays  = make([]Kind, 0, len(kinds))
+		bombs  []Kind
+		pass   bool
+		trios  = airplaneValues(hand)
+		result = make([]Kind, 0, len(kinds))
+	)
+	for _, kind := range kinds {
+		switch {
+		case kind.Len() == 0:
+			pass = true
+		case kind.IsBomb() || kind.IsRocket():
+			if !fromTeammate || lead.Len() == 0 {
+				bombs = append(bombs, kind)
+			}
+		default:
+			costs[kind.key()] = breakCost(hand, kind, trios)
+			plays = append(plays, kind)
+		}
+	}
+	sort.SliceStable(plays, func(i, j int) bool {
+		a, b := plays[i], plays[j]
+		if ca, cb := costs[a.key()], costs[b.key()]; ca != cb {
+			return ca < cb
+		}
+		if a.minValue != b.minValue {
+			return a.minValue < b.minValue
+		}
+		if a.Len() != b.Len() {
+			return a.Len() > b.Len()
+		}
+		return a.Compare(b) < 0
+	})
+	SortKinds(bombs)
+
+	if pass && fromTeammate {
+		result = append(result, Kind{})
+	}
+	split := sort.Search(len(plays), func(i int) bool {
+		return costs[plays[i].key()] >= costBreakBomb
+	})
+	result = append(result, plays[:split]...)
+	result = append(result, bombs...)
+	result = append(result, plays[split:]...)
+	if pass && !fromTeammate {
+		result = append(result, Kind{})
+	}
+	return result
+}
+
+// 保守出牌: self 手牌 hand 针对 leader 出的 lead 的第一个出牌提示, 用于 AI 出错或者来不及思考时.
+// 没有需要管的牌时 leader 为 BadPosition
+func FirstHint(hand PokerSet, lead Kind, leader, landlord, self Position, opt Options) Kind {
+	fromTeammate := leader.Valid() && leader.IsFriend(landlord, self)
+	return Hint(hand, lead, fromTeammate, opt)[0]
+}
+
+// 总是出第一个出牌提示的 AI: 叫 3 分,不加倍. 不需要搜索并且结果是确定的,用于测试和作为基准对手
+type hintAI struct {
+	self, landlord Position
+	hand           PokerSet
+	lead           Kind
+	leader         Position
+	opt            Options
+}
+
+// 创建一个总是出第一个出牌提示的 AI, opt 为零值时使用 DefaultOptions
+func NewHintAI(opt Options) AI {
+	if opt == (Options{}) {
+		opt = DefaultOptions
+	}
+	return &hintAI{leader: BadPosition, opt: opt}
+}
+
+func (h *hintAI) SetLandlord(pos Position) { h.landlord = pos }
+func (h *hintAI) SetLastPokers(PokerSet)   {}
+func (h *hintAI) SetSelf(pos Position)     { h.self = pos }
+func (h *hintAI) Rob(Position, int)        {}
+func (h *hintAI) Double(Position, int)     {}
+func (h *hintAI) RecommendRob() int        { return 3 }
+func (h *hintAI) RecommendDouble() int     { return 0 }
+func (h *hintAI) Stop()                    {}
+
+func (h *hintAI) Start(pokers [NumPlayer]PokerSet) {
+	h.hand, h.lead, h.leader = pokers[h.self], Kind{}, BadPosition
+}
+
+func (h *hintAI) Play(tag string, pos Position, kind Kind) {
+	if kind.Len() > 0 {
+		h.lead, h.leader = kind, pos
+		if pos == h.self {
+			h.hand.Remove(kind.Pokers())
+		}
+	} else if pos.Next() == h.leader {
+		// 其他两家都不出,重新主动出牌
+		h.lead, h.leader = Kind{}, BadPosition
+	}
+}
+
+func (h *hintAI) RecommendPlay(tag string) Kind {
+	return FirstHint(h.hand, h.lead, h.leader, h.landlord, h.self, h.opt)
+}
+
+// 计算出牌 kind 对手牌 hand 的拆牌代价
+func breakCost(hand PokerSet, kind Kind, airplanes PokerSet) int {
+	var (
+		cost   int
+		pokers = kind.Pokers()
+	)
+	pokers.WalkBlock(func(value poker.Value, block Block) bool {
+		used := block.Len()
+		if used == 0 {
+			return false
+		}
+		have := hand.Count(value)
+		if used < have {
+			switch have {
+			case 4:
+				cost += costBreakBomb
+			case 3:
+				cost += costBreakTrio
+			case 2:
+				cost += costBreakPair
+			}
+		}
+		if airplanes.Count(value) > 0 && !(kind.height == 3 && kind.width > 1) {
+			cost += costBreakAirplane
+		}
+		return false
+	})
+	if hand.Contains(rocket) && pokers&rocket != 0 {
+		cost += costBreakRocket
+	}
+	return cost
+}
+
+// 手牌中能组成飞机(2 连及以上的三张)的牌
+func airplaneValues(hand PokerSet) PokerSet {
+	var ret PokerSet
+	for value := minPokerValue; value < poker.PM2; value++ {
+		if hand.Count(value) != 3 {
+			continue
+		}
+		if (value > minPokerValue && hand.Count(value-1) == 3) ||
+			(value+1 < poker.PM2 && hand.Count(value+1) == 3) {
+			ret.AddByValue(hand, value, 3)
+		}
+	}
+	return ret
+}
+
+// 智能选牌: 玩家选中部分手牌后,返回包含这些牌的合法出牌,按玩家可能的意图排列
+//
+// 排序时依次考虑: 拆牌代价小的在前,主干的牌少的在前,能带牌时带牌的在前(比如选中 3 时 3334 在 333 之前),
+// 补充的牌少的在前,最小牌值接近选中牌中最小值的在前,带的牌小的在前.
+// 返回的牌型使用手牌中实际的牌,并且一定包含选中的牌; selected 不是 hand 的子集时返回空
+func Complete(hand, selected PokerSet, lead Kind, opt Options) []Kind {
+	if selected.Empty() || !hand.Contains(selected) {
+		return nil
+	}
+	var (
+		trios    = airplaneValues(hand)
+		minValue = selected.MinValue()
+		result   []Kind
+		costs    = make(map[kindKey]int)
+	)
+	hand.WalkMatch(Kind{}, lead, opt, func(kind Kind) bool {
+		if kind.Len() == 0 {
+			return false
+		}
+		if realized, ok := kind.realize(hand, selected); ok {
+			costs[realized.key()] = breakCost(hand, realized, trios)
+			result = append(result, realized)
+		}
+		return false
+	})
+	distance := func(kind Kind) int {
+		if kind.minValue > minValue {
+			return int(kind.minValue - minValue)
+		}
+		return int(minValue - kind.minValue)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if ca, cb := costs[a.key()], costs[b.key()]; ca != cb {
+			return ca < cb
+		}
+		if la, lb := a.body.Len(), b.body.Len(); la != lb {
+			return la < lb
+		}
+		if ka, kb := a.kicker.Len() > 0, b.kicker.Len() > 0; ka != kb {
+			return ka
+		}
+		if a.Len() != b.Len() {
+			return a.Len() < b.Len()
+		}
+		if da, db := distance(a), distance(b); da != db {
+			return da < db
+		}
+		if ka, kb := a.kicker.MinValue(), b.kicker.MinValue(); ka != kb {
+			return ka < kb
+		}
+		return a.Compare(b) < 0
+	})
+	return result
+}
+
+// 使用手牌 hand 中实际的牌构造牌型,优先使用 preferred 中的牌,
+// 如果构造的牌型不能包含 preferred 中所有的牌则返回 false
+func (kind Kind) realize(hand, preferred PokerSet) (Kind, bool) {
+	var (
+		body   PokerSet
+		kicker PokerSet
+		ok     = true
+	)
+	kind.Pokers().WalkBlock(func(value poker.Value, block Block) bool {
+		need := block.Len()
+		if need == 0 {
+			if preferred.Count(value) > 0 {
+				ok = false
+				return true
+			}
+			return false
+		}
+		if preferred.Count(value) > need {
+			ok = false
+			return true
+		}
+		var chosen PokerSet
+		chosen.AddByValue(preferred, value, preferred.Count(value))
+		if rest := need - chosen.Len(); rest > 0 {
+			if chosen.AddByValue(hand, value, rest).Empty() {
+				ok = false
+				return true
+			}
+		}
+		// 主干部分先取,剩下的作为带牌
+		n := kind.body.Count(value)
+		chosen.Walk(func(p poker.Poker) bool {
+			if n > 0 {
+				body.Add(NewPokerSetWithPoker(p))
+				n--
+			} else {
+				kicker.Add(NewPokerSetWithPoker(p))
+			}
+			return false
+		})
+		return false
+	})
+	if !ok {
+		return Kind{}, false
+	}
+	return kind.extend(body, kicker), true
+}
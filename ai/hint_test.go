@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+func TestHint(t *testing.T) {
+	opt := DefaultOptions
+	// 炸弹 6666, 飞机 777888
+	hand := mustParsePokerSet(t, "6666 777 888 99 X K")
+	lead := mustClassify(t, poker.Single1, opt, "5")
+
+	hints := Hint(hand, lead, false, opt)
+	expected := []poker.Value{poker.P10, poker.PK, poker.P9}
+	for i, value := range expected {
+		if hints[i].Type() != poker.Single1 || hints[i].minValue != value {
+			t.Fatalf("hint %d: expected single %v, got %v (all: %v)", i, value, hints[i], hints)
+		}
+	}
+	if hints[len(hints)-1].Len() != 0 {
+		t.Fatalf("expected pass at the end: %v", hints)
+	}
+	bomb := -1
+	for i, kind := range hints {
+		if kind.IsBomb() {
+			bomb = i
+		} else if kind.minValue == poker.P6 && bomb < 0 {
+			t.Fatalf("hint %v breaks bomb before using the bomb: %v", kind, hints)
+		}
+	}
+	if bomb < 0 {
+		t.Fatalf("bomb missing: %v", hints)
+	}
+
+	again := Hint(hand, lead, false, opt)
+	for i := range hints {
+		if hints[i] != again[i] {
+			t.Fatalf("nondeterministic hints: %v vs %v", hints, again)
+		}
+	}
+
+	teammate := Hint(hand, lead, true, opt)
+	if teammate[0].Len() != 0 {
+		t.Fatalf("pass should come first on teammate's lead: %v", teammate)
+	}
+	for _, kind := range teammate {
+		if kind.IsBomb() || kind.IsRocket() {
+			t.Fatalf("bomb suggested on teammate's lead: %v", teammate)
+		}
+	}
+
+	leading := Hint(hand, Kind{}, false, opt)
+	if len(leading) == 0 || leading[0].Len() == 0 {
+		t.Fatalf("bad leading hints: %v", leading)
+	}
+	for _, kind := range leading {
+		if kind.Len() == 0 {
+			t.Fatalf("pass suggested when leading")
+		}
+	}
+	if c := breakCost(hand, leading[0], airplaneValues(hand)); c != 0 {
+		t.Fatalf("first leading hint %v breaks cards, cost %d", leading[0], c)
+	}
+
+	// 农民 1 跟农民 2 的牌时不出,跟地主 0 的牌时出最小的单张
+	if kind := FirstHint(hand, lead, 2, 0, 1, opt); kind.Len() != 0 {
+		t.Fatalf("first hint on teammate's lead should be pass, got %v", kind)
+	}
+	if kind := FirstHint(hand, lead, 0, 0, 1, opt); !kind.Equal(hints[0]) {
+		t.Fatalf("first hint on landlord's lead should be %v, got %v", hints[0], kind)
+	}
+	if kind := FirstHint(hand, Kind{}, BadPosition, 0, 1, opt); !kind.Equal(leading[0]) {
+		t.Fatalf("first leading hint should be %v, got %v", leading[0], kind)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	opt := DefaultOptions
+	heart3 := NewPokerSetWithPoker(poker.NewPoker(poker.Heart, poker.P3))
+	hand := mustParsePokerSet(t, "333 4 9 K")
+
+	// 主动出牌时选中 3 补全为三带一 3334, 然后是带更大单张的三带一和不带的 333
+	kinds := Complete(hand, heart3, Kind{}, opt)
+	if len(kinds) < 4 || kinds[0].Type() != poker.ThreeSingle1 || kinds[0].Pokers() != mustParsePokerSet(t, "333 4") ||
+		kinds[1].Type() != poker.ThreeSingle1 || kinds[2].Type() != poker.ThreeSingle1 || kinds[3].Type() != poker.Three1 {
+		t.Fatalf("expected 3334 first and 333 after trios with kickers, got %v", kinds)
+	}
+	for _, kind := range kinds {
+		if !kind.Pokers().Contains(heart3) || !hand.Contains(kind.Pokers()) {
+			t.Fatalf("bad completion %v", kind)
+		}
+		if !kind.Valid(opt) {
+			t.Fatalf("invalid completion %v", kind)
+		}
+	}
+
+	// 跟顺子时选中 5 自动选出 5-9 的顺子
+	lead := mustClassify(t, poker.Single5, opt, "3 4 5 6 7")
+	hand = mustParsePokerSet(t, "5 6 7 8 9 X K")
+	five := mustParsePokerSet(t, "5")
+	kinds = Complete(hand, five, lead, opt)
+	if len(kinds) != 1 || kinds[0].Type() != poker.Single5 || kinds[0].minValue != poker.P5 {
+		t.Fatalf("expected straight 5-9, got %v", kinds)
+	}
+
+	if kinds := Complete(hand, mustParsePokerSet(t, "K"), lead, opt); len(kinds) != 0 {
+		t.Fatalf("K can't be in any play beating %v, got %v", lead, kinds)
+	}
+	if kinds := Complete(hand, mustParsePokerSet(t, "3"), lead, opt); kinds != nil {
+		t.Fatalf("selection not in hand, got %v", kinds)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/gopherd/log"
@@ -16,13 +17,51 @@ func (d Duration) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("%q", time.Duration(d).String())), nil
 }
 
-type runStats struct {
-	NumNewNodes     int64
-	NumTraverseNode int64
-	TimeOfTraverse  Duration
-	TimeOfExpand    Duration
-	TimeOfRollout   Duration
-	TimeOfBackup    Duration
+// 搜索过程统计数据
+type SearchStats struct {
+	// 迭代次数
+	NumIterations int64 `json:"num_iterations"`
+	// 扩展时新建的节点数
+	NumNewNodes int64 `json:"num_new_nodes"`
+	// 选择阶段遍历的节点数
+	NumTraverseNode int64 `json:"num_traverse_node"`
+	// 搜索结束时整棵树的节点数
+	NumTreeNodes int64 `json:"num_tree_nodes"`
+	// 各阶段耗时
+	TimeOfTraverse Duration `json:"time_of_traverse"`
+	TimeOfExpand   Duration `json:"time_of_expand"`
+	TimeOfRollout  Duration `json:"time_of_rollout"`
+	TimeOfBackup   Duration `json:"time_of_backup"`
+	// 总耗时
+	TimeOfSearch Duration `json:"time_of_search"`
+}
+
+// 根节点的一个候选动作
+type Candidate struct {
+	// 候选动作
+	Action Action `json:"action"`
+	// 访问次数
+	Visits float64 `json:"visits"`
+	// 平均收益,以根节点出牌玩家的视角计算
+	Value float64 `json:"value"`
+	// 先验概率
+	Prior float64 `json:"prior"`
+	// 置信上限
+	Bonus float64 `json:"bonus"`
+}
+
+// 搜索结果
+type SearchResult struct {
+	// 选中的子节点
+	Best *Node `json:"-"`
+	// 根节点所有候选动作,按访问次数从大到小排列
+	Candidates []Candidate `json:"candidates"`
+	// 主要变例(principal variation): 从根节点开始每层选择访问次数最多的子节点形成的动作序列
+	PV []Action `json:"pv"`
+	// 统计数据
+	Stats SearchStats `json:"stats"`
+	// 是否没有经过搜索直接决定的出牌(见 RecommendPlay), 此时只有一个候选动作
+	Shortcut bool `json:"shortcut,omitempty"`
 }
 
 // 蒙特卡洛搜索树(MCTS)状态节点
@@ -45,6 +84,25 @@ type Node struct {
 	p float64 // 先验概率(priori probability)
 }
 
+func (node *Node) Parent() *Node     { return node.parent }
+func (node *Node) Children() []*Node { return node.children }
+func (node *Node) State() State      { return node.state }
+func (node *Node) Action() Action    { return node.action }
+func (node *Node) Depth() int        { return node.depth }
+func (node *Node) N() float64        { return node.n }
+func (node *Node) Q() float64        { return node.q }
+func (node *Node) U() float64        { return node.u }
+func (node *Node) P() float64        { return node.p }
+
+// 计算以当前节点为根的子树节点数
+func (node *Node) Size() int64 {
+	var size int64 = 1
+	for _, child := range node.children {
+		size += child.Size()
+	}
+	return size
+}
+
 // 创建节点
 // 创建根节点时, parent 和 action 为空即可
 func NewNode(parent *Node, action Action, state State) *Node {
@@ -99,60 +157,136 @@ func (node *Node) clone() *Node {
 
 // 执行蒙特卡洛树搜索(MCTS)
 func (node *Node) Search(policyFn PolicyFunc, rolloutFn RolloutFunc, alpha, cparam float64, maxcnt int) *Node {
+	return node.SearchWithResult(policyFn, rolloutFn, alpha, cparam, maxcnt).Best
+}
+
+// 执行蒙特卡洛树搜索(MCTS)并返回搜索结果
+func (node *Node) SearchWithResult(policyFn PolicyFunc, rolloutFn RolloutFunc, alpha, cparam float64, maxcnt int) SearchResult {
+	return node.SearchWithRand(nil, policyFn, rolloutFn, alpha, cparam, maxcnt)
+}
+
+// 使用随机数 r 执行蒙特卡洛树搜索(MCTS)并返回搜索结果, r 为空时使用全局随机数.
+// 策略函数和推演函数使用的随机数由调用者决定
+func (node *Node) SearchWithRand(r *rand.Rand, policyFn PolicyFunc, rolloutFn RolloutFunc, alpha, cparam float64, maxcnt int) SearchResult {
 	// 在搜索次数和搜索时间限制下执行蒙特卡洛树搜索
 	var (
-		stats = runStats{}
-		begin = time.Now()
-		now   time.Time
+		stats = SearchStats{}
+		start = time.Now()
 	)
 	for i := 0; i < maxcnt; i++ {
-		// Select:
-		// 从当前根节点延伸到叶子节点
-		// 每次向下延伸时使用 q+u 最大的子节点
-		leaf := node.traverse()
+		node.iterate(r, policyFn, rolloutFn, alpha, cparam, &stats)
+	}
+	stats.TimeOfSearch = Duration(time.Since(start))
+	stats.NumTreeNodes = node.Size()
+	log.Debug().Any("stats", stats).Print("mcts Search stats")
+
+	result := node.result(r)
+	result.Stats = stats
+	return result
+}
 
-		now = time.Now()
-		stats.TimeOfTraverse += Duration(now.Sub(begin))
-		begin = now
+// 执行一次搜索迭代: 选择,扩展和估值,反向更新,并累计统计数据
+func (node *Node) iterate(r *rand.Rand, policyFn PolicyFunc, rolloutFn RolloutFunc, alpha, cparam float64, stats *SearchStats) {
+	var (
+		begin  = time.Now()
+		now    time.Time
+		player = node.action.player.Next()
+	)
+	stats.NumIterations++
 
-		// Expand and evaluate
-		var value1 float64
-		leaf, value1 = leaf.expand(policyFn)
+	// Select:
+	// 从当前根节点延伸到叶子节点
+	// 每次向下延伸时使用 q+u 最大的子节点
+	leaf := node.traverse()
+	stats.NumTraverseNode += int64(leaf.depth - node.depth)
 
-		now = time.Now()
-		stats.TimeOfExpand += Duration(now.Sub(begin))
-		begin = now
+	now = time.Now()
+	stats.TimeOfTraverse += Duration(now.Sub(begin))
+	begin = now
+
+	// Expand and evaluate
+	// 策略函数的估值以 leaf 之后出牌玩家的视角计算,需要转换成根节点之后出牌玩家的视角
+	numChildren := len(leaf.children)
+	expanded, value1 := leaf.expand(policyFn, r)
+	if !leaf.action.player.Next().IsFriend(leaf.state.landlord, player) {
+		value1 = -value1
+	}
+	stats.NumNewNodes += int64(len(leaf.children) - numChildren)
+	leaf = expanded
 
+	now = time.Now()
+	stats.TimeOfExpand += Duration(now.Sub(begin))
+	begin = now
+
+	var value float64
+	if leaf.state.Gameover() {
+		value = leaf.state.score(player)
+	} else {
 		var value2 float64
 		if rolloutFn != nil {
 			value2 = rolloutFn(node, leaf)
 		}
-		value := alpha*value2 + (1-alpha)*value1
+		value = alpha*value2 + (1-alpha)*value1
+	}
 
-		now = time.Now()
-		stats.TimeOfRollout += Duration(now.Sub(begin))
-		begin = now
+	now = time.Now()
+	stats.TimeOfRollout += Duration(now.Sub(begin))
+	begin = now
 
-		// Backup
-		leaf.backup(node, value, cparam)
+	// Backup
+	leaf.backup(node, value, cparam)
+
+	stats.TimeOfBackup += Duration(time.Since(begin))
+}
 
-		now = time.Now()
-		stats.TimeOfBackup += Duration(now.Sub(begin))
-		begin = now
+// 汇总根节点的搜索结果
+func (node *Node) result(r *rand.Rand) SearchResult {
+	var result SearchResult
+	if len(node.children) == 0 {
+		return result
 	}
-	log.Debug().Any("stats", stats).Print("mcts Search stats")
 
 	// 选择访问次数最多的子节点做最优解
 	maxi := 0
 	maxn := float64(0)
 	for i, child := range node.children {
-		n := child.n + rand.Float64()
+		n := child.n + float64n(r)
 		if i == 0 || n > maxn {
 			maxi = i
 			maxn = n
 		}
 	}
-	return node.children[maxi]
+	result.Best = node.children[maxi]
+
+	result.Candidates = make([]Candidate, 0, len(node.children))
+	for _, child := range node.children {
+		result.Candidates = append(result.Candidates, Candidate{
+			Action: child.action,
+			Visits: child.n,
+			Value:  child.q,
+			Prior:  child.p,
+			Bonus:  child.u,
+		})
+	}
+	sort.SliceStable(result.Candidates, func(i, j int) bool {
+		return result.Candidates[i].Visits > result.Candidates[j].Visits
+	})
+
+	for curr := result.Best; curr != nil; curr = curr.mostVisitedChild() {
+		result.PV = append(result.PV, curr.action)
+	}
+	return result
+}
+
+// 访问次数最多的子节点,没有访问过的子节点时返回 nil
+func (node *Node) mostVisitedChild() *Node {
+	var best *Node
+	for _, child := range node.children {
+		if child.n >= 1 && (best == nil || child.n > best.n) {
+			best = child
+		}
+	}
+	return best
 }
 
 // 节点推进
@@ -228,7 +362,12 @@ func (node *Node) next(player Position) *Node {
 }
 
 // 使用给定策略扩展当前节点的子节点
-func (node *Node) expand(policyFn PolicyFunc) (*Node, float64) {
+func (node *Node) expand(policyFn PolicyFunc, r *rand.Rand) (*Node, float64) {
+	// 游戏已经结束的节点不再扩展: 之后出牌的玩家虽然还有合法出牌,但继续扩展会搜索到牌局结束之后,
+	// 所以直接返回该节点由推演估值
+	if node.state.Gameover() {
+		return node, 0
+	}
 	if len(node.children) == 0 {
 		var (
 			actions, value, _ = policyFn(node)
@@ -240,7 +379,7 @@ func (node *Node) expand(policyFn PolicyFunc) (*Node, float64) {
 			child := NewNode(node, action, action.Do(node.state))
 			node.children = append(node.children, child)
 		}
-		return node.children[rand.Intn(len(node.children))], value
+		return node.children[intn(r, len(node.children))], value
 	}
 
 	totalUnvisited := 0
@@ -250,9 +389,9 @@ func (node *Node) expand(policyFn PolicyFunc) (*Node, float64) {
 		}
 	}
 	if totalUnvisited == 0 {
-		return node.children[rand.Intn(len(node.children))], 0
+		return node.children[intn(r, len(node.children))], 0
 	} else {
-		selected := rand.Intn(totalUnvisited)
+		selected := intn(r, totalUnvisited)
 		tmp := 0
 		index := 0
 		for i, child := range node.children {
@@ -272,19 +411,23 @@ func (node *Node) expand(policyFn PolicyFunc) (*Node, float64) {
 func (node *Node) backup(root *Node, value, cparam float64) {
 	curr := node
 	for curr != nil && curr != root {
-		curr.update(value, cparam, false, curr.depth-root.depth)
+		curr.update(value, cparam)
 		curr = curr.parent
 	}
 	if root != nil {
-		root.update(value, cparam, true, 0)
+		root.update(value, cparam)
 	}
 }
 
-// 更新节点统计数据(n,q,u)
-func (node *Node) update(value, cparam float64, isRoot bool, depth int) {
+// 更新节点统计数据(n,q)和所有子节点的置信上限(u)
+func (node *Node) update(value, cparam float64) {
 	node.n += 1
 	node.q += (value - node.q) / node.n
-	if !isRoot && node.parent != nil {
-		node.u = cparam * node.p * math.Sqrt(node.parent.n) / (1 + node.n)
+	// 子节点的置信上限 u = c*p*sqrt(N)/(1+n) 依赖父节点的访问次数 N. 只在子节点自己被访问时更新的话,
+	// 没有被访问的兄弟节点的 u 会停留在旧的 N 上,越少被访问的节点越被低估,选择时就很难再被探索.
+	// 所以父节点更新后同时刷新所有子节点
+	sqrtn := math.Sqrt(node.n)
+	for _, child := range node.children {
+		child.u = cparam * child.p * sqrtn / (1 + child.n)
 	}
 }
@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"math"
+	"testing"
+)
+
+// 父节点更新后刷新所有子节点的置信上限,包括没有被访问的兄弟节点
+func TestUpdateRefreshesSiblings(t *testing.T) {
+	const cparam = 30
+	root := newTestRoot()
+	for i := 0; i < 2; i++ {
+		action := Action{player: root.action.player.Next(), prob: 0.5}
+		root.children = append(root.children, NewNode(root, action, root.state))
+	}
+	visited, sibling := root.children[0], root.children[1]
+	for i := 0; i < 3; i++ {
+		visited.backup(root, 1, cparam)
+	}
+	if root.n != 3 || visited.n != 3 || sibling.n != 0 {
+		t.Fatalf("bad visits: root %v, visited %v, sibling %v", root.n, visited.n, sibling.n)
+	}
+	if u := cparam * 0.5 * math.Sqrt(3); math.Abs(sibling.u-u) > 1e-9 {
+		t.Fatalf("sibling u should be %v, got %v", u, sibling.u)
+	}
+	if u := cparam * 0.5 * math.Sqrt(3) / 4; math.Abs(visited.u-u) > 1e-9 {
+		t.Fatalf("visited u should be %v, got %v", u, visited.u)
+	}
+}
+
+// 游戏结束的节点不再扩展
+func TestExpandGameover(t *testing.T) {
+	var pokers [NumPlayer]PokerSet
+	pokers[1] = mustParsePokerSet(t, "3 4")
+	pokers[2] = mustParsePokerSet(t, "5 6")
+	node := new(Node)
+	node.state = NewState(pokers, 0)
+	node.action.player = 0
+	expanded, value := node.expand(getLegalActions, nil)
+	if expanded != node || value != 0 || len(node.children) != 0 {
+		t.Fatalf("gameover node should not be expanded, got %d children", len(node.children))
+	}
+}
@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+// 扑克牌文本表示
+//
+// 每个字符表示一张牌: 3~9, X(或 T, 10) 表示 10, J, Q, K, A, 2, # 表示小王, $ 表示大王,
+// 字母不区分大小写,空白,逗号和方括号会被忽略.
+// 牌值前可以加花色符号(♠,♥,♣,♦)指定花色,否则按黑桃,红桃,梅花,方块的顺序使用还没用过的花色.
+// 例如 "333 44 X J Q K A 2 $" 或 "♥3♠3"
+
+// 解析扑克牌文本表示
+func ParsePokerSet(s string) (PokerSet, error) {
+	return parsePokerSet(s, emptyPokerSet)
+}
+
+// 解析扑克牌文本表示,未指定花色的牌不会使用 used 中的牌
+func parsePokerSet(s string, used PokerSet) (PokerSet, error) {
+	var (
+		pset  PokerSet
+		runes = []rune(s)
+		suit  = -1
+	)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if unicode.IsSpace(r) || r == ',' || r == '[' || r == ']' {
+			continue
+		}
+		switch r {
+		case '♠':
+			suit = int(poker.Spade)
+			continue
+		case '♥':
+			suit = int(poker.Heart)
+			continue
+		case '♣':
+			suit = int(poker.Club)
+			continue
+		case '♦':
+			suit = int(poker.Diamond)
+			continue
+		}
+		var value poker.Value
+		switch unicode.ToUpper(r) {
+		case '3', '4', '5', '6', '7', '8', '9':
+			value = poker.Value(r - '0')
+		case '1':
+			if i+1 < len(runes) && runes[i+1] == '0' {
+				i++
+				value = poker.P10
+			}
+		case 'X', 'T':
+			value = poker.P10
+		case 'J':
+			value = poker.PJ
+		case 'Q':
+			value = poker.PQ
+		case 'K':
+			value = poker.PK
+		case 'A':
+			value = poker.PMA
+		case '2':
+			value = poker.PM2
+		case '#':
+			value = poker.PJoker1
+		case '$':
+			value = poker.PJoker2
+		}
+		if value == poker.InvalidPokerValue {
+			return pset, fmt.Errorf("ai: invalid poker %q in %q", r, s)
+		}
+		var added PokerSet
+		if suit >= 0 {
+			if value >= poker.PJoker1 {
+				return pset, fmt.Errorf("ai: joker with suit in %q", s)
+			}
+			added = NewPokerSetWithPoker(poker.NewPoker(poker.Suit(suit), value))
+			if pset.Contains(added) {
+				added = emptyPokerSet
+			}
+			suit = -1
+		} else {
+			added = added.AddByValue(FullDeck&^(pset|used), value, 1)
+		}
+		if added.Empty() {
+			return pset, fmt.Errorf("ai: too many %v in %q", value, s)
+		}
+		pset.Add(added)
+	}
+	if suit >= 0 {
+		return pset, fmt.Errorf("ai: suit without value in %q", s)
+	}
+	return pset, nil
+}
+
+// 输出扑克牌文本表示(不含花色),结果可以被 ParsePokerSet 解析
+func (pset PokerSet) Notation() string {
+	var buf bytes.Buffer
+	pset.Walk(func(p poker.Poker) bool {
+		buf.WriteString(p.Value().String())
+		return false
+	})
+	return buf.String()
+}
+
+// 使用文本表示从手牌中选出要出的牌,不指定花色的牌按手牌中的顺序选取
+func (pset PokerSet) Select(s string) (PokerSet, error) {
+	if strings.ContainsAny(s, "♠♥♣♦") {
+		selected, err := ParsePokerSet(s)
+		if err != nil {
+			return emptyPokerSet, err
+		}
+		if !pset.Contains(selected) {
+			return emptyPokerSet, fmt.Errorf("ai: %v not in %v", selected, pset)
+		}
+		return selected, nil
+	}
+	values, err := ParsePokerSet(s)
+	if err != nil {
+		return emptyPokerSet, err
+	}
+	selected := pset.Find(values)
+	if selected.Len() != values.Len() {
+		return emptyPokerSet, fmt.Errorf("ai: %s not in %s", values.Notation(), pset.Notation())
+	}
+	return selected, nil
+}
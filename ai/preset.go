@@ -0,0 +1,304 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidOptions = errors.New("ai: invalid options")
+
+// 检查规则是否合法,矛盾的规则组合会返回 ErrInvalidOptions
+func (opt Options) Validate() error {
+	var reasons []string
+	if opt.MultipleOfBomb < 1 {
+		reasons = append(reasons, "multiple_of_bomb must be at least 1")
+	}
+	if opt.MultipleOfRocket < 1 {
+		reasons = append(reasons, "multiple_of_rocket must be at least 1")
+	}
+	if opt.MultipleOfSpring < 1 {
+		reasons = append(reasons, "multiple_of_spring must be at least 1")
+	}
+	if opt.MaxMultiple < 0 {
+		reasons = append(reasons, "max_multiple must not be negative")
+	}
+	if opt.MinLengthOfChain < 3 || opt.MinLengthOfChain > 12 {
+		reasons = append(reasons, "min_length_of_chain must be in [3, 12]")
+	}
+	if opt.MinLengthOfPairChain < 2 || opt.MinLengthOfPairChain > 10 {
+		reasons = append(reasons, "min_length_of_pair_chain must be in [2, 10]")
+	}
+	if opt.NoKicker {
+		if !opt.CanTrioWithoutKicker {
+			reasons = append(reasons, "no_kicker requires can_trio_without_kicker, otherwise trios can never be played")
+		}
+		if opt.CanTrioWithPair || opt.CanFourTwoWithKickers || opt.CanKickerInBody ||
+			opt.CanRepeatKicker || opt.CanJokerAsKicker {
+			reasons = append(reasons, "no_kicker conflicts with kicker rules")
+		}
+	}
+	if len(reasons) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+// 计算出牌过程的倍数(不含叫分和加倍): 炸弹,火箭和春天按规则累乘,超过封顶倍数时取封顶倍数
+func (opt Options) Multiple(numBombs, numRockets int, spring bool) int {
+	multi := 1
+	capped := func() bool { return opt.MaxMultiple > 0 && multi >= opt.MaxMultiple }
+	for i := 0; i < numBombs && !capped(); i++ {
+		multi *= opt.MultipleOfBomb
+	}
+	for i := 0; i < numRockets && !capped(); i++ {
+		multi *= opt.MultipleOfRocket
+	}
+	if spring && !capped() {
+		multi *= opt.MultipleOfSpring
+	}
+	if capped() {
+		multi = opt.MaxMultiple
+	}
+	return multi
+}
+
+// 规则预设
+type Preset struct {
+	// 预设名称,如 classic
+	Name string `json:"name"`
+	// 预设说明
+	Description string `json:"description"`
+	// 规则
+	Options Options `json:"options"`
+}
+
+// 内置规则预设
+var (
+	// 经典玩法: 三带一,三带对,四带二,飞机均可,炸弹火箭春天均翻倍,不封顶
+	PresetClassic = Preset{
+		Name:        "classic",
+		Description: "经典玩法,可三带一/三带对/四带二,炸弹、火箭、春天翻倍,不封顶",
+		Options:     DefaultOptions,
+	}
+
+	// 欢乐玩法: 在经典玩法基础上允许航天飞机和带王,倍数封顶 256 倍
+	PresetHappy = Preset{
+		Name:        "happy",
+		Description: "欢乐玩法,在经典玩法基础上允许航天飞机和带王,倍数封顶 256 倍",
+		Options: Options{
+			CanTrioWithPair:       true,
+			CanFourTwoWithKickers: true,
+			CanKickerInBody:       true,
+			CanTrioWithoutKicker:  true,
+			CanSpaceShuttle:       true,
+			CanRepeatKicker:       true,
+			CanJokerAsKicker:      true,
+			MultipleOfBomb:        2,
+			MultipleOfRocket:      2,
+			MultipleOfSpring:      2,
+			MaxMultiple:           256,
+			MinLengthOfChain:      5,
+			MinLengthOfPairChain:  3,
+		},
+	}
+
+	// 竞技玩法: 带牌不能与主体相同,不能重复带牌,不能带王,四个 2 不能带牌,倍数封顶 64 倍
+	PresetCompetition = Preset{
+		Name:        "competition",
+		Description: "竞技玩法,带牌不能与主体相同且不能重复,不能带王,四个 2 不能带牌,倍数封顶 64 倍",
+		Options: Options{
+			CanTrioWithPair:       true,
+			CanFourTwoWithKickers: false,
+			CanKickerInBody:       false,
+			CanTrioWithoutKicker:  true,
+			CanSpaceShuttle:       false,
+			CanRepeatKicker:       false,
+			CanJokerAsKicker:      false,
+			MultipleOfBomb:        2,
+			MultipleOfRocket:      2,
+			MultipleOfSpring:      2,
+			MaxMultiple:           64,
+			MinLengthOfChain:      5,
+			MinLengthOfPairChain:  3,
+		},
+	}
+
+	// 休闲不带牌玩法: 所有牌型都不能带牌,顺子 3 张起,连对 2 对起,倍数封顶 16 倍
+	PresetCasual = Preset{
+		Name:        "casual",
+		Description: "休闲不带牌玩法,所有牌型都不能带牌,顺子 3 张起,连对 2 对起,倍数封顶 16 倍",
+		Options: Options{
+			CanTrioWithoutKicker: true,
+			CanSpaceShuttle:      true,
+			NoKicker:             true,
+			MultipleOfBomb:       2,
+			MultipleOfRocket:     2,
+			MultipleOfSpring:     2,
+			MaxMultiple:          16,
+			MinLengthOfChain:     3,
+			MinLengthOfPairChain: 2,
+		},
+	}
+)
+
+var presets = struct {
+	sync.RWMutex
+	m map[string]Preset
+}{
+	m: map[string]Preset{
+		PresetClassic.Name:     PresetClassic,
+		PresetHappy.Name:       PresetHappy,
+		PresetCompetition.Name: PresetCompetition,
+		PresetCasual.Name:      PresetCasual,
+	},
+}
+
+// 注册规则预设,同名的预设会被覆盖
+func RegisterPreset(preset Preset) error {
+	return registerPresets([]Preset{preset})
+}
+
+// 注册一组规则预设: 全部合法时才注册,否则一个也不注册
+func registerPresets(list []Preset) error {
+	for _, preset := range list {
+		if preset.Name == "" {
+			return fmt.Errorf("%w: preset name is empty", ErrInvalidOptions)
+		}
+		if err := preset.Options.Validate(); err != nil {
+			return fmt.Errorf("preset %q: %w", preset.Name, err)
+		}
+	}
+	presets.Lock()
+	defer presets.Unlock()
+	for _, preset := range list {
+		presets.m[preset.Name] = preset
+	}
+	return nil
+}
+
+// 按名称查找规则预设
+func LookupPreset(name string) (Preset, bool) {
+	presets.RLock()
+	defer presets.RUnlock()
+	preset, ok := presets.m[name]
+	return preset, ok
+}
+
+// 获取所有规则预设,按名称排列
+func Presets() []Preset {
+	presets.RLock()
+	defer presets.RUnlock()
+	ret := make([]Preset, 0, len(presets.m))
+	for _, preset := range presets.m {
+		ret = append(ret, preset)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// 预设文件中的一项,可以通过 base 继承已有的预设,options 中只需要写出不同的规则
+type presetEntry struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Base        string          `json:"base"`
+	Options     json.RawMessage `json:"options"`
+}
+
+// 从 JSON 数组读取规则预设并验证,文件中靠后的预设可以继承靠前的预设
+//
+//	[
+//		{"name": "house", "base": "classic", "options": {"max_multiple": 32}}
+//	]
+func LoadPresets(r io.Reader) ([]Preset, error) {
+	var entries []presetEntry
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return nil, err
+	}
+	return resolvePresets(entries)
+}
+
+// 从 YAML 读取规则预设,字段名与 JSON 相同
+func LoadPresetsYAML(r io.Reader) ([]Preset, error) {
+	var v interface{}
+	if err := yaml.NewDecoder(r).Decode(&v); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return LoadPresets(bytes.NewReader(data))
+}
+
+// 根据扩展名(.json, .yaml, .yml)读取规则预设文件并注册所有预设,有不合法的预设时一个也不注册
+func LoadPresetsFile(filename string) ([]Preset, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var list []Preset
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		list, err = LoadPresets(f)
+	case ".yaml", ".yml":
+		list, err = LoadPresetsYAML(f)
+	default:
+		return nil, fmt.Errorf("ai: unsupported preset file extension %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load presets from %s: %w", filename, err)
+	}
+	if err := registerPresets(list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func resolvePresets(entries []presetEntry) ([]Preset, error) {
+	var (
+		ret    = make([]Preset, 0, len(entries))
+		loaded = make(map[string]Preset)
+	)
+	for _, entry := range entries {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("%w: preset name is empty", ErrInvalidOptions)
+		}
+		preset := Preset{Name: entry.Name, Description: entry.Description}
+		if entry.Base != "" {
+			base, ok := loaded[entry.Base]
+			if !ok {
+				base, ok = LookupPreset(entry.Base)
+			}
+			if !ok {
+				return nil, fmt.Errorf("preset %q: unknown base preset %q", entry.Name, entry.Base)
+			}
+			preset.Options = base.Options
+		}
+		if len(entry.Options) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(entry.Options))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&preset.Options); err != nil {
+				return nil, fmt.Errorf("preset %q: %w", entry.Name, err)
+			}
+		}
+		if err := preset.Options.Validate(); err != nil {
+			return nil, fmt.Errorf("preset %q: %w", entry.Name, err)
+		}
+		loaded[preset.Name] = preset
+		ret = append(ret, preset)
+	}
+	return ret, nil
+}
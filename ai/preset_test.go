@@ -0,0 +1,142 @@
+package ai
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	names := []string{"casual", "classic", "competition", "happy"}
+	list := Presets()
+	if len(list) < len(names) {
+		t.Fatalf("expected at least %d presets, got %d", len(names), len(list))
+	}
+	for _, name := range names {
+		preset, ok := LookupPreset(name)
+		if !ok {
+			t.Fatalf("preset %q not found", name)
+		}
+		if err := preset.Options.Validate(); err != nil {
+			t.Fatalf("preset %q: %v", name, err)
+		}
+	}
+	if err := DefaultOptions.Validate(); err != nil {
+		t.Fatalf("default options: %v", err)
+	}
+
+	casual := PresetCasual.Options
+	for _, typ := range casual.Types() {
+		if kindsMap[typ].hasKicker() {
+			t.Fatalf("casual preset allows kicker type %d", typ)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(*Options)
+	}{
+		{"zero bomb multiple", func(opt *Options) { opt.MultipleOfBomb = 0 }},
+		{"negative max multiple", func(opt *Options) { opt.MaxMultiple = -1 }},
+		{"short chain", func(opt *Options) { opt.MinLengthOfChain = 2 }},
+		{"long pair chain", func(opt *Options) { opt.MinLengthOfPairChain = 11 }},
+		{"no kicker with kicker rules", func(opt *Options) { opt.NoKicker = true }},
+		{"no kicker without trio", func(opt *Options) {
+			*opt = PresetCasual.Options
+			opt.CanTrioWithoutKicker = false
+		}},
+	} {
+		opt := DefaultOptions
+		tc.modify(&opt)
+		if err := opt.Validate(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%s: expected ErrInvalidOptions, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestOptionsMultiple(t *testing.T) {
+	opt := DefaultOptions
+	if m := opt.Multiple(2, 1, true); m != 16 {
+		t.Fatalf("expected 16, got %d", m)
+	}
+	opt.MaxMultiple = 8
+	if m := opt.Multiple(2, 1, true); m != 8 {
+		t.Fatalf("expected capped 8, got %d", m)
+	}
+	if m := opt.Multiple(0, 0, false); m != 1 {
+		t.Fatalf("expected 1, got %d", m)
+	}
+}
+
+func TestLoadPresets(t *testing.T) {
+	list, err := LoadPresets(strings.NewReader(`[
+		{"name": "house", "description": "house rules", "base": "classic", "options": {"max_multiple": 32}},
+		{"name": "house2", "base": "house", "options": {"min_length_of_chain": 3}}
+	]`))
+	if err != nil {
+		t.Fatalf("load presets: %v", err)
+	}
+	if len(list) != 2 || list[0].Options.MaxMultiple != 32 || !list[0].Options.CanTrioWithPair ||
+		list[1].Options.MaxMultiple != 32 || list[1].Options.MinLengthOfChain != 3 {
+		t.Fatalf("unexpected presets: %+v", list)
+	}
+
+	for _, content := range []string{
+		`[{"name": "bad", "base": "classic", "options": {"no_kicker": true}}]`,
+		`[{"name": "bad", "base": "unknown"}]`,
+		`[{"name": "bad", "base": "classic", "options": {"unknown_rule": true}}]`,
+		`[{"base": "classic"}]`,
+	} {
+		if _, err := LoadPresets(strings.NewReader(content)); err == nil {
+			t.Errorf("expected error loading %s", content)
+		}
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "presets.yaml")
+	content := `
+- name: yaml-house
+  base: competition
+  options:
+    max_multiple: 128
+    can_space_shuttle: true
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPresetsFile(filename); err != nil {
+		t.Fatalf("load yaml presets: %v", err)
+	}
+	preset, ok := LookupPreset("yaml-house")
+	if !ok || preset.Options.MaxMultiple != 128 || !preset.Options.CanSpaceShuttle || preset.Options.CanRepeatKicker {
+		t.Fatalf("unexpected yaml preset: %+v", preset)
+	}
+	// 有不合法的预设时一个也不注册
+	content = `
+- name: yaml-partial
+  base: classic
+- name: yaml-bad
+  base: classic
+  options:
+    no_kicker: true
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPresetsFile(filename); err == nil {
+		t.Fatalf("expected error loading invalid presets")
+	}
+	if _, ok := LookupPreset("yaml-partial"); ok {
+		t.Fatalf("valid preset should not be registered when others are invalid")
+	}
+	if err := registerPresets([]Preset{{Name: "partial", Options: DefaultOptions}, {Name: ""}}); err == nil {
+		t.Fatalf("expected error registering preset without name")
+	}
+	if _, ok := LookupPreset("partial"); ok {
+		t.Fatalf("registration should be all or nothing")
+	}
+}
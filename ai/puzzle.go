@@ -0,0 +1,557 @@
+package ai
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 残局目标
+type Goal int
+
+const (
+	GoalLandlord Goal = iota // 地主获胜
+	GoalFarmers              // 农民获胜
+)
+
+func (goal Goal) String() string {
+	if goal == GoalLandlord {
+		return "landlord"
+	}
+	return "farmers"
+}
+
+// 判断玩家是否是目标方
+func (goal Goal) side(landlord, pos Position) bool {
+	return (pos == landlord) == (goal == GoalLandlord)
+}
+
+var (
+	ErrIllegalMove = errors.New("ai: illegal move")
+	ErrLosingMove  = errors.New("ai: losing move")
+	ErrNotYourTurn = errors.New("ai: not goal side's turn")
+	ErrTooComplex  = errors.New("ai: puzzle too complex to solve")
+	ErrPuzzleEnded = errors.New("ai: puzzle ended")
+	errBadScenario = errors.New("ai: bad scenario")
+)
+
+// 求解器最多记录的局面数量
+const maxSolverEntries = 1 << 22
+
+// 残局
+//
+// 残局文件是按行组织的文本,每行为 "键: 值",空行和 # 开头的行被忽略
+// (小王也用 # 表示,所以注释只能单独成行):
+//
+//	# 名称,可选
+//	name: 送队友走
+//	# 规则预设名称,可选,默认使用 DefaultOptions
+//	rules: classic
+//	# 地主位置和当前轮到的玩家
+//	landlord: 0
+//	turn: 1
+//	# 当前需要管的牌: 出牌者位置和牌,可选,不指定表示 turn 主动出牌
+//	lead: 0 KK
+//	# 目标: landlord 或 farmers
+//	goal: farmers
+//	# 各玩家的手牌,使用 ParsePokerSet 的文本表示,不同玩家未指定花色的相同牌值会自动使用不同的花色
+//	p0: 2AAKK
+//	p1: 3QQ
+//	p2: 4
+type Scenario struct {
+	Name     string
+	Options  Options
+	Landlord Position
+	Turn     Position
+	Lead     Kind
+	Leader   Position
+	Goal     Goal
+	Hands    [NumPlayer]PokerSet
+}
+
+// 解析残局文件
+func ParseScenario(r io.Reader) (*Scenario, error) {
+	s := &Scenario{
+		Options:  DefaultOptions,
+		Landlord: BadPosition,
+		Turn:     BadPosition,
+		Leader:   BadPosition,
+		Goal:     -1,
+	}
+	var (
+		lead string
+		used PokerSet
+	)
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("%w: line %d: missing ':'", errBadScenario, lineno)
+		}
+		key, value := strings.TrimSpace(line[:colon]), strings.TrimSpace(line[colon+1:])
+		var err error
+		switch key {
+		case "name":
+			s.Name = value
+		case "rules":
+			preset, ok := LookupPreset(value)
+			if !ok {
+				err = fmt.Errorf("unknown rules %q", value)
+			}
+			s.Options = preset.Options
+		case "landlord":
+			s.Landlord, err = parsePosition(value)
+		case "turn":
+			s.Turn, err = parsePosition(value)
+		case "lead":
+			lead = value
+		case "goal":
+			switch value {
+			case "landlord":
+				s.Goal = GoalLandlord
+			case "farmers":
+				s.Goal = GoalFarmers
+			default:
+				err = fmt.Errorf("unknown goal %q", value)
+			}
+		case "p0", "p1", "p2":
+			var hand PokerSet
+			hand, err = parsePokerSet(value, used)
+			s.Hands[key[1]-'0'] = hand
+			used |= hand
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", errBadScenario, lineno, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lead != "" {
+		fields := strings.SplitN(lead, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: lead requires position and pokers", errBadScenario)
+		}
+		leader, err := parsePosition(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: lead: %v", errBadScenario, err)
+		}
+		pokers, err := parsePokerSet(fields[1], used)
+		if err != nil {
+			return nil, fmt.Errorf("%w: lead: %v", errBadScenario, err)
+		}
+		kinds := Classify(pokers, s.Options)
+		if len(kinds) == 0 {
+			return nil, fmt.Errorf("%w: lead %s is not a valid play", errBadScenario, pokers.Notation())
+		}
+		s.Lead, s.Leader = kinds[0], leader
+	}
+	return s, s.validate()
+}
+
+func parsePosition(s string) (Position, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || !Position(n).Valid() {
+		return BadPosition, fmt.Errorf("invalid position %q", s)
+	}
+	return Position(n), nil
+}
+
+func (s *Scenario) validate() error {
+	switch {
+	case !s.Landlord.Valid():
+		return fmt.Errorf("%w: landlord not set", errBadScenario)
+	case !s.Turn.Valid():
+		return fmt.Errorf("%w: turn not set", errBadScenario)
+	case s.Goal < 0:
+		return fmt.Errorf("%w: goal not set", errBadScenario)
+	case s.Leader == s.Turn:
+		return fmt.Errorf("%w: leader can't be the player to move", errBadScenario)
+	}
+	var all PokerSet
+	for i, hand := range s.Hands {
+		if hand.Empty() {
+			return fmt.Errorf("%w: p%d has no pokers", errBadScenario, i)
+		}
+		if all&hand != 0 {
+			return fmt.Errorf("%w: p%d has duplicated pokers", errBadScenario, i)
+		}
+		all.Add(hand)
+	}
+	if all&s.Lead.Pokers() != 0 {
+		return fmt.Errorf("%w: lead pokers are still in hands", errBadScenario)
+	}
+	return nil
+}
+
+// 残局开始时的游戏状态
+func (s *Scenario) State() State {
+	return NewStateWithOptions(s.Hands, s.Landlord, s.Options)
+}
+
+// 残局开始时的搜索树根节点,可以直接用于 Node.Search
+func (s *Scenario) Node() *Node {
+	var (
+		prev   = s.Turn.Prev()
+		first  = Action{player: prev.Prev()}
+		second = Action{player: prev}
+	)
+	switch s.Leader {
+	case prev:
+		second.kind = s.Lead
+	case prev.Prev():
+		first.kind = s.Lead
+	}
+	return NewNode(NewNode(nil, first, s.State()), second, s.State())
+}
+
+func (s *Scenario) position() position {
+	return position{
+		state:  s.State(),
+		next:   s.Turn,
+		lead:   s.Lead,
+		leader: s.Leader,
+	}
+}
+
+// 完全信息下的对局局面
+type position struct {
+	state  State
+	next   Position
+	lead   Kind
+	leader Position
+}
+
+type positionKey struct {
+	pokers [NumPlayer]PokerSet
+	next   Position
+	lead   kindKey
+	leader Position
+}
+
+func (p position) key() positionKey {
+	return positionKey{pokers: p.state.pokers, next: p.next, lead: p.lead.key(), leader: p.leader}
+}
+
+func (p position) moves(opt Options) []Kind {
+	return p.state.pokers[p.next].MatchAll(Kind{}, p.lead, opt)
+}
+
+func (p position) play(kind Kind) position {
+	p.state = Action{player: p.next, kind: kind}.Do(p.state)
+	if kind.Len() > 0 {
+		p.lead = kind
+		p.leader = p.next
+	}
+	p.next = p.next.Next()
+	if p.next == p.leader {
+		// 其他玩家都不出,出牌者重新出牌
+		p.lead = Kind{}
+		p.leader = BadPosition
+	}
+	return p
+}
+
+// 求解结果: 目标方是否必胜,以及双方最优应对下(目标方尽快获胜,对手尽量拖延)剩余的出牌次数
+type solution struct {
+	win   bool
+	plies int
+}
+
+// 穷举求解器
+type solver struct {
+	opt   Options
+	goal  Goal
+	memo  map[positionKey]solution
+	limit int
+}
+
+func newSolver(s *Scenario) *solver {
+	return &solver{
+		opt:   s.Options,
+		goal:  s.Goal,
+		memo:  make(map[positionKey]solution),
+		limit: maxSolverEntries,
+	}
+}
+
+func (sv *solver) solve(p position) (solution, error) {
+	if winner := p.state.Winner(); winner.Valid() {
+		return solution{win: sv.goal.side(p.state.landlord, winner)}, nil
+	}
+	key := p.key()
+	if sol, ok := sv.memo[key]; ok {
+		return sol, nil
+	}
+	if len(sv.memo) >= sv.limit {
+		return solution{}, ErrTooComplex
+	}
+	var (
+		mine = sv.goal.side(p.state.landlord, p.next)
+		best solution
+		init bool
+	)
+	for _, kind := range p.moves(sv.opt) {
+		sol, err := sv.solve(p.play(kind))
+		if err != nil {
+			return sol, err
+		}
+		sol.plies++
+		if !init || sv.better(mine, sol, best) {
+			best, init = sol, true
+		}
+	}
+	sv.memo[key] = best
+	return best, nil
+}
+
+// 判断对于目标方(mine)或对手来说 a 是否比 b 更好
+func (sv *solver) better(mine bool, a, b solution) bool {
+	if mine {
+		if a.win != b.win {
+			return a.win
+		}
+		if a.win {
+			return a.plies < b.plies
+		}
+		return a.plies > b.plies
+	}
+	if a.win != b.win {
+		return !a.win
+	}
+	if a.win {
+		return a.plies > b.plies
+	}
+	return a.plies < b.plies
+}
+
+// 局面 p 下所有走法及其结果
+func (sv *solver) analyze(p position) ([]Kind, []solution, error) {
+	moves := p.moves(sv.opt)
+	sols := make([]solution, len(moves))
+	for i, kind := range moves {
+		sol, err := sv.solve(p.play(kind))
+		if err != nil {
+			return nil, nil, err
+		}
+		sol.plies++
+		sols[i] = sol
+	}
+	return moves, sols, nil
+}
+
+// 选出最优走法
+func (sv *solver) best(p position) (Kind, solution, error) {
+	moves, sols, err := sv.analyze(p)
+	if err != nil {
+		return Kind{}, solution{}, err
+	}
+	mine := sv.goal.side(p.state.landlord, p.next)
+	index := 0
+	for i := range moves {
+		if sv.better(mine, sols[i], sols[index]) {
+			index = i
+		}
+	}
+	return moves[index], sols[index], nil
+}
+
+// 残局检查结果
+type PuzzleReport struct {
+	// 目标方是否必胜
+	Solvable bool
+	// 无论对手如何应对,目标方每一步是否都只有唯一的获胜走法. 同一组牌的不同牌型解释算作同一种走法
+	Unique bool
+	// 主要变例: 目标方最快获胜,对手最大限度拖延
+	MainLine []Action
+	// 主要变例上有多种获胜走法的局面的下标. 只在主要变例以外的局面有多种获胜走法时为空,但 Unique 为 false
+	Ambiguous []int
+}
+
+// 使用穷举搜索检查残局: 是否有解,以及对手任意应对下的解是否唯一
+func (s *Scenario) Check() (*PuzzleReport, error) {
+	sv := newSolver(s)
+	p := s.position()
+	sol, err := sv.solve(p)
+	if err != nil {
+		return nil, err
+	}
+	report := &PuzzleReport{Solvable: sol.win, Unique: sol.win}
+	if !sol.win {
+		return report, nil
+	}
+	for !p.state.Gameover() {
+		if sv.goal.side(p.state.landlord, p.next) {
+			_, sols, err := sv.analyze(p)
+			if err != nil {
+				return nil, err
+			}
+			wins := 0
+			for _, sol := range sols {
+				if sol.win {
+					wins++
+				}
+			}
+			if wins > 1 {
+				report.Unique = false
+				report.Ambiguous = append(report.Ambiguous, len(report.MainLine))
+			}
+		}
+		kind, _, err := sv.best(p)
+		if err != nil {
+			return nil, err
+		}
+		report.MainLine = append(report.MainLine, Action{player: p.next, kind: kind})
+		p = p.play(kind)
+	}
+	if report.Unique {
+		report.Unique, err = sv.unique(s.position(), make(map[positionKey]bool))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// 检查目标方必胜的局面 p 之后,对手的每一种应对下目标方是否都只有唯一的获胜走法. seen 记录已经检查过的局面
+func (sv *solver) unique(p position, seen map[positionKey]bool) (bool, error) {
+	if p.state.Gameover() {
+		return true, nil
+	}
+	key := p.key()
+	if seen[key] {
+		return true, nil
+	}
+	seen[key] = true
+	moves, sols, err := sv.analyze(p)
+	if err != nil {
+		return false, err
+	}
+	mine := sv.goal.side(p.state.landlord, p.next)
+	var (
+		win   PokerSet
+		found bool
+	)
+	for i, kind := range moves {
+		if !sols[i].win {
+			continue
+		}
+		if mine {
+			pokers := kind.Pokers().Normalize()
+			if found && pokers != win {
+				return false, nil
+			}
+			win, found = pokers, true
+		}
+		if ok, err := sv.unique(p.play(kind), seen); err != nil || !ok {
+			return ok, err
+		}
+	}
+	return true, nil
+}
+
+// 残局解题过程: 玩家为目标方出牌,对手的出牌由求解器按最大限度拖延的策略自动完成
+type PuzzleSession struct {
+	scenario *Scenario
+	solver   *solver
+	pos      position
+	hands    [NumPlayer]PokerSet
+	history  []Action
+}
+
+// 开始解题,如果开始时轮到对手出牌,对手会先自动出牌
+func (s *Scenario) NewSession() (*PuzzleSession, error) {
+	sess := &PuzzleSession{
+		scenario: s,
+		solver:   newSolver(s),
+		pos:      s.position(),
+		hands:    s.Hands,
+	}
+	return sess, sess.respond()
+}
+
+// 当前轮到的玩家
+func (sess *PuzzleSession) Turn() Position { return sess.pos.next }
+
+// 当前需要管的牌,为空表示主动出牌
+func (sess *PuzzleSession) Lead() Kind { return sess.pos.lead }
+
+// 各玩家剩余的牌
+func (sess *PuzzleSession) Hands() [NumPlayer]PokerSet { return sess.hands }
+
+// 所有的出牌记录,使用玩家手中实际的牌
+func (sess *PuzzleSession) History() []Action { return sess.history }
+
+// 游戏是否结束
+func (sess *PuzzleSession) Done() bool { return sess.pos.state.Gameover() }
+
+// 是否已经解出残局
+func (sess *PuzzleSession) Solved() bool {
+	winner := sess.pos.state.Winner()
+	return winner.Valid() && sess.solver.goal.side(sess.pos.state.landlord, winner)
+}
+
+// 提交目标方的一步出牌: 不合法返回 ErrIllegalMove, 出牌后无法获胜返回 ErrLosingMove,
+// 出错时局面不变. 出牌成功后对手会自动应对,直到再次轮到目标方或游戏结束
+func (sess *PuzzleSession) Submit(kind Kind) error {
+	if sess.Done() {
+		return ErrPuzzleEnded
+	}
+	if !sess.solver.goal.side(sess.pos.state.landlord, sess.pos.next) {
+		return ErrNotYourTurn
+	}
+	if !sess.hands[sess.pos.next].Contains(kind.Pokers()) {
+		return ErrIllegalMove
+	}
+	var (
+		found bool
+		key   = kind.key()
+	)
+	for _, k := range sess.pos.moves(sess.solver.opt) {
+		if k.key() == key {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrIllegalMove
+	}
+	sol, err := sess.solver.solve(sess.pos.play(kind))
+	if err != nil {
+		return err
+	}
+	if !sol.win {
+		return ErrLosingMove
+	}
+	sess.play(kind)
+	return sess.respond()
+}
+
+func (sess *PuzzleSession) play(kind Kind) {
+	player := sess.pos.next
+	sess.hands[player].Remove(kind.Pokers())
+	sess.history = append(sess.history, Action{player: player, kind: kind})
+	sess.pos = sess.pos.play(kind)
+}
+
+// 对手自动出牌
+func (sess *PuzzleSession) respond() error {
+	for !sess.Done() && !sess.solver.goal.side(sess.pos.state.landlord, sess.pos.next) {
+		kind, _, err := sess.solver.best(sess.pos)
+		if err != nil {
+			return err
+		}
+		kind, _ = kind.realize(sess.hands[sess.pos.next], emptyPokerSet)
+		sess.play(kind)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+func TestNotation(t *testing.T) {
+	pset, err := ParsePokerSet("333 44, [X J Q K A 2] #$")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if pset.Len() != 13 || pset.Count(poker.P3) != 3 || pset.Count(poker.P10) != 1 || !pset.Contains(rocket) {
+		t.Fatalf("bad poker set %v", pset)
+	}
+	if again, err := ParsePokerSet(pset.Notation()); err != nil || again != pset {
+		t.Fatalf("notation %q doesn't round trip: %v, %v", pset.Notation(), again, err)
+	}
+	if ten, err := ParsePokerSet("10t"); err != nil || ten.Count(poker.P10) != 2 {
+		t.Fatalf("bad tens: %v, %v", ten, err)
+	}
+	heart3 := NewPokerSetWithPoker(poker.NewPoker(poker.Heart, poker.P3))
+	if pset, err := ParsePokerSet("♥3"); err != nil || pset != heart3 {
+		t.Fatalf("bad suited poker: %v, %v", pset, err)
+	}
+	for _, s := range []string{"33333", "Z", "♠#", "♥3♥3", "3♥"} {
+		if _, err := ParsePokerSet(s); err == nil {
+			t.Fatalf("%q should fail", s)
+		}
+	}
+
+	hand := mustParsePokerSet(t, "33 4 K")
+	selected, err := hand.Select("3K")
+	if err != nil || selected.Len() != 2 || !hand.Contains(selected) {
+		t.Fatalf("bad selection %v, %v", selected, err)
+	}
+	if _, err := hand.Select("44"); err == nil {
+		t.Fatalf("select 44 should fail")
+	}
+}
+
+const puzzleFarmers = `
+# 地主先出,农民必须用 A 管上,再出对子走掉
+name: 抢回出牌权
+landlord: 0
+turn: 0
+goal: farmers
+p0: KQ
+p1: 44A
+p2: 55
+`
+
+func TestPuzzleCheck(t *testing.T) {
+	scenario, err := ParseScenario(strings.NewReader(puzzleFarmers))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if scenario.Name != "抢回出牌权" || scenario.Goal != GoalFarmers || scenario.Hands[1].Len() != 3 {
+		t.Fatalf("bad scenario %+v", scenario)
+	}
+	report, err := scenario.Check()
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if !report.Solvable || !report.Unique {
+		t.Fatalf("puzzle should have a unique solution: %+v", report)
+	}
+	if len(report.MainLine) != 5 || report.MainLine[1].kind.minValue != poker.PMA || report.MainLine[4].kind.Type() != poker.Double1 {
+		t.Fatalf("bad main line %v", report.MainLine)
+	}
+
+	// 地主多一张 2 后农民无解
+	scenario.Hands[0].Add(NewPokerSetWithPoker(poker.NewPoker(poker.Heart, poker.PM2)))
+	if report, err := scenario.Check(); err != nil || report.Solvable {
+		t.Fatalf("puzzle should be unsolvable: %+v, %v", report, err)
+	}
+}
+
+// 主要变例上地主每一步都只有唯一的获胜走法,但对手的其他应对之后地主有多种获胜走法
+const puzzleOffMainLine = `
+landlord: 0
+turn: 0
+goal: landlord
+p0: 44A57
+p1: 5629
+p2: 4J5K
+`
+
+func TestPuzzleUniqueOffMainLine(t *testing.T) {
+	scenario, err := ParseScenario(strings.NewReader(puzzleOffMainLine))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	report, err := scenario.Check()
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if !report.Solvable || len(report.Ambiguous) != 0 {
+		t.Fatalf("main line should be unique: %+v", report)
+	}
+	if report.Unique {
+		t.Fatalf("puzzle has alternative wins off the main line: %+v", report)
+	}
+}
+
+func TestPuzzleSession(t *testing.T) {
+	scenario, err := ParseScenario(strings.NewReader(puzzleFarmers))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	sess, err := scenario.NewSession()
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	hand := sess.Hands()[1]
+	pick := func(s string) Kind {
+		pokers, err := hand.Select(s)
+		if err != nil {
+			t.Fatalf("select %q: %v", s, err)
+		}
+		return Classify(pokers, scenario.Options)[0]
+	}
+
+	// 地主已经自动出牌
+	if sess.Turn() != 1 || len(sess.History()) != 1 || sess.Lead().Len() != 1 {
+		t.Fatalf("landlord should have played: %v", sess.History())
+	}
+	if err := sess.Submit(Kind{}); !errors.Is(err, ErrLosingMove) {
+		t.Fatalf("expected losing move, got %v", err)
+	}
+	if err := sess.Submit(pick("44")); !errors.Is(err, ErrIllegalMove) {
+		t.Fatalf("expected illegal move, got %v", err)
+	}
+	if err := sess.Submit(pick("A")); err != nil {
+		t.Fatalf("submit A: %v", err)
+	}
+	// 轮到队友
+	if sess.Turn() != 2 {
+		t.Fatalf("bad session state: turn %v, history %v", sess.Turn(), sess.History())
+	}
+	if err := sess.Submit(Kind{}); err != nil {
+		t.Fatalf("submit pass: %v", err)
+	}
+	// 地主要不起
+	if sess.Turn() != 1 || len(sess.History()) != 4 {
+		t.Fatalf("bad session state: turn %v, history %v", sess.Turn(), sess.History())
+	}
+	if err := sess.Submit(pick("4")); !errors.Is(err, ErrLosingMove) {
+		t.Fatalf("expected losing move, got %v", err)
+	}
+	if err := sess.Submit(pick("44")); err != nil {
+		t.Fatalf("submit 44: %v", err)
+	}
+	if !sess.Done() || !sess.Solved() {
+		t.Fatalf("puzzle should be solved")
+	}
+	if err := sess.Submit(Kind{}); !errors.Is(err, ErrPuzzleEnded) {
+		t.Fatalf("expected ended, got %v", err)
+	}
+}
+
+func TestScenarioLead(t *testing.T) {
+	scenario, err := ParseScenario(strings.NewReader(`
+landlord: 0
+turn: 2
+lead: 0 K
+goal: landlord
+rules: classic
+p0: 22#
+p1: 3K
+p2: 4A
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	lead, leader := scenario.Node().lead()
+	if leader != 0 || lead.Type() != poker.Single1 || lead.minValue != poker.PK {
+		t.Fatalf("bad lead %v by %v", lead, leader)
+	}
+	if scenario.Hands[0]&scenario.Lead.Pokers() != 0 || scenario.Hands[1].Count(poker.PK) != 1 {
+		t.Fatalf("same values in different seats should use different suits: %+v", scenario)
+	}
+	report, err := scenario.Check()
+	if err != nil || !report.Solvable {
+		t.Fatalf("landlord should win: %+v, %v", report, err)
+	}
+
+	for _, bad := range []string{
+		"landlord: 0\nturn: 1\ngoal: farmers\np0: ♠3\np1: ♠3\np2: 4",
+		"landlord: 0\nturn: 1\ngoal: farmers\np0: 3\np1: 4\np2: 55555",
+		"landlord: 0\nturn: 1\ngoal: nobody\np0: 3\np1: 4\np2: 5",
+		"landlord: 0\ngoal: farmers\np0: 3\np1: 4\np2: 5",
+		"landlord: 0\nturn: 1\nlead: 1 6\ngoal: farmers\np0: 3\np1: 4\np2: 5",
+	} {
+		if _, err := ParseScenario(strings.NewReader(bad)); err == nil {
+			t.Fatalf("scenario should be rejected:\n%s", bad)
+		}
+	}
+}
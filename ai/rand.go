@@ -0,0 +1,27 @@
+package ai
+
+import "math/rand"
+
+// 随机数辅助函数: r 为空时使用全局随机数
+
+func shuffle(r *rand.Rand, n int, swap func(i, j int)) {
+	if r == nil {
+		rand.Shuffle(n, swap)
+	} else {
+		r.Shuffle(n, swap)
+	}
+}
+
+func float64n(r *rand.Rand) float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	return r.Float64()
+}
+
+func intn(r *rand.Rand, n int) int {
+	if r == nil {
+		return rand.Intn(n)
+	}
+	return r.Intn(n)
+}
@@ -2,8 +2,13 @@ package ai
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 
 	"github.com/gopherd/doge/bits"
 	"github.com/gopherd/doge/math/mathutil"
@@ -34,10 +39,16 @@ type Options struct {
 	CanRepeatKicker bool `json:"can_repeat_kicker"`
 	// 是否允许带牌时带王
 	CanJokerAsKicker bool `json:"can_joker_as_kicker"`
+	// 是否禁止所有带牌(不带牌玩法)
+	NoKicker bool `json:"no_kicker"`
 	// 普通炸弹倍数
 	MultipleOfBomb int `json:"multiple_of_bomb"`
 	// 火箭(王炸)倍数
 	MultipleOfRocket int `json:"multiple_of_rocket"`
+	// 春天(含反春)倍数
+	MultipleOfSpring int `json:"multiple_of_spring"`
+	// 封顶倍数, 0 表示不封顶
+	MaxMultiple int `json:"max_multiple"`
 	// 顺子最少长度
 	MinLengthOfChain int `json:"min_length_of_chain"`
 	// 连对最少长度
@@ -52,12 +63,54 @@ var DefaultOptions = Options{
 	CanSpaceShuttle:       false,
 	CanRepeatKicker:       true,
 	CanJokerAsKicker:      false,
+	NoKicker:              false,
 	MultipleOfBomb:        2,
 	MultipleOfRocket:      2,
+	MultipleOfSpring:      2,
+	MaxMultiple:           0,
 	MinLengthOfChain:      5,
 	MinLengthOfPairChain:  3,
 }
 
+// 判断牌型是否被规则允许
+func (opt Options) allows(kind Kind) bool {
+	if kind.IsRocket() {
+		return true
+	}
+	if opt.NoKicker && kind.hasKicker() {
+		return false
+	}
+	if kind.height == 1 && kind.width > 1 && int(kind.width) < opt.MinLengthOfChain {
+		return false
+	}
+	if kind.height == 2 && kind.width > 1 && int(kind.width) < opt.MinLengthOfPairChain {
+		return false
+	}
+	if kind.height == 4 && kind.width > 1 && !opt.CanSpaceShuttle {
+		return false
+	}
+	if kind.height == 3 {
+		if kind.kickerHeight == 2 && !opt.CanTrioWithPair {
+			return false
+		}
+		if !kind.hasKicker() && !opt.CanTrioWithoutKicker {
+			return false
+		}
+	}
+	return true
+}
+
+// 获取规则允许的所有牌型(不包括不出),按编号从小到大排列
+func (opt Options) Types() []poker.Type {
+	var types []poker.Type
+	for _, typ := range kindTypes {
+		if k := kindsMap[typ]; k.Len() > 0 && opt.allows(k) {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
 // 按位表示的扑克牌集合
 // 每 4 bits 为一个块,分别表示一个扑克面值的 4 种花色的牌
 // 从最低位开始的 15 个块(60 bits) 分表表示牌值 3,4,...,A,2,Joker1,Joker2
@@ -103,6 +156,15 @@ var bombs = map[PokerSet]poker.Value{
 	NewBomb(poker.PM2): poker.PM2,
 }
 
+// 一副完整的扑克牌(54张)
+var FullDeck = func() PokerSet {
+	var deck PokerSet
+	for value := minPokerValue; value <= poker.PM2; value++ {
+		deck.Add(NewBomb(value))
+	}
+	return deck.Add(rocket)
+}()
+
 func (pset PokerSet) raw() uint64 { return uint64(pset) }
 
 func NewPokerSetWithPoker(p poker.Poker) PokerSet {
@@ -334,7 +396,7 @@ func (pset PokerSet) MinValue() poker.Value {
 	return poker.Value(0)
 }
 
-func (pset PokerSet) matchBody(w, h int8, begin poker.Value, opts Options) []PokerSet {
+func (pset PokerSet) matchBody(w, h int8, begin poker.Value, withKicker bool, opts Options) []PokerSet {
 	var (
 		ret   []PokerSet
 		start = poker.Value(0)
@@ -343,11 +405,10 @@ func (pset PokerSet) matchBody(w, h int8, begin poker.Value, opts Options) []Pok
 	begin = begin + 1
 	if w == 2 && h == 1 {
 		// 宽度为 2 且高度为 1 的只有火箭
-		if begin <= poker.PJoker1 {
-			begin = poker.PJoker1
-		} else {
-			return ret
+		if begin <= poker.PJoker1 && pset.Contains(rocket) {
+			ret = append(ret, rocket)
 		}
+		return ret
 	}
 	for value := begin; value <= maxPokerValue; value++ {
 		if value < minPokerValue {
@@ -357,7 +418,7 @@ func (pset PokerSet) matchBody(w, h int8, begin poker.Value, opts Options) []Pok
 			break
 		}
 		if h == 4 {
-			if value > poker.PM2 || (value == poker.PM2 && !opts.CanFourTwoWithKickers) {
+			if value > poker.PM2 || (value == poker.PM2 && withKicker && !opts.CanFourTwoWithKickers) {
 				break
 			}
 		}
@@ -381,7 +442,19 @@ func (pset PokerSet) matchBody(w, h int8, begin poker.Value, opts Options) []Pok
 }
 
 func (pset PokerSet) match(kind Kind, strict bool, opt Options, ret []Kind, limit int) []Kind {
-	var bodies = pset.matchBody(kind.width, kind.height, kind.minValue, opt)
+	pset.walkMatch(kind, strict, opt, func(k Kind) bool {
+		ret = append(ret, k)
+		return len(ret) >= limit
+	})
+	return ret
+}
+
+// 牌型遍历
+
+type KindVisitor func(Kind) (terminate bool)
+
+func (pset PokerSet) walkMatch(kind Kind, strict bool, opt Options, visitor KindVisitor) bool {
+	var bodies = pset.matchBody(kind.width, kind.height, kind.minValue, kind.hasKicker(), opt)
 	for _, body := range bodies {
 		if kind.hasKicker() {
 			// 选取带牌
@@ -430,13 +503,12 @@ func (pset PokerSet) match(kind Kind, strict bool, opt Options, ret []Kind, limi
 						kicker.Add(kickers[i][j])
 					}
 				}
-				ret = append(ret, kind.extend(body, kicker))
+				if visitor(kind.extend(body, kicker)) {
+					return true
+				}
 			}
-		} else {
-			ret = append(ret, kind.extend(body, emptyPokerSet))
-		}
-		if len(ret) >= limit {
-			return ret
+		} else if visitor(kind.extend(body, emptyPokerSet)) {
+			return true
 		}
 	}
 
@@ -447,11 +519,9 @@ func (pset PokerSet) match(kind Kind, strict bool, opt Options, ret []Kind, limi
 			// 如果已经是炸弹,那么在 body 匹配中就已经完成,这里就不需要了
 			for value := minPokerValue; value <= poker.PM2; value++ {
 				var body PokerSet
-				if body.AddByValue(pset, value, 4) == 4 {
-					kind2 := kindsMap[poker.Bomb].extend(body, emptyPokerSet)
-					ret = append(ret, kind2)
-					if len(ret) >= limit {
-						return ret
+				if !body.AddByValue(pset, value, 4).Empty() {
+					if visitor(kindsMap[poker.Bomb].extend(body, emptyPokerSet)) {
+						return true
 					}
 				}
 			}
@@ -460,40 +530,127 @@ func (pset PokerSet) match(kind Kind, strict bool, opt Options, ret []Kind, limi
 			// 如果当前不是火箭则尝试匹配火箭
 			// 如果已经是火箭,那么在 body 匹配中就已经完成,这里就不需要了
 			if pset.Contains(rocket) {
-				kind2 := kindsMap[poker.Rocket].extend(rocket, emptyPokerSet)
-				ret = append(ret, kind2)
+				if visitor(kindsMap[poker.Rocket].extend(rocket, emptyPokerSet)) {
+					return true
+				}
 			}
 		}
 	}
 
-	return ret
+	return false
 }
 
-func (pset PokerSet) Match(kind1, kind2 Kind, opt Options, limit int) []Kind {
+// 用于去除花色差异的重复牌型. 同一组牌主干不同的解释(如 333444555+666 和 444555666+333)是不同的牌型
+type kindKey struct {
+	shape    uint32
+	minValue poker.Value
+	pokers   PokerSet
+}
+
+func (kind Kind) key() kindKey {
+	return kindKey{shape: kind.shape(), minValue: kind.minValue, pokers: kind.Pokers().Normalize()}
+}
+
+// 遍历所有可以出的牌型,遍历顺序是确定的: 主动出牌时按牌型编号从小到大,
+// 同一牌型内按主干部分从小到大;跟牌时依次是同牌型更大的牌,炸弹,火箭,最后是不出.
+// 只有花色不同的牌型只会被遍历一次
+func (pset PokerSet) WalkMatch(kind1, kind2 Kind, opt Options, visitor KindVisitor) bool {
 	kind := kind2
 	if kind.Len() == 0 {
 		kind = kind1
 	}
 
+	var (
+		seen  = make(map[kindKey]bool)
+		visit = func(k Kind) bool {
+			key := k.key()
+			if seen[key] {
+				return false
+			}
+			seen[key] = true
+			return visitor(k)
+		}
+	)
+
 	// 前两手都没有人出牌,则玩家可以选择任意合法牌型出牌
 	if kind.Len() == 0 {
-		ret := make([]Kind, 0, pset.Len()*2)
-		for _, k := range kindsMap {
-			if k.Len() == 0 {
-				continue
-			}
-			ret = pset.match(k, true, opt, ret, limit)
-			if len(ret) >= limit {
-				break
+		for _, typ := range opt.Types() {
+			if pset.walkMatch(kindsMap[typ], true, opt, visit) {
+				return true
 			}
 		}
-		return ret
+		return false
 	}
 
 	// 否则,玩家只能选择能管上 ``kind'' 的牌型出牌或者弃牌
-	ret := make([]Kind, 0, 8)
-	ret = pset.match(kind, false, opt, ret, limit)
-	ret = append(ret, Kind{})
+	if pset.walkMatch(kind, false, opt, visit) {
+		return true
+	}
+	return visitor(Kind{})
+}
+
+// 获取所有可以出的牌型,顺序同 WalkMatch
+func (pset PokerSet) MatchAll(kind1, kind2 Kind, opt Options) []Kind {
+	ret := make([]Kind, 0, pset.Len()*2)
+	pset.WalkMatch(kind1, kind2, opt, func(k Kind) bool {
+		ret = append(ret, k)
+		return false
+	})
+	return ret
+}
+
+// 获取最多 limit 个可以出的牌型,跟牌时总是包含不出
+func (pset PokerSet) Match(kind1, kind2 Kind, opt Options, limit int) []Kind {
+	var (
+		ret     = make([]Kind, 0, 8)
+		leading = kind1.Len() == 0 && kind2.Len() == 0
+	)
+	pset.WalkMatch(kind1, kind2, opt, func(k Kind) bool {
+		if k.Len() == 0 {
+			ret = append(ret, k)
+			return true
+		}
+		if len(ret) < limit {
+			ret = append(ret, k)
+		}
+		return leading && len(ret) >= limit
+	})
+	return ret
+}
+
+// 识别一组牌的所有可能牌型,返回结果按牌型从小到大排列
+// 同一组牌可能有多种解释,比如 333444555666 既可以是 4 连飞机不带, 也可以是 333444555+666 的 3 连飞机带单
+func Classify(pokers PokerSet, opt Options) []Kind {
+	var ret []Kind
+	n := pokers.Len()
+	if n == 0 {
+		return ret
+	}
+	for _, typ := range opt.Types() {
+		k := kindsMap[typ]
+		if k.Len() != n {
+			continue
+		}
+		// 同一牌型可能有多种主干不同的解释,比如 333444555666 作为 3 连飞机带单时
+		// 既可以是 333444555+666, 也可以是 444555666+333, 每种主干最小牌面值保留一个
+		start := len(ret)
+		for _, kind := range pokers.match(k, true, opt, nil, math.MaxInt32) {
+			if kind.Pokers() != pokers {
+				continue
+			}
+			found := false
+			for _, prev := range ret[start:] {
+				if prev.minValue == kind.minValue {
+					found = true
+					break
+				}
+			}
+			if !found {
+				ret = append(ret, kind)
+			}
+		}
+	}
+	SortKinds(ret)
 	return ret
 }
 
@@ -547,6 +704,58 @@ func (kind Kind) String() string {
 		kind.width, kind.height, kind.kickerWidth, kind.kickerHeight, kind.minValue)
 }
 
+func (kind Kind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   poker.Type `json:"type"`
+		Pokers []int32    `json:"pokers"`
+	}{kind.Type(), kind.Pokers().ToInt32s(nil)})
+}
+
+// 二进制编码: 4 字节形状,然后是主干部分和带牌部分的 uvarint 编码
+func (kind Kind) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 4+2*binary.MaxVarintLen64)
+	b[0], b[1], b[2], b[3] = byte(kind.width), byte(kind.height), byte(kind.kickerWidth), byte(kind.kickerHeight)
+	n := 4
+	n += binary.PutUvarint(b[n:], uint64(kind.body))
+	n += binary.PutUvarint(b[n:], uint64(kind.kicker))
+	return b[:n], nil
+}
+
+var errBadKindBinary = errors.New("ai: invalid binary kind")
+
+func (kind *Kind) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return errBadKindBinary
+	}
+	k := NewKind(int8(data[0]), int8(data[1]), int8(data[2]), int8(data[3]))
+	data = data[4:]
+	body, n := binary.Uvarint(data)
+	if n <= 0 {
+		return errBadKindBinary
+	}
+	data = data[n:]
+	kicker, n := binary.Uvarint(data)
+	if n <= 0 || n != len(data) {
+		return errBadKindBinary
+	}
+	k = k.extend(PokerSet(body), PokerSet(kicker))
+	if k.shape() == 0 {
+		if k.Len() != 0 || !k.Pokers().Empty() {
+			return errBadKindBinary
+		}
+		*kind = Kind{}
+		return nil
+	}
+	if _, ok := kindsRevMap[k.shape()]; !ok ||
+		k.body.Len() != int(k.width)*int(k.height) ||
+		k.kicker.Len() != int(k.kickerWidth)*int(k.kickerHeight) ||
+		k.body&k.kicker != 0 {
+		return errBadKindBinary
+	}
+	*kind = k
+	return nil
+}
+
 func NewKind(width, height, kickerWidth, kickerHeight int8) Kind {
 	return Kind{
 		width:        width,
@@ -592,8 +801,11 @@ func (kind Kind) Len() int {
 	return int(kind.height*kind.width + kind.kickerHeight*kind.kickerWidth)
 }
 
+// 主干部分的最小牌面值
+func (kind Kind) MinValue() poker.Value { return kind.minValue }
+
 func (kind Kind) Equal(kind2 Kind) bool {
-	return kind.shape() == kind2.shape() &&
+	return kind.shape() == kind2.shape() && kind.minValue == kind2.minValue &&
 		kind.Pokers().Normalize() == kind2.Pokers().Normalize()
 }
 
@@ -601,9 +813,86 @@ func (kind Kind) Type() poker.Type {
 	return kindsRevMap[kind.shape()]
 }
 
+// 判断牌型在给定规则下是否合法
+func (kind Kind) Valid(opt Options) bool {
+	if kind.Len() == 0 {
+		return true
+	}
+	for _, k := range Classify(kind.Pokers(), opt) {
+		if k.shape() == kind.shape() && k.minValue == kind.minValue {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断牌型 kind 能否管上 other
+//
+// other 为空时表示没有需要管的牌,任意合法牌型都可以出;
+// 火箭可以管上任何牌型,炸弹可以管上非炸弹的牌型以及更小的炸弹,
+// 其余牌型只能管上形状相同(包括带牌的宽高)且主干部分更大的牌型
+func (kind Kind) Beats(other Kind, opt Options) bool {
+	if kind.Len() == 0 || !kind.Valid(opt) {
+		return false
+	}
+	if other.Len() == 0 {
+		return true
+	}
+	if kind.IsRocket() {
+		return !other.IsRocket()
+	}
+	if other.IsRocket() {
+		return false
+	}
+	if kind.IsBomb() {
+		return !other.IsBomb() || kind.minValue > other.minValue
+	}
+	return kind.shape() == other.shape() && kind.minValue > other.minValue
+}
+
+// 牌型比较: 先按牌型,再按主干部分的最小牌值,最后按带牌比较
+// 返回值小于 0 表示 kind 排在 other 之前,等于 0 表示两者相等(不区分花色),大于 0 表示排在之后
+func (kind Kind) Compare(other Kind) int {
+	if t1, t2 := kind.Type(), other.Type(); t1 != t2 {
+		if t1 < t2 {
+			return -1
+		}
+		return 1
+	}
+	if kind.shape() != other.shape() {
+		if kind.shape() < other.shape() {
+			return -1
+		}
+		return 1
+	}
+	if kind.minValue != other.minValue {
+		if kind.minValue < other.minValue {
+			return -1
+		}
+		return 1
+	}
+	k1, k2 := kind.kicker.Normalize(), other.kicker.Normalize()
+	if k1 != k2 {
+		if k1 < k2 {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// 对牌型从小到大排序
+func SortKinds(kinds []Kind) {
+	sort.SliceStable(kinds, func(i, j int) bool {
+		return kinds[i].Compare(kinds[j]) < 0
+	})
+}
+
 var kindsMap = map[poker.Type]Kind{
 	poker.None:         NewKind(0, 0, 0, 0),
 	poker.Single1:      NewKind(1, 1, 0, 0),
+	poker.Single3:      NewKind(3, 1, 0, 0),
+	poker.Single4:      NewKind(4, 1, 0, 0),
 	poker.Single5:      NewKind(5, 1, 0, 0),
 	poker.Single6:      NewKind(6, 1, 0, 0),
 	poker.Single7:      NewKind(7, 1, 0, 0),
@@ -613,6 +902,7 @@ var kindsMap = map[poker.Type]Kind{
 	poker.Single11:     NewKind(11, 1, 0, 0),
 	poker.Single12:     NewKind(12, 1, 0, 0),
 	poker.Double1:      NewKind(1, 2, 0, 0),
+	poker.Double2:      NewKind(2, 2, 0, 0),
 	poker.Double3:      NewKind(3, 2, 0, 0),
 	poker.Double4:      NewKind(4, 2, 0, 0),
 	poker.Double5:      NewKind(5, 2, 0, 0),
@@ -637,18 +927,30 @@ var kindsMap = map[poker.Type]Kind{
 	poker.Three5:       NewKind(5, 3, 0, 0),
 	poker.Three6:       NewKind(6, 3, 0, 0),
 	poker.FourSingle1:  NewKind(1, 4, 2, 1),
+	poker.FourSingle2:  NewKind(2, 4, 4, 1),
+	poker.FourSingle3:  NewKind(3, 4, 6, 1),
 	poker.FourDouble1:  NewKind(1, 4, 2, 2),
+	poker.FourDouble2:  NewKind(2, 4, 4, 2),
+	poker.Four2:        NewKind(2, 4, 0, 0),
+	poker.Four3:        NewKind(3, 4, 0, 0),
+	poker.Four4:        NewKind(4, 4, 0, 0),
+	poker.Four5:        NewKind(5, 4, 0, 0),
 	poker.Bomb:         NewKind(1, 4, 0, 0),
 	poker.Rocket:       NewKind(2, 1, 0, 0),
 }
 
 var kindsRevMap map[uint32]poker.Type
 
+// 按编号从小到大排列的所有牌型
+var kindTypes []poker.Type
+
 func init() {
 	kindsRevMap = make(map[uint32]poker.Type)
 	for k, v := range kindsMap {
 		kindsRevMap[v.shape()] = k
+		kindTypes = append(kindTypes, k)
 	}
+	sort.Slice(kindTypes, func(i, j int) bool { return kindTypes[i] < kindTypes[j] })
 }
 
 /*
@@ -769,24 +1071,42 @@ func (pos Position) IsFriend(landlord, player Position) bool {
 	return player == pos || (player != landlord && pos != landlord)
 }
 
+// 获取农民的队友位置,地主没有队友
+func (pos Position) Partner(landlord Position) Position {
+	if pos == landlord {
+		return BadPosition
+	}
+	return Position(NumPlayer*(NumPlayer-1)/2) - pos - landlord
+}
+
 // 游戏状态
 type State struct {
 	// 各玩家剩余牌的正则化表示
 	pokers [NumPlayer]PokerSet
 	// 地主位置
 	landlord Position
-	// 出牌游戏过程累计倍数
-	multi int16
+	// 规则
+	opt Options
+	// 出过的炸弹个数
+	bombs int8
+	// 出过的火箭个数
+	rockets int8
 	// 地主出牌次数
 	landlordPlayTimes int8
 	// 农民出牌次数
 	farmerPlayTimes int8
 }
 
+// 使用默认规则创建游戏状态
 func NewState(pokers [NumPlayer]PokerSet, landlord Position) State {
+	return NewStateWithOptions(pokers, landlord, DefaultOptions)
+}
+
+// 使用指定规则创建游戏状态
+func NewStateWithOptions(pokers [NumPlayer]PokerSet, landlord Position, opt Options) State {
 	s := State{
 		landlord: landlord,
-		multi:    1,
+		opt:      opt,
 	}
 	for i := range s.pokers {
 		s.pokers[i] = pokers[i].Normalize()
@@ -808,7 +1128,9 @@ func (state State) String() string {
 func (state *State) Copy(from State) {
 	copy(state.pokers[:], from.pokers[:])
 	state.landlord = from.landlord
-	state.multi = from.multi
+	state.opt = from.opt
+	state.bombs = from.bombs
+	state.rockets = from.rockets
 	state.landlordPlayTimes = from.landlordPlayTimes
 	state.farmerPlayTimes = from.farmerPlayTimes
 }
@@ -849,6 +1171,22 @@ type Action struct {
 	prob float64
 }
 
+func NewAction(player Position, kind Kind) Action {
+	return Action{player: player, kind: kind}
+}
+
+func (act Action) Player() Position { return act.player }
+func (act Action) Kind() Kind       { return act.kind }
+func (act Action) Prob() float64    { return act.prob }
+
+func (act Action) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Player Position `json:"player"`
+		Kind   Kind     `json:"kind"`
+		Prob   float64  `json:"prob"`
+	}{act.player, act.kind, act.prob})
+}
+
 func (act Action) String() string {
 	return fmt.Sprintf("{pos: %v, kind: %v, prob: %.4g}", act.player, act.kind, act.prob)
 }
@@ -870,9 +1208,11 @@ func (act Action) Do(from State) State {
 		to.farmerPlayTimes++
 	}
 
-	// 累计炸弹倍数
-	if act.kind.IsBomb() || act.kind.IsRocket() {
-		to.multi <<= 1
+	// 累计炸弹和火箭个数
+	if act.kind.IsBomb() {
+		to.bombs++
+	} else if act.kind.IsRocket() {
+		to.rockets++
 	}
 
 	return to
@@ -882,11 +1222,19 @@ func (act Action) Equal(act2 Action) bool {
 	return act.player == act2.player && act.kind.Equal(act2.kind)
 }
 
-type PolicyFunc func(root *Node) ([]Action, float64, int)
+// 策略函数: 返回 node 之后出牌玩家的所有合法动作(含先验概率),以该玩家视角计算的局面估值和推演时选择的动作下标
+type PolicyFunc func(node *Node) ([]Action, float64, int)
+
+// 推演函数: 从 leaf 推演到游戏结束,返回以 root 之后出牌玩家视角计算的结果
 type RolloutFunc func(root, leaf *Node) float64
 
 // 获取所有合法操作
 func getLegalActions(node *Node) ([]Action, float64, int) {
+	return legalActions(node, nil)
+}
+
+// node 之后出牌的玩家及其所有合法出牌
+func (node *Node) legalKinds() (Position, []Kind) {
 	var (
 		kind1 Kind
 		kind2 = node.action.kind
@@ -895,8 +1243,12 @@ func getLegalActions(node *Node) ([]Action, float64, int) {
 	if node.parent != nil {
 		kind1 = node.parent.action.kind
 	}
+	return next, node.state.pokers[next].MatchAll(kind1, kind2, node.state.opt)
+}
 
-	kinds := node.state.pokers[next].Match(kind1, kind2, DefaultOptions, 256)
+// 获取所有合法操作,并使用随机数 r 按权重选择一个, r 为空时使用全局随机数
+func legalActions(node *Node, r *rand.Rand) ([]Action, float64, int) {
+	next, kinds := node.legalKinds()
 
 	// 计算权重,如果所有权重都为 0,则所有权重都加 1
 	total := float64(0)
@@ -906,44 +1258,62 @@ func getLegalActions(node *Node) ([]Action, float64, int) {
 	added := float64(0)
 	if total == 0 {
 		added = 1
-		total += added * float64(len(kinds))
+	}
+
+	// 农民配合: 根据队友的出牌和剩余牌数调整权重
+	lead, leader := node.lead()
+	weights := make([]float64, len(kinds))
+	total = 0
+	for i, kind := range kinds {
+		weights[i] = (float64(kind.ext) + added) * cooperationWeight(node.state, lead, leader, next, kind)
+		total += weights[i]
 	}
 
 	// 创建 Actions
 	var actions []Action
-	for _, kind := range kinds {
+	for i, kind := range kinds {
 		action := Action{
 			player: next,
 			kind:   kind,
-			prob:   (float64(kind.ext) + added) / total,
+			prob:   weights[i] / total,
 		}
 		actions = append(actions, action)
 	}
 
-	// 选择一个 Action
+	// 按权重选择一个 Action
 	index := -1
 	if len(actions) > 0 {
-		index = rand.Intn(len(actions))
+		x := float64n(r) * total
+		for index = 0; index < len(actions)-1; index++ {
+			x -= weights[index]
+			if x < 0 {
+				break
+			}
+		}
 	}
 	return actions, 0, index
 }
 
 // 游戏模拟推演
 func rollout(root, leaf *Node) float64 {
+	return rolloutWithRand(root, leaf, nil)
+}
+
+// 使用随机数 r 进行游戏模拟推演, r 为空时使用全局随机数
+func rolloutWithRand(root, leaf *Node, r *rand.Rand) float64 {
 	var (
-		curr     = leaf
-		landlord = leaf.state.landlord
-		player   = root.action.player.Next()
+		curr   = leaf
+		player = root.action.player.Next()
 	)
 	for !curr.state.Gameover() {
 		if len(curr.children) == 0 {
-			actions, _, index := getLegalActions(curr)
+			actions, _, index := legalActions(curr, r)
 			for _, action := range actions {
 				child := NewNode(curr, action, action.Do(curr.state))
 				curr.children = append(curr.children, child)
 			}
 			if index < 0 || index >= len(curr.children) {
-				index = rand.Intn(len(curr.children))
+				index = intn(r, len(curr.children))
 			}
 			curr = curr.children[index]
 		} else {
@@ -954,9 +1324,9 @@ func rollout(root, leaf *Node) float64 {
 				}
 			}
 			if totalUnvisited == 0 {
-				curr = curr.children[rand.Intn(len(curr.children))]
+				curr = curr.children[intn(r, len(curr.children))]
 			} else {
-				selected := rand.Intn(totalUnvisited)
+				selected := intn(r, totalUnvisited)
 				tmp := 0
 				index := 0
 				for i, child := range curr.children {
@@ -972,13 +1342,14 @@ func rollout(root, leaf *Node) float64 {
 			}
 		}
 	}
-	// 计算结果值
-	winner := curr.state.Winner()
-	multi := float64(curr.state.multi)
-	if curr.state.IsSpring(winner) {
-		multi *= 2
-	}
-	if winner.IsFriend(landlord, player) {
+	return curr.state.score(player)
+}
+
+// 以 player 视角计算已经结束的游戏的结果值: 胜负乘以按规则计算的倍数
+func (state State) score(player Position) float64 {
+	winner := state.Winner()
+	multi := float64(state.opt.Multiple(int(state.bombs), int(state.rockets), state.IsSpring(winner)))
+	if winner.IsFriend(state.landlord, player) {
 		return multi
 	}
 	return -multi
@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/gopherd/landlord/poker"
@@ -214,3 +215,367 @@ func TestMatch(t *testing.T) {
 	kinds = pset.Match(Kind{}, Kind{}, DefaultOptions, 256)
 	t.Logf("pset %v all %d kinds: %v", pset, len(kinds), kinds)
 }
+
+// 解析 ParsePokerSet 格式的牌集,未指定花色的相同牌值依次使用黑桃,红桃,梅花,方块
+func mustParsePokerSet(t *testing.T, s string) PokerSet {
+	t.Helper()
+	pset, err := ParsePokerSet(s)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return pset
+}
+
+func mustClassify(t *testing.T, typ poker.Type, opt Options, s string) Kind {
+	t.Helper()
+	pset := mustParsePokerSet(t, s)
+	for _, kind := range Classify(pset, opt) {
+		if kind.Type() == typ {
+			return kind
+		}
+	}
+	t.Fatalf("%v is not classified as %d: %v", pset, typ, Classify(pset, opt))
+	return Kind{}
+}
+
+func TestClassify(t *testing.T) {
+	kinds := Classify(mustParsePokerSet(t, "333 444 555 666"), DefaultOptions)
+	if len(kinds) != 3 || kinds[0].Type() != poker.ThreeSingle3 || kinds[1].Type() != poker.ThreeSingle3 || kinds[2].Type() != poker.Three4 {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+	// 333444555+666 和 444555666+333 两种解释,后者可以管上前者
+	if kinds[0].minValue != poker.P3 || kinds[1].minValue != poker.P4 || !kinds[1].Beats(kinds[0], DefaultOptions) {
+		t.Fatalf("should classify both readings: %v", kinds)
+	}
+	if kinds := Classify(mustParsePokerSet(t, "3 4"), DefaultOptions); len(kinds) != 0 {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+	if kinds := Classify(rocket, DefaultOptions); len(kinds) != 1 || !kinds[0].IsRocket() {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+
+	opt := DefaultOptions
+	opt.CanTrioWithoutKicker = false
+	opt.CanFourTwoWithKickers = false
+	bomb := mustClassify(t, poker.Bomb, opt, "2222")
+	if !bomb.Valid(opt) {
+		t.Fatalf("bomb of 2 should be valid")
+	}
+	for _, pset := range []PokerSet{
+		mustParsePokerSet(t, "2222 3 4"),
+		mustParsePokerSet(t, "555"),
+	} {
+		for _, kind := range Classify(pset, opt) {
+			t.Fatalf("unexpected kind %v with %+v", kind, opt)
+		}
+	}
+}
+
+func TestKindBinary(t *testing.T) {
+	hand := mustParsePokerSet(t, "333 444 5555 6 7 # $")
+	kinds := append(hand.MatchAll(Kind{}, Kind{}, DefaultOptions), Kind{})
+	for _, kind := range kinds {
+		data, err := kind.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal %v: %v", kind, err)
+		}
+		var kind2 Kind
+		if err := kind2.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unmarshal %v: %v", kind, err)
+		}
+		if kind2 != kind {
+			t.Fatalf("kind %v changed to %v", kind, kind2)
+		}
+	}
+	bad := NewKind(1, 2, 0, 0).extend(mustParsePokerSet(t, "3"), 0)
+	data, _ := bad.MarshalBinary()
+	if err := new(Kind).UnmarshalBinary(data); err == nil {
+		t.Fatalf("pair with one poker should be invalid")
+	}
+}
+
+func TestBeats(t *testing.T) {
+	opt := DefaultOptions
+	single3 := mustClassify(t, poker.Single1, opt, "3")
+	single2 := mustClassify(t, poker.Single1, opt, "2")
+	joker := mustClassify(t, poker.Single1, opt, "$")
+	pair3 := mustClassify(t, poker.Double1, opt, "33")
+	chain := mustClassify(t, poker.Single5, opt, "3 4 5 6 7")
+	chain2 := mustClassify(t, poker.Single5, opt, "4 5 6 7 8")
+	chain6 := mustClassify(t, poker.Single6, opt, "4 5 6 7 8 9")
+	trio := mustClassify(t, poker.ThreeSingle1, opt, "888 3")
+	trio2 := mustClassify(t, poker.ThreeSingle1, opt, "999 3")
+	trioPair := mustClassify(t, poker.ThreeDouble1, opt, "999 33")
+	bomb3 := mustClassify(t, poker.Bomb, opt, "3333")
+	bomb2 := mustClassify(t, poker.Bomb, opt, "2222")
+	rocketKind := mustClassify(t, poker.Rocket, opt, "# $")
+
+	for _, tc := range []struct {
+		a, b  Kind
+		beats bool
+	}{
+		{single2, single3, true},
+		{single3, single2, false},
+		{single3, single3, false},
+		{joker, single2, true},
+		{pair3, single3, false},
+		{chain2, chain, true},
+		{chain6, chain, false},
+		{trio2, trio, true},
+		{trioPair, trio, false},
+		{bomb3, chain6, true},
+		{bomb3, single2, true},
+		{bomb2, bomb3, true},
+		{bomb3, bomb2, false},
+		{rocketKind, bomb2, true},
+		{bomb2, rocketKind, false},
+		{rocketKind, rocketKind, false},
+		{single3, Kind{}, true},
+		{Kind{}, single3, false},
+	} {
+		if tc.a.Beats(tc.b, opt) != tc.beats {
+			t.Errorf("%v beats %v: expected %v", tc.a, tc.b, tc.beats)
+		}
+	}
+
+	// 不允许三带对的规则下三带对不能管上任何牌
+	opt.CanTrioWithPair = false
+	if trioPair.Beats(Kind{}, opt) {
+		t.Errorf("%v should be invalid with %+v", trioPair, opt)
+	}
+}
+
+func TestSortKinds(t *testing.T) {
+	var pset PokerSet
+	pset.Add(mustParsePokerSet(t, "33 44 55 2"))
+	kinds := pset.Match(Kind{}, Kind{}, DefaultOptions, 256)
+	SortKinds(kinds)
+	for i := 1; i < len(kinds); i++ {
+		if kinds[i-1].Compare(kinds[i]) > 0 {
+			t.Fatalf("kinds not sorted: %v", kinds)
+		}
+		if kinds[i-1].Type() == kinds[i].Type() && kinds[i-1].minValue < kinds[i].minValue &&
+			!kinds[i].Beats(kinds[i-1], DefaultOptions) {
+			t.Fatalf("%v should beat %v", kinds[i], kinds[i-1])
+		}
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	pset := mustParsePokerSet(t, "333 444 555 6 7 8 9 XX JJ QQ K")
+	kinds := pset.MatchAll(Kind{}, Kind{}, DefaultOptions)
+	if len(kinds) <= 256 {
+		t.Fatalf("expected more than 256 kinds, got %d", len(kinds))
+	}
+	if len(pset.Match(Kind{}, Kind{}, DefaultOptions, 256)) != 256 {
+		t.Fatalf("Match should be truncated to limit")
+	}
+
+	seen := make(map[kindKey]bool)
+	for i, kind := range kinds {
+		if seen[kind.key()] {
+			t.Fatalf("duplicated kind %v", kind)
+		}
+		seen[kind.key()] = true
+		if !pset.Contains(kind.Pokers()) {
+			t.Fatalf("kind %v not in %v", kind, pset)
+		}
+		if i > 0 && kinds[i-1].Type() > kind.Type() {
+			t.Fatalf("kinds not ordered by type: %v before %v", kinds[i-1], kind)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		again := pset.MatchAll(Kind{}, Kind{}, DefaultOptions)
+		if len(again) != len(kinds) {
+			t.Fatalf("nondeterministic kinds count")
+		}
+		for j := range again {
+			if again[j] != kinds[j] {
+				t.Fatalf("nondeterministic kind at %d: %v vs %v", j, again[j], kinds[j])
+			}
+		}
+	}
+
+	// 跟牌时依次是同牌型更大的牌,炸弹,火箭,不出
+	pset = mustParsePokerSet(t, "6666 9 # $")
+	lead := mustClassify(t, poker.Single1, DefaultOptions, "8")
+	kinds = pset.MatchAll(Kind{}, lead, DefaultOptions)
+	types := []poker.Type{poker.Single1, poker.Single1, poker.Single1, poker.Bomb, poker.Rocket, poker.None}
+	if len(kinds) != len(types) {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+	for i, kind := range kinds {
+		if kind.Type() != types[i] {
+			t.Fatalf("unexpected kind %v at %d, expected type %d", kind, i, types[i])
+		}
+	}
+
+	// 同一组牌主干不同的解释都要保留,和 Classify 一致
+	pset = mustParsePokerSet(t, "3 3 3 4 4 4 5 5 5 6 6 6")
+	var readings []Kind
+	for _, kind := range pset.MatchAll(Kind{}, Kind{}, DefaultOptions) {
+		if kind.Pokers() == pset {
+			readings = append(readings, kind)
+		}
+	}
+	if classified := Classify(pset, DefaultOptions); len(readings) != len(classified) {
+		t.Fatalf("MatchAll readings %v, Classify %v", readings, classified)
+	}
+	for _, kind := range Classify(pset, DefaultOptions) {
+		found := false
+		for _, reading := range readings {
+			found = found || reading.Equal(kind)
+		}
+		if !found {
+			t.Fatalf("MatchAll misses %v", kind)
+		}
+	}
+
+	var visited int
+	pset = mustParsePokerSet(t, "6666 9 # $")
+	pset.WalkMatch(Kind{}, lead, DefaultOptions, func(kind Kind) bool {
+		visited++
+		return kind.IsBomb()
+	})
+	if visited != 4 {
+		t.Fatalf("walk should terminate at bomb, visited %d", visited)
+	}
+}
+
+func TestSpaceShuttle(t *testing.T) {
+	opt := DefaultOptions
+	shuttle := mustParsePokerSet(t, "3333 4444")
+	withSingles := shuttle | mustParsePokerSet(t, "5 6 7 8")
+	withPairs := shuttle | mustParsePokerSet(t, "55 66 77 88")
+
+	for _, pset := range []PokerSet{withSingles, withPairs} {
+		if kinds := Classify(pset, opt); len(kinds) != 0 {
+			t.Fatalf("space shuttle is disabled, got %v", kinds)
+		}
+	}
+	for _, kind := range withPairs.MatchAll(Kind{}, Kind{}, opt) {
+		if kind.height == 4 && kind.width > 1 {
+			t.Fatalf("space shuttle is disabled, got %v", kind)
+		}
+	}
+
+	opt.CanSpaceShuttle = true
+	four2 := mustClassify(t, poker.Four2, opt, "3333 4444")
+	mustClassify(t, poker.FourSingle2, opt, withSingles.Notation())
+	mustClassify(t, poker.FourDouble2, opt, withPairs.Notation())
+	mustClassify(t, poker.FourSingle3, opt, "9999 XXXX JJJJ 3 4 5 6 7 8")
+	mustClassify(t, poker.Four5, opt, "3333 4444 5555 6666 7777")
+	if kinds := Classify(mustParsePokerSet(t, "AAAA 2222"), opt); len(kinds) != 2 ||
+		kinds[0].Type() != poker.FourDouble1 || kinds[1].Type() != poker.FourDouble1 {
+		t.Fatalf("2 can't be in a chain: %v", kinds)
+	}
+
+	hand := mustParsePokerSet(t, "5555 6666 9")
+	kinds := hand.MatchAll(Kind{}, four2, opt)
+	if len(kinds) != 4 || kinds[0].Type() != poker.Four2 || !kinds[0].Beats(four2, opt) ||
+		!kinds[1].IsBomb() || !kinds[2].IsBomb() || kinds[3].Len() != 0 {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+	if !kinds[1].Beats(four2, opt) || four2.Beats(kinds[1], opt) {
+		t.Fatalf("bomb should beat space shuttle")
+	}
+}
+
+func TestMinLengthOfChain(t *testing.T) {
+	// 从 3 开始长度为 n 高度为 h 的连续牌
+	chain := func(n, h int) string {
+		var s string
+		for i := 0; i < n; i++ {
+			s += strings.Repeat((poker.P3 + poker.Value(i)).String(), h)
+		}
+		return s
+	}
+	for _, tc := range []struct {
+		minChain, minPairChain int
+	}{
+		{3, 2}, {3, 3}, {3, 4},
+		{4, 2}, {4, 3}, {4, 4},
+		{5, 2}, {5, 3}, {5, 4},
+		{6, 2}, {6, 3}, {6, 4},
+	} {
+		opt := DefaultOptions
+		opt.MinLengthOfChain = tc.minChain
+		opt.MinLengthOfPairChain = tc.minPairChain
+
+		for n := 2; n <= 12; n++ {
+			pset := mustParsePokerSet(t, chain(n, 1))
+			valid := false
+			for _, kind := range Classify(pset, opt) {
+				valid = valid || (kind.height == 1 && int(kind.width) == n)
+			}
+			if valid != (n >= tc.minChain && n >= 3) {
+				t.Errorf("%+v: straight of length %d valid = %v", tc, n, valid)
+			}
+		}
+		for n := 2; n <= 10; n++ {
+			pset := mustParsePokerSet(t, chain(n, 2))
+			valid := false
+			for _, kind := range Classify(pset, opt) {
+				valid = valid || (kind.height == 2 && int(kind.width) == n)
+			}
+			if valid != (n >= tc.minPairChain) {
+				t.Errorf("%+v: pair chain of length %d valid = %v", tc, n, valid)
+			}
+		}
+
+		hand := mustParsePokerSet(t, chain(6, 2)+"#$")
+		var hasRocket bool
+		for _, kind := range hand.MatchAll(Kind{}, Kind{}, opt) {
+			switch {
+			case kind.IsRocket():
+				hasRocket = true
+			case kind.height == 1 && kind.width > 1 && int(kind.width) < tc.minChain,
+				kind.height == 2 && kind.width > 1 && int(kind.width) < tc.minPairChain:
+				t.Errorf("%+v: unexpected kind %v", tc, kind)
+			}
+		}
+		if !hasRocket {
+			t.Errorf("%+v: rocket missing", tc)
+		}
+	}
+}
+
+// 搜索使用状态中的规则生成合法出牌和计算得分
+func TestStateOptions(t *testing.T) {
+	var pokers [NumPlayer]PokerSet
+	pokers[0] = mustParsePokerSet(t, "4 4 4 4 5 5 5 5 6 6 6 6 7 7 7 7 8 8 8 8 3")
+	pokers[1] = mustParsePokerSet(t, "9")
+	pokers[2] = mustParsePokerSet(t, "10")
+	for _, tc := range []struct {
+		opt      Options
+		kicker   bool
+		expected float64
+	}{
+		{DefaultOptions, true, 64},
+		{PresetCasual.Options, false, 16},
+	} {
+		root := new(Node)
+		root.state = NewStateWithOptions(pokers, 0, tc.opt)
+		root.action.player = 2
+		_, kinds := root.legalKinds()
+		kicker := false
+		for _, kind := range kinds {
+			kicker = kicker || kind.hasKicker()
+		}
+		if kicker != tc.kicker {
+			t.Fatalf("%+v: kinds with kicker = %v", tc.opt, kicker)
+		}
+		state := root.state
+		for _, s := range []string{"4 4 4 4", "5 5 5 5", "6 6 6 6", "7 7 7 7", "8 8 8 8"} {
+			for _, kind := range Classify(mustParsePokerSet(t, s), tc.opt) {
+				if kind.IsBomb() {
+					state = Action{player: 0, kind: kind}.Do(state)
+				}
+			}
+		}
+		state = Action{player: 0, kind: Classify(mustParsePokerSet(t, "3"), tc.opt)[0]}.Do(state)
+		if score := state.score(0); score != tc.expected {
+			t.Fatalf("%+v: score should be %v, got %v", tc.opt, tc.expected, score)
+		}
+	}
+}
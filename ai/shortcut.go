@@ -0,0 +1,108 @@
+package ai
+
+// 判断必胜出牌序列时最多考虑的出牌次数
+const maxShortcutPlays = 3
+
+// 不需要搜索就能决定的出牌:
+//
+// 只有一个合法出牌(包括只能不出);
+// 能一手出完所有牌;
+// 剩余的牌可以按顺序出完,并且除最后一手外每一手都没有其他玩家管得上.
+//
+// 后两种情况都是必胜的,得分只取决于之后出的炸弹和火箭的个数,所以在必胜的出牌序列中选择炸弹和火箭最多的一个
+func (node *Node) shortcut() (Action, bool) {
+	return node.shortcutWithin(maxShortcutPlays)
+}
+
+// 同 shortcut, 但必胜的出牌序列最多出 plays 手. plays 为 1 时只考虑一手出完,不依赖其他玩家的牌
+func (node *Node) shortcutWithin(plays int) (Action, bool) {
+	if node.state.Gameover() {
+		return Action{}, false
+	}
+	next, kinds := node.legalKinds()
+	if len(kinds) == 1 {
+		return Action{player: next, kind: kinds[0], prob: 1}, true
+	}
+	var others [NumPlayer - 1]PokerSet
+	for i, pos := 0, next.Next(); pos != next; i, pos = i+1, pos.Next() {
+		others[i] = node.state.pokers[pos]
+	}
+	kind, _, ok := winningPlay(node.state.pokers[next], kinds, others[:], plays, node.state.opt)
+	if !ok {
+		return Action{}, false
+	}
+	return Action{player: next, kind: kind, prob: 1}, true
+}
+
+// 在合法出牌 kinds 中寻找最多出 plays 手就能出完 hand 的必胜出牌,返回第一手出牌和整个序列中炸弹和火箭的个数
+func winningPlay(hand PokerSet, kinds []Kind, others []PokerSet, plays int, opt Options) (Kind, int, bool) {
+	var (
+		best  Kind
+		bombs = -1
+	)
+	for _, kind := range kinds {
+		if kind.Len() == 0 {
+			continue
+		}
+		n := 0
+		if kind.IsBomb() || kind.IsRocket() {
+			n = 1
+		}
+		rest := hand
+		rest.Remove(kind.Pokers())
+		if !rest.Empty() {
+			if plays <= 1 || beatable(kind, others, opt) {
+				continue
+			}
+			_, m, ok := winningPlay(rest, rest.MatchAll(Kind{}, Kind{}, opt), others, plays-1, opt)
+			if !ok {
+				continue
+			}
+			n += m
+		}
+		if n > bombs {
+			best, bombs = kind, n
+		}
+	}
+	return best, bombs, bombs >= 0
+}
+
+// 是否有其他玩家管得上 kind
+func beatable(kind Kind, others []PokerSet, opt Options) bool {
+	for _, pokers := range others {
+		found := pokers.WalkMatch(Kind{}, kind, opt, func(k Kind) bool {
+			return k.Len() > 0
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// 不经搜索直接选择 action 的结果
+func (node *Node) shortcutResult(action Action) SearchResult {
+	var child *Node
+	for _, c := range node.children {
+		if c.action.Equal(action) {
+			child = c
+			break
+		}
+	}
+	if child == nil {
+		child = NewNode(node, action, action.Do(node.state))
+		node.children = append(node.children, child)
+	}
+	return SearchResult{
+		Best: child,
+		Candidates: []Candidate{{
+			Action: child.action,
+			Visits: child.n,
+			Value:  child.q,
+			Prior:  child.p,
+			Bonus:  child.u,
+		}},
+		PV:       []Action{child.action},
+		Shortcut: true,
+	}
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 创建地主 0 先出牌的局面
+func newShortcutRoot(t *testing.T, hands ...string) *Node {
+	t.Helper()
+	var pokers [NumPlayer]PokerSet
+	for i, hand := range hands {
+		pokers[i] = mustParsePokerSet(t, hand)
+	}
+	root := new(Node)
+	root.state = NewState(pokers, 0)
+	root.action.player = 2
+	return root
+}
+
+func TestShortcut(t *testing.T) {
+	rocket := Classify(mustParsePokerSet(t, "$ #"), DefaultOptions)[0]
+	for _, tc := range []struct {
+		name  string
+		root  func() *Node
+		play  string
+		empty bool
+	}{
+		{
+			name: "only pass",
+			root: func() *Node {
+				return newShortcutRoot(t, "$ # 3", "4 5", "6 7").Move(Action{player: 0, kind: rocket})
+			},
+			empty: true,
+		},
+		{
+			name: "go out",
+			root: func() *Node { return newShortcutRoot(t, "3 3 3 4", "5", "6") },
+			play: "3 3 3 4",
+		},
+		{
+			name: "unbeatable",
+			root: func() *Node { return newShortcutRoot(t, "$ 3", "4", "K K") },
+			play: "$",
+		},
+	} {
+		root := tc.root()
+		action, ok := root.shortcut()
+		if !ok {
+			t.Fatalf("%s: should be a shortcut", tc.name)
+		}
+		if tc.empty != (action.kind.Len() == 0) || !tc.empty && action.kind.Pokers() != mustParsePokerSet(t, tc.play).Normalize() {
+			t.Fatalf("%s: bad shortcut %v", tc.name, action.kind)
+		}
+
+		// 与完整搜索的结果一致
+		r := rand.New(rand.NewSource(1))
+		result := tc.root().SearchWithRand(r,
+			func(node *Node) ([]Action, float64, int) { return legalActions(node, r) },
+			func(root, leaf *Node) float64 { return rolloutWithRand(root, leaf, r) },
+			1, 30, 2000)
+		if !result.Best.action.Equal(action) {
+			t.Fatalf("%s: shortcut %v mismatch search %v", tc.name, action.kind, result.Best.action.kind)
+		}
+	}
+
+	if _, ok := newShortcutRoot(t, "3 4 5 5", "6", "7").shortcut(); ok {
+		t.Fatalf("should not shortcut when opponents can beat every play")
+	}
+}
+
+// 必胜时选择炸弹和火箭最多的出牌序列
+func TestWinningPlayBombs(t *testing.T) {
+	hand := mustParsePokerSet(t, "8888 $ #")
+	others := []PokerSet{mustParsePokerSet(t, "3 4"), mustParsePokerSet(t, "5 6")}
+	kind, bombs, ok := winningPlay(hand, hand.MatchAll(Kind{}, Kind{}, DefaultOptions), others, maxShortcutPlays, DefaultOptions)
+	if !ok || bombs != 2 || !kind.IsBomb() && !kind.IsRocket() {
+		t.Fatalf("should play bomb and rocket separately, got %v with %d bombs", kind, bombs)
+	}
+	// 对手有更大的炸弹时先出火箭
+	others[0] = mustParsePokerSet(t, "9999")
+	kind, bombs, ok = winningPlay(hand, hand.MatchAll(Kind{}, Kind{}, DefaultOptions), others, maxShortcutPlays, DefaultOptions)
+	if !ok || bombs != 2 || !kind.IsRocket() {
+		t.Fatalf("should play rocket first, got %v with %d bombs", kind, bombs)
+	}
+}
+
+func TestRecommendPlayShortcut(t *testing.T) {
+	var pokers [NumPlayer]PokerSet
+	pokers[0] = mustParsePokerSet(t, "3 3 3 4")
+	pokers[1] = mustParsePokerSet(t, "5 6")
+	pokers[2] = mustParsePokerSet(t, "7 8")
+	player := NewMCTSAI()
+	player.SetSelf(0)
+	player.SetLandlord(0)
+	player.Start(pokers)
+	kind, result := player.Analyze("L")
+	if !result.Shortcut || result.Stats.NumIterations != 0 || kind.Pokers() != pokers[0] {
+		t.Fatalf("should go out without search, got %v, %+v", kind, result)
+	}
+	player.Play("L", 0, kind)
+}
+
+// 不记牌时对手的牌是采样的,只有一手出完才不经搜索直接出牌
+func TestForgetfulShortcut(t *testing.T) {
+	var pokers [NumPlayer]PokerSet
+	pokers[0] = mustParsePokerSet(t, "2 2 3")
+	pokers[1] = mustParsePokerSet(t, "4 5")
+	pokers[2] = mustParsePokerSet(t, "6 7")
+	for _, d := range []Difficulty{Expert, Beginner} {
+		player := NewMCTSAIWithConfig(MCTSConfig{Rand: rand.New(rand.NewSource(1)), Difficulty: d})
+		player.SetSelf(0)
+		player.SetLandlord(0)
+		player.Start(pokers)
+		_, result := player.Analyze("L")
+		if result.Shortcut != (d == Expert) {
+			t.Fatalf("%v: shortcut should be %v", d, d == Expert)
+		}
+	}
+}
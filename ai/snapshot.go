@@ -0,0 +1,342 @@
+package ai
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	snapshotMagic   = "LLAI"
+	snapshotVersion = 1
+)
+
+var ErrBadSnapshot = errors.New("ai: bad snapshot")
+
+// AI 的状态快照,用于在进程重启或者牌局迁移到其他服务器后恢复 AI, 不需要从头重新通知所有事件.
+// 快照只保存牌局信息,不保存搜索树和配置
+type Snapshot struct {
+	// 自己的位置
+	Self Position
+	// 地主位置
+	Landlord Position
+	// 底牌
+	LastPokers PokerSet
+	// 发牌后自己的手牌(不含底牌),未知时为空
+	Hand PokerSet
+	// 叫地主分数
+	Scores [NumPlayer]int
+	// 加倍倍数
+	Multiples [NumPlayer]int
+	// 是否已经开始出牌,没有开始时忽略 Pokers 和 History
+	Started bool
+	// 各玩家剩余的牌
+	Pokers [NumPlayer]PokerSet
+	// 从地主第一手开始的出牌记录,包括不出
+	History []Action
+}
+
+// 可以保存和恢复状态的 AI
+type Snapshotter interface {
+	// 当前状态的快照
+	Snapshot() Snapshot
+	// 从快照恢复状态,快照不合法时返回 ErrBadSnapshot 且状态不变
+	Restore(Snapshot) error
+}
+
+// 二进制编码: 4 字节魔数, uvarint 版本号, varint 自己和地主的位置, uvarint 底牌和手牌,
+// 各玩家 varint 叫分和加倍倍数, 1 字节是否开始出牌. 开始出牌时后面还有各玩家 uvarint 剩余的牌,
+// uvarint 出牌次数, 每次出牌的 varint 位置, uvarint 长度和 Kind 的二进制编码
+func (s Snapshot) MarshalBinary() ([]byte, error) {
+	b := append([]byte(nil), snapshotMagic...)
+	b = appendUvarint(b, snapshotVersion)
+	b = appendVarint(b, int64(s.Self))
+	b = appendVarint(b, int64(s.Landlord))
+	b = appendUvarint(b, uint64(s.LastPokers))
+	b = appendUvarint(b, uint64(s.Hand))
+	for i := 0; i < NumPlayer; i++ {
+		b = appendVarint(b, int64(s.Scores[i]))
+		b = appendVarint(b, int64(s.Multiples[i]))
+	}
+	if !s.Started {
+		return append(b, 0), nil
+	}
+	b = append(b, 1)
+	for _, pokers := range s.Pokers {
+		b = appendUvarint(b, uint64(pokers))
+	}
+	b = appendUvarint(b, uint64(len(s.History)))
+	for _, action := range s.History {
+		data, err := action.kind.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = appendVarint(b, int64(action.player))
+		b = appendUvarint(b, uint64(len(data)))
+		b = append(b, data...)
+	}
+	return b, nil
+}
+
+func (s *Snapshot) UnmarshalBinary(data []byte) error {
+	if len(data) < len(snapshotMagic) || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: bad magic", ErrBadSnapshot)
+	}
+	d := &snapshotDecoder{data: data[len(snapshotMagic):]}
+	if version := d.uvarint(); d.err == nil && version != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, version)
+	}
+	var ret Snapshot
+	ret.Self = d.position()
+	ret.Landlord = d.position()
+	ret.LastPokers = PokerSet(d.uvarint())
+	ret.Hand = PokerSet(d.uvarint())
+	for i := 0; i < NumPlayer; i++ {
+		ret.Scores[i] = int(d.varint())
+		ret.Multiples[i] = int(d.varint())
+	}
+	ret.Started = d.flag()
+	if ret.Started {
+		for i := range ret.Pokers {
+			ret.Pokers[i] = PokerSet(d.uvarint())
+		}
+		n := d.uvarint()
+		if d.err == nil && n > uint64(len(d.data)) {
+			d.fail("too many actions")
+		}
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			player := d.position()
+			var kind Kind
+			if data := d.bytes(int(d.uvarint())); d.err == nil {
+				if err := kind.UnmarshalBinary(data); err != nil {
+					d.fail(err.Error())
+				}
+			}
+			ret.History = append(ret.History, NewAction(player, kind))
+		}
+	}
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("trailing data")
+	}
+	if d.err != nil {
+		return d.err
+	}
+	*s = ret
+	return nil
+}
+
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+func appendVarint(b []byte, x int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], x)]...)
+}
+
+// 快照解码器,记录第一个错误,出错后的读取都返回零值
+type snapshotDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *snapshotDecoder) fail(reason string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrBadSnapshot, reason)
+	}
+}
+
+func (d *snapshotDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("bad uvarint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *snapshotDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail("bad varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *snapshotDecoder) position() Position {
+	x := d.varint()
+	if d.err == nil && (x < int64(BadPosition) || x >= NumPlayer) {
+		d.fail("bad position")
+	}
+	return Position(x)
+}
+
+func (d *snapshotDecoder) flag() bool {
+	b := d.bytes(1)
+	if d.err != nil {
+		return false
+	}
+	return b[0] != 0
+}
+
+func (d *snapshotDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.fail("unexpected end")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+// 当前状态的快照
+func (ai *mctsAI) Snapshot() Snapshot {
+	s := Snapshot{
+		Self:       ai.self,
+		Landlord:   ai.landlord,
+		LastPokers: ai.lastPokers,
+		Hand:       ai.hand,
+		Scores:     ai.scores,
+		Multiples:  ai.multiples,
+		Started:    ai.root != nil,
+	}
+	if s.Started {
+		s.Pokers = ai.pokers
+		s.History = append([]Action(nil), ai.history...)
+	}
+	return s
+}
+
+// 从快照恢复状态. 已经开始出牌时根据剩余的牌和出牌记录重建搜索的根节点,之前的搜索树被丢弃
+func (ai *mctsAI) Restore(s Snapshot) error {
+	if !s.Self.Valid() || !s.Landlord.Valid() {
+		return fmt.Errorf("%w: bad position", ErrBadSnapshot)
+	}
+	var hands [NumPlayer]PokerSet
+	if s.Started {
+		var err error
+		if hands, err = s.deal(); err != nil {
+			return err
+		}
+		if err := s.replay(hands, ai.cfg.options()); err != nil {
+			return err
+		}
+	}
+
+	ai.self = s.Self
+	ai.landlord = s.Landlord
+	ai.lastPokers = s.LastPokers
+	ai.hand = s.Hand
+	ai.scores = s.Scores
+	ai.multiples = s.Multiples
+	if !s.Started {
+		ai.Stop()
+		ai.pokers = [NumPlayer]PokerSet{}
+		ai.history = nil
+		return nil
+	}
+	ai.Start(hands)
+	for _, action := range s.History {
+		ai.Play(action.player.Role(ai.landlord), action.player, action.kind)
+	}
+	return nil
+}
+
+// 由剩余的牌和出牌记录还原开始出牌时各玩家的手牌,检查是否是一副完整的牌按规则发出的
+func (s Snapshot) deal() ([NumPlayer]PokerSet, error) {
+	var all PokerSet
+	hands := s.Pokers
+	for _, pokers := range s.Pokers {
+		if !FullDeck.Contains(pokers) || all&pokers != 0 {
+			return hands, fmt.Errorf("%w: bad pokers", ErrBadSnapshot)
+		}
+		all.Add(pokers)
+	}
+	for i, action := range s.History {
+		pokers := action.kind.Pokers()
+		if !action.player.Valid() {
+			return hands, fmt.Errorf("%w: action %d: bad position %v", ErrBadSnapshot, i, action.player)
+		}
+		if !FullDeck.Contains(pokers) || all&pokers != 0 {
+			return hands, fmt.Errorf("%w: action %d: bad pokers %v", ErrBadSnapshot, i, pokers)
+		}
+		all.Add(pokers)
+		hands[action.player].Add(pokers)
+	}
+	if all != FullDeck {
+		return hands, fmt.Errorf("%w: missing pokers %v", ErrBadSnapshot, FullDeck&^all)
+	}
+	for i, hand := range hands {
+		n := 17
+		if Position(i) == s.Landlord {
+			n = 20
+		}
+		if hand.Len() != n {
+			return hands, fmt.Errorf("%w: player %d dealt %d pokers", ErrBadSnapshot, i, hand.Len())
+		}
+	}
+	if s.LastPokers.Len() != 3 || !hands[s.Landlord].Contains(s.LastPokers) {
+		return hands, fmt.Errorf("%w: bad last pokers %v", ErrBadSnapshot, s.LastPokers)
+	}
+	dealt := hands[s.Self]
+	if s.Self == s.Landlord {
+		dealt.Remove(s.LastPokers)
+	}
+	if !s.Hand.Empty() && s.Hand != dealt {
+		return hands, fmt.Errorf("%w: bad hand %v", ErrBadSnapshot, s.Hand)
+	}
+	return hands, nil
+}
+
+// 从开始出牌时的手牌 hands 按规则 opt 重放出牌记录,检查每次出牌都合法并且牌局还没有结束
+func (s Snapshot) replay(hands [NumPlayer]PokerSet, opt Options) error {
+	node := new(Node)
+	node.state = NewStateWithOptions(hands, s.Landlord, opt)
+	node.action.player = s.Landlord.Prev()
+	for i, action := range s.History {
+		if node.state.Gameover() {
+			return fmt.Errorf("%w: action %d: game over", ErrBadSnapshot, i)
+		}
+		next, kinds := node.legalKinds()
+		if action.player != next {
+			return fmt.Errorf("%w: action %d: next position should be %v, but got %v", ErrBadSnapshot, i, next, action.player)
+		}
+		legal := false
+		for _, kind := range kinds {
+			if kind.Equal(action.kind) {
+				legal = true
+				break
+			}
+		}
+		if !legal {
+			return fmt.Errorf("%w: action %d: illegal play %v", ErrBadSnapshot, i, action.kind)
+		}
+		node = NewNode(node, action, action.Do(node.state))
+	}
+	if node.state.Gameover() {
+		return fmt.Errorf("%w: game over", ErrBadSnapshot)
+	}
+	return nil
+}
+
+// 从快照创建基于蒙特卡罗树搜索的 AI
+func NewMCTSAIFromSnapshot(s Snapshot, cfg MCTSConfig) (Analyzer, error) {
+	ai := &mctsAI{cfg: cfg}
+	if err := ai.Restore(s); err != nil {
+		return nil, err
+	}
+	return ai, nil
+}
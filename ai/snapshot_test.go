@@ -0,0 +1,160 @@
+package ai
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+func TestSnapshot(t *testing.T) {
+	var (
+		r        = rand.New(rand.NewSource(1))
+		landlord = Position(1)
+		cfg      = MCTSConfig{Rand: r, Iterations: 100}
+	)
+	hands, lastPokers := dealFullDeck(r, landlord)
+	player := NewMCTSAIWithConfig(cfg)
+	player.SetSelf(2)
+	player.Rob(1, 3)
+	player.SetLandlord(landlord)
+	player.SetLastPokers(lastPokers)
+	player.Double(0, 2)
+	player.Start(hands)
+	pos := landlord
+	for i := 0; i < 7; i++ {
+		player.Play(pos.Role(landlord), pos, player.RecommendPlay(pos.Role(landlord)))
+		pos = pos.Next()
+	}
+
+	snapshot := player.(Snapshotter).Snapshot()
+	data, err := snapshot.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded Snapshot
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if again, _ := decoded.MarshalBinary(); !bytes.Equal(again, data) {
+		t.Fatalf("snapshot changed after round trip")
+	}
+	restored, err := NewMCTSAIFromSnapshot(decoded, cfg)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	a, b := player.(*mctsAI), restored.(*mctsAI)
+	if a.root.state != b.root.state || a.pokers != b.pokers || a.scores != b.scores || a.multiples != b.multiples || a.next() != b.next() {
+		t.Fatalf("restored state mismatch")
+	}
+	lead1, leader1 := a.root.lead()
+	lead2, leader2 := b.root.lead()
+	if !lead1.Equal(lead2) || leader1 != leader2 {
+		t.Fatalf("restored lead mismatch")
+	}
+	if len(a.root.History()) != len(b.root.History()) {
+		t.Fatalf("restored history mismatch")
+	}
+	kind := restored.RecommendPlay(pos.Role(landlord))
+	if !b.pokers[pos].Contains(kind.Pokers()) {
+		t.Fatalf("restored AI recommends %v not in hand", kind)
+	}
+	restored.Play(pos.Role(landlord), pos, kind)
+
+	// 没有开始出牌的快照
+	before := NewMCTSAI()
+	before.SetSelf(1)
+	before.(HandSetter).SetHand(hands[1])
+	data, _ = before.(Snapshotter).Snapshot().MarshalBinary()
+	if err := decoded.UnmarshalBinary(data); err != nil || decoded.Started || decoded.Hand != hands[1] {
+		t.Fatalf("bad snapshot before start: %+v, %v", decoded, err)
+	}
+}
+
+func TestBadSnapshot(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	hands, lastPokers := dealFullDeck(r, 0)
+	good := Snapshot{Self: 0, Landlord: 0, LastPokers: lastPokers, Started: true, Pokers: hands}
+	data, _ := good.MarshalBinary()
+	for _, bad := range [][]byte{nil, []byte("LLCF"), data[:len(data)-1], append(data[:len(data):len(data)], 0)} {
+		if err := new(Snapshot).UnmarshalBinary(bad); !errors.Is(err, ErrBadSnapshot) {
+			t.Fatalf("unmarshal %x should fail, got %v", bad, err)
+		}
+	}
+
+	player, err := NewMCTSAIFromSnapshot(good, MCTSConfig{})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	kind := Hint(hands[1], Kind{}, false, DefaultOptions)[0]
+	lead := Hint(hands[0], Kind{}, false, DefaultOptions)[0]
+	played := hands
+	played[0].Remove(lead.Pokers())
+	// 比第一手小的牌不能跟
+	var small Kind
+	for _, k := range hands[1].MatchAll(Kind{}, Kind{}, DefaultOptions) {
+		if k.Type() == lead.Type() && k.Len() == lead.Len() && !k.Beats(lead, DefaultOptions) && !k.IsBomb() && !k.IsRocket() {
+			small = k
+			break
+		}
+	}
+	bads := []Snapshot{
+		{Self: BadPosition, Landlord: 0},
+		{Self: 0, Landlord: 0, Started: true},
+		{Self: 0, Landlord: 0, LastPokers: lastPokers, Started: true, Pokers: [NumPlayer]PokerSet{hands[0], hands[0], hands[2]}},
+		{Self: 0, Landlord: 0, LastPokers: lastPokers, Started: true, Pokers: hands, History: []Action{NewAction(1, kind)}},
+		{Self: 0, Landlord: 1, LastPokers: lastPokers, Started: true, Pokers: hands, History: []Action{NewAction(1, kind)}},
+		{Self: 0, Landlord: 0, Started: true, Pokers: hands},
+		{Self: 0, Landlord: 0, LastPokers: lastPokers, Hand: hands[1], Started: true, Pokers: hands},
+		{Self: 0, Landlord: 0, LastPokers: lastPokers, Started: true, Pokers: [NumPlayer]PokerSet{hands[0], hands[1]}},
+		{Self: 0, Landlord: 1, LastPokers: lastPokers, Started: true, Pokers: hands},
+	}
+	// 地主第一手出完所有牌后牌局已经结束
+	var (
+		plane = mustParsePokerSet(t, "3 3 3 4 4 4 5 5 5 6 6 6 7 7 7 8 9 J Q K")
+		over  = [NumPlayer]PokerSet{0: plane}
+		i     int
+	)
+	(FullDeck &^ plane).Walk(func(p poker.Poker) bool {
+		over[1+i%2].Add(NewPokerSetWithPoker(p))
+		i++
+		return false
+	})
+	n := len(bads)
+	for _, k := range plane.MatchAll(Kind{}, Kind{}, DefaultOptions) {
+		if k.Len() == plane.Len() {
+			rest := over
+			rest[0] = 0
+			bads = append(bads,
+				Snapshot{Self: 0, Landlord: 0, LastPokers: mustParsePokerSet(t, "J Q K"), Started: true, Pokers: rest, History: []Action{NewAction(0, k)}},
+				Snapshot{Self: 0, Landlord: 0, LastPokers: mustParsePokerSet(t, "J Q K"), Started: true, Pokers: rest, History: []Action{NewAction(0, k), NewAction(1, Kind{})}},
+			)
+			break
+		}
+	}
+	if len(bads) == n {
+		t.Fatalf("%v should be played at once", plane)
+	}
+	if small.Len() == 0 {
+		t.Fatalf("no smaller %v to follow %v", lead.Type(), lead)
+	}
+	followed := played
+	followed[1].Remove(small.Pokers())
+	bads = append(bads, Snapshot{Self: 0, Landlord: 0, LastPokers: lastPokers, Started: true, Pokers: followed, History: []Action{NewAction(0, lead), NewAction(1, small)}})
+	if s := (Snapshot{Self: 0, Landlord: 0, LastPokers: lastPokers, Started: true, Pokers: played, History: []Action{NewAction(0, lead)}}); player.(Snapshotter).Restore(s) != nil {
+		t.Fatalf("restore after first play should succeed")
+	}
+	if err := player.(Snapshotter).Restore(good); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	for _, s := range bads {
+		if err := player.(Snapshotter).Restore(s); !errors.Is(err, ErrBadSnapshot) {
+			t.Fatalf("restore %+v should fail, got %v", s, err)
+		}
+	}
+	if player.(Snapshotter).Snapshot().Pokers != hands {
+		t.Fatalf("state should not change after failed restore")
+	}
+}
@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+// 出牌风格,零值为默认风格
+//
+// 风格只影响 AI 自己的决策: 叫地主和加倍按牌力和风格调整后的门槛决定;
+// 出牌时调整搜索树中自己出牌的先验概率,并对推演的估值进行塑形
+type Style struct {
+	// 叫地主的激进程度: 叫分所需的牌力门槛降低的分数,负数表示更保守
+	Bid float64 `json:"bid"`
+	// 加倍的激进程度: 加倍所需的牌力门槛降低的分数,负数表示更保守
+	Double float64 `json:"double"`
+	// 自己出炸弹和火箭的先验概率倍数, 0 表示不调整
+	Bomb float64 `json:"bomb"`
+	// 自己出炸弹和火箭的估值加成,以一局的基本分为单位. 越早出加成越大,所以倾向于早出炸弹
+	BombBonus float64 `json:"bomb_bonus"`
+	// 节奏: 大于 0 时倾向于出张数多的牌,小于 0 时倾向于出张数少的牌和不出
+	Tempo float64 `json:"tempo"`
+}
+
+// 预定义的风格
+var (
+	StyleDefault      = Style{}
+	StyleAggressive   = Style{Bid: 2, Double: 2, Bomb: 2, Tempo: 0.5}
+	StyleConservative = Style{Bid: -2, Double: -3, Bomb: 0.5, Tempo: -0.3}
+	StyleBombHappy    = Style{Bid: 1, Double: 1, Bomb: 4, BombBonus: 0.5}
+)
+
+var styles = map[string]Style{
+	"default":      StyleDefault,
+	"aggressive":   StyleAggressive,
+	"conservative": StyleConservative,
+	"bomb-happy":   StyleBombHappy,
+}
+
+// 预定义风格的名称
+func StyleNames() []string {
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 根据名称获取预定义的风格
+func ParseStyle(name string) (Style, error) {
+	if style, ok := styles[name]; ok {
+		return style, nil
+	}
+	return Style{}, fmt.Errorf("ai: unknown style %q", name)
+}
+
+// 叫 1,2,3 分和加倍所需的默认牌力
+const (
+	bidStrength1   = 7
+	bidStrength2   = 9
+	bidStrength3   = 11
+	doubleStrength = 10
+)
+
+// 手牌的牌力: 大王 4 分,小王 3 分,每张 2 为 2 分,每张 A 为 1 分,每个 2 以外的炸弹 4 分
+func handStrength(hand PokerSet) float64 {
+	var strength float64
+	if hand.Count(poker.PJoker2) > 0 {
+		strength += 4
+	}
+	if hand.Count(poker.PJoker1) > 0 {
+		strength += 3
+	}
+	strength += 2 * float64(hand.Count(poker.PM2))
+	strength += float64(hand.Count(poker.PMA))
+	for v := minPokerValue; v < poker.PM2; v++ {
+		if hand.Count(v) == 4 {
+			strength += 4
+		}
+	}
+	return strength
+}
+
+// 按牌力建议叫分, maxBid 为之前的最高叫分,叫不了更高的分时返回 0
+func (style Style) rob(hand PokerSet, maxBid int) int {
+	var (
+		strength = handStrength(hand) + style.Bid
+		score    int
+	)
+	switch {
+	case strength >= bidStrength3:
+		score = 3
+	case strength >= bidStrength2:
+		score = 2
+	case strength >= bidStrength1:
+		score = 1
+	}
+	if score <= maxBid {
+		return 0
+	}
+	return score
+}
+
+// 按牌力建议是否加倍
+func (style Style) double(hand PokerSet) bool {
+	return handStrength(hand)+style.Double >= doubleStrength
+}
+
+// 调整 player 自己出牌的先验概率: 炸弹和火箭乘以 Bomb, 出 n 张牌乘以 (n+1)^Tempo
+func (style Style) policy(player Position, policyFn PolicyFunc) PolicyFunc {
+	if style.Bomb == 0 && style.Tempo == 0 {
+		return policyFn
+	}
+	return func(node *Node) ([]Action, float64, int) {
+		actions, value, index := policyFn(node)
+		if len(actions) == 0 || actions[0].player != player {
+			return actions, value, index
+		}
+		var total float64
+		for i := range actions {
+			kind := actions[i].kind
+			if style.Bomb > 0 && (kind.IsBomb() || kind.IsRocket()) {
+				actions[i].prob *= style.Bomb
+			}
+			if style.Tempo != 0 {
+				actions[i].prob *= math.Pow(float64(kind.Len()+1), style.Tempo)
+			}
+			total += actions[i].prob
+		}
+		if total > 0 {
+			for i := range actions {
+				actions[i].prob /= total
+			}
+		}
+		return actions, value, index
+	}
+}
+
+// 对推演结果进行塑形: 从 root 到 leaf 的路径上 player 每出一个炸弹或火箭加 BombBonus,
+// 出得越早加成越大
+func (style Style) rollout(player Position, rolloutFn RolloutFunc) RolloutFunc {
+	if style.BombBonus == 0 || rolloutFn == nil {
+		return rolloutFn
+	}
+	return func(root, leaf *Node) float64 {
+		value := rolloutFn(root, leaf)
+		for curr := leaf; curr != nil && curr != root; curr = curr.parent {
+			kind := curr.action.kind
+			if curr.action.player == player && (kind.IsBomb() || kind.IsRocket()) {
+				value += style.BombBonus / float64(curr.depth-root.depth)
+			}
+		}
+		return value
+	}
+}
@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"testing"
+)
+
+func TestParseStyle(t *testing.T) {
+	for _, name := range StyleNames() {
+		if _, err := ParseStyle(name); err != nil {
+			t.Fatalf("parse style %q: %v", name, err)
+		}
+	}
+	if style, err := ParseStyle("aggressive"); err != nil || style != StyleAggressive {
+		t.Fatalf("bad aggressive style %+v, %v", style, err)
+	}
+	if _, err := ParseStyle("reckless"); err == nil {
+		t.Fatalf("parse unknown style should fail")
+	}
+}
+
+func TestStyleBidding(t *testing.T) {
+	// 大王, 2, A, A: 牌力 8
+	marginal := mustParsePokerSet(t, "$ 2 A A 3 4 5 7 8 9 J Q K 6 6 X X")
+	if s := handStrength(marginal); s != 8 {
+		t.Fatalf("strength should be 8, got %v", s)
+	}
+	if score := StyleDefault.rob(marginal, 0); score != 1 {
+		t.Fatalf("default style should bid 1, got %d", score)
+	}
+	if score := StyleAggressive.rob(marginal, 0); score != 2 {
+		t.Fatalf("aggressive style should bid 2, got %d", score)
+	}
+	if score := StyleConservative.rob(marginal, 0); score != 0 {
+		t.Fatalf("conservative style should not bid, got %d", score)
+	}
+	if score := StyleAggressive.rob(marginal, 2); score != 0 {
+		t.Fatalf("should not bid when unable to outbid, got %d", score)
+	}
+	if StyleConservative.double(marginal) || !StyleAggressive.double(mustParsePokerSet(t, "$ # 2 A A")) {
+		t.Fatalf("bad double decision")
+	}
+
+	player := NewMCTSAIWithConfig(MCTSConfig{Style: StyleAggressive})
+	player.SetSelf(1)
+	player.Rob(0, 1)
+	player.(HandSetter).SetHand(marginal)
+	if score := player.RecommendRob(); score != 2 {
+		t.Fatalf("aggressive AI should bid 2 over 1, got %d", score)
+	}
+	player.SetLandlord(1)
+	player.SetLastPokers(mustParsePokerSet(t, "#"))
+	if multi := player.RecommendDouble(); multi != 2 {
+		t.Fatalf("aggressive landlord with small joker should double, got %d", multi)
+	}
+}
+
+func TestStylePolicy(t *testing.T) {
+	var pokers [NumPlayer]PokerSet
+	pokers[0] = mustParsePokerSet(t, "3 4 5 6 7 8888 $ #")
+	pokers[1] = mustParsePokerSet(t, "9 9 X")
+	pokers[2] = mustParsePokerSet(t, "J Q K")
+	root := new(Node)
+	root.state = NewState(pokers, 0)
+	root.action.player = 2
+
+	base := func(node *Node) ([]Action, float64, int) { return legalActions(node, nil) }
+	prior := func(policyFn PolicyFunc, pred func(Kind) bool) float64 {
+		actions, _, _ := policyFn(root)
+		var p float64
+		for _, action := range actions {
+			if pred(action.kind) {
+				p += action.prob
+			}
+		}
+		return p
+	}
+	isBomb := func(kind Kind) bool { return kind.IsBomb() || kind.IsRocket() }
+	isChain := func(kind Kind) bool { return kind.Len() >= 5 && !kind.IsBomb() }
+	if prior(StyleBombHappy.policy(0, base), isBomb) <= prior(base, isBomb) {
+		t.Fatalf("bomb-happy style should prefer bombs")
+	}
+	if prior(StyleAggressive.policy(0, base), isChain) <= prior(base, isChain) {
+		t.Fatalf("aggressive style should prefer playing more pokers")
+	}
+	if prior(StyleBombHappy.policy(1, base), isBomb) != prior(base, isBomb) {
+		t.Fatalf("style should not change other players' priors")
+	}
+
+	// 估值塑形: 根节点之后自己出的炸弹有加成
+	var bomb Kind
+	for _, kind := range Classify(mustParsePokerSet(t, "8888"), DefaultOptions) {
+		if kind.IsBomb() {
+			bomb = kind
+		}
+	}
+	leaf := NewNode(root, Action{player: 0, kind: bomb}, Action{player: 0, kind: bomb}.Do(root.state))
+	rollout := func(root, leaf *Node) float64 { return 1 }
+	if v := StyleBombHappy.rollout(0, rollout)(root, leaf); v != 1+StyleBombHappy.BombBonus {
+		t.Fatalf("bomb should get bonus, got %v", v)
+	}
+	if v := StyleBombHappy.rollout(1, rollout)(root, leaf); v != 1 {
+		t.Fatalf("other's bomb should not get bonus, got %v", v)
+	}
+}
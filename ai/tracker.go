@@ -0,0 +1,259 @@
+package ai
+
+import (
+	"math/rand"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+// 对手让牌(不出)推断出的约束: 对手很可能没有能管上 kind 的同牌型的牌(炸弹和火箭除外)
+type constraint struct {
+	player Position
+	kind   Kind
+}
+
+// 违反软约束时的采样接受概率
+const constraintPenalty = 0.1
+
+// 采样时的最大重试次数
+const maxSampleRetries = 64
+
+// 记牌器
+//
+// 记牌器以某个玩家的视角记录已经出过的牌,推断对手还可能持有的牌,
+// 可用于展示给玩家的记牌功能,也可以为不完全信息搜索采样对手手牌
+type Tracker struct {
+	// 自己的位置
+	self Position
+	// 地主位置
+	landlord Position
+	// 整副牌
+	deck PokerSet
+	// 自己的手牌(包含还没出的和已经出的)
+	hand PokerSet
+	// 底牌
+	lastPokers PokerSet
+	// 各玩家已经出的牌
+	played [NumPlayer]PokerSet
+	// 各玩家剩余牌数
+	nums [NumPlayer]int
+	// 当前需要管的牌及出牌者
+	lead   Kind
+	leader Position
+	// 软约束
+	constraints []constraint
+	// 规则,用于判断软约束
+	opt Options
+}
+
+// 使用一副完整的牌创建记牌器, hand 为自己开始出牌时的手牌(地主包含底牌)
+func NewTracker(self, landlord Position, hand, lastPokers PokerSet) *Tracker {
+	return NewTrackerWithDeck(FullDeck, self, landlord, hand, lastPokers)
+}
+
+// 使用指定的牌创建记牌器,各玩家的初始张数由牌的张数推算: 农民张数相等,地主多出底牌的张数
+func NewTrackerWithDeck(deck PokerSet, self, landlord Position, hand, lastPokers PokerSet) *Tracker {
+	t := &Tracker{
+		self:       self,
+		landlord:   landlord,
+		deck:       deck,
+		hand:       hand,
+		lastPokers: lastPokers,
+		leader:     BadPosition,
+		opt:        DefaultOptions,
+	}
+	others := deck.Len() - hand.Len()
+	for i := range t.nums {
+		pos := Position(i)
+		switch {
+		case pos == self:
+			t.nums[i] = hand.Len()
+		case self == landlord:
+			t.nums[i] = others / 2
+		case pos == landlord:
+			t.nums[i] = (others + lastPokers.Len()) / 2
+		default:
+			t.nums[i] = (others - lastPokers.Len()) / 2
+		}
+	}
+	return t
+}
+
+// 设置规则,默认为 DefaultOptions
+func (t *Tracker) SetOptions(opt Options) {
+	t.opt = opt
+}
+
+// 记录出牌
+func (t *Tracker) Play(pos Position, kind Kind) {
+	if kind.Len() == 0 {
+		if t.leader.Valid() && !pos.IsFriend(t.landlord, t.leader) {
+			t.constraints = append(t.constraints, constraint{player: pos, kind: t.lead})
+		}
+		if pos.Next() == t.leader {
+			// 其他玩家都不出,出牌者重新出牌
+			t.lead = Kind{}
+			t.leader = BadPosition
+		}
+		return
+	}
+	pokers := kind.Pokers()
+	t.played[pos].Add(pokers)
+	t.nums[pos] -= pokers.Len()
+	t.lead = kind
+	t.leader = pos
+}
+
+// 玩家已经出的牌
+func (t *Tracker) Played(pos Position) PokerSet { return t.played[pos] }
+
+// 玩家剩余牌数
+func (t *Tracker) NumPokers(pos Position) int { return t.nums[pos] }
+
+// 自己剩余的手牌
+func (t *Tracker) Hand() PokerSet { return t.hand &^ t.played[t.self] }
+
+// 所有玩家已经出的牌
+func (t *Tracker) AllPlayed() PokerSet {
+	var ret PokerSet
+	for _, pokers := range t.played {
+		ret.Add(pokers)
+	}
+	return ret
+}
+
+// 还没出现过的牌: 不在自己手中也还没被打出的牌(记牌器展示的剩余牌)
+func (t *Tracker) Unseen() PokerSet {
+	return t.deck &^ t.hand &^ t.AllPlayed()
+}
+
+// 已知某个对手一定持有的牌: 地主还没出的底牌
+func (t *Tracker) Known(pos Position) PokerSet {
+	if pos == t.self {
+		return t.Hand()
+	}
+	if pos == t.landlord {
+		return t.lastPokers &^ t.played[pos]
+	}
+	return emptyPokerSet
+}
+
+// 判断玩家是否很可能没有能管上 kind 的同牌型的牌(不拆炸弹和火箭)
+func (t *Tracker) Unlikely(pos Position, kind Kind) bool {
+	for _, c := range t.constraints {
+		if c.player == pos && c.kind.shape() == kind.shape() && c.kind.minValue <= kind.minValue {
+			return true
+		}
+	}
+	return false
+}
+
+// 统计 pokers 违反了玩家 pos 的多少个软约束
+func (t *Tracker) violations(pos Position, pokers PokerSet) int {
+	var (
+		count int
+		rest  = pokers
+	)
+	for value := minPokerValue; value <= poker.PM2; value++ {
+		rest.Remove(NewBomb(value))
+	}
+	rest.Remove(rocket)
+	for _, c := range t.constraints {
+		if c.player == pos && len(rest.match(c.kind, true, t.opt, nil, 1)) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// 按已知信息采样所有玩家的手牌,用于不完全信息搜索的确定化(determinization)
+// 采样结果严格满足已知的牌和张数,并按软约束降低不太可能的分配出现的概率
+func (t *Tracker) Sample(r *rand.Rand) [NumPlayer]PokerSet {
+	var (
+		pool  []PokerSet
+		known [NumPlayer]PokerSet
+	)
+	for i := range known {
+		known[i] = t.Known(Position(i))
+	}
+	var located PokerSet
+	for _, pokers := range known {
+		located.Add(pokers)
+	}
+	(t.Unseen() &^ located).Walk(func(p poker.Poker) bool {
+		pool = append(pool, NewPokerSetWithPoker(p))
+		return false
+	})
+
+	var ret [NumPlayer]PokerSet
+	for retry := 0; retry < maxSampleRetries; retry++ {
+		shuffle(r, len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+		ret = known
+		offset := 0
+		for i := range ret {
+			if Position(i) == t.self {
+				continue
+			}
+			n := t.nums[i] - known[i].Len()
+			for _, p := range pool[offset : offset+n] {
+				ret[i].Add(p)
+			}
+			offset += n
+		}
+		accept := 1.0
+		for i := range ret {
+			if Position(i) != t.self {
+				for n := t.violations(Position(i), ret[i]); n > 0; n-- {
+					accept *= constraintPenalty
+				}
+			}
+		}
+		if accept >= 1 || float64n(r) < accept {
+			break
+		}
+	}
+	return ret
+}
+
+// 估计各玩家持有每张牌的概率
+func (t *Tracker) Estimate(r *rand.Rand, samples int) *Estimate {
+	e := new(Estimate)
+	if samples <= 0 {
+		return e
+	}
+	for k := 0; k < samples; k++ {
+		hands := t.Sample(r)
+		for i, pokers := range hands {
+			for b := uint(0); b < uint(numValidBits); b++ {
+				if (pokers>>b)&0x1 != 0 {
+					e.probs[i][b]++
+				}
+			}
+		}
+	}
+	for i := range e.probs {
+		for b := range e.probs[i] {
+			e.probs[i][b] /= float64(samples)
+		}
+	}
+	return e
+}
+
+// 各玩家持有每张牌的概率估计
+type Estimate struct {
+	probs [NumPlayer][numValidBits]float64
+}
+
+// 玩家持有某张牌的概率
+func (e *Estimate) Probability(pos Position, p poker.Poker) float64 {
+	return e.probs[pos][uint(p.Value()-minPokerValue)<<2+uint(p.Suit())]
+}
+
+// 玩家持有某个面值的牌的期望张数
+func (e *Estimate) Expected(pos Position, value poker.Value) float64 {
+	var sum float64
+	for suit := uint(0); suit < 4; suit++ {
+		sum += e.probs[pos][uint(value-minPokerValue)<<2+suit]
+	}
+	return sum
+}
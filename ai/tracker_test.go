@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+func TestTrackerSample(t *testing.T) {
+	var (
+		r        = rand.New(rand.NewSource(1))
+		deck     = FullDeck
+		hands    [NumPlayer]PokerSet
+		landlord = Position(1)
+		self     = Position(0)
+		list     []PokerSet
+	)
+	deck.Walk(func(p poker.Poker) bool {
+		list = append(list, NewPokerSetWithPoker(p))
+		return false
+	})
+	r.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+	for i, p := range list {
+		if i < 51 {
+			hands[i%NumPlayer].Add(p)
+		} else {
+			hands[landlord].Add(p)
+		}
+	}
+	lastPokers := list[51] | list[52] | list[53]
+
+	tracker := NewTracker(self, landlord, hands[self], lastPokers)
+	for i := range hands {
+		if tracker.NumPokers(Position(i)) != hands[i].Len() {
+			t.Fatalf("player %d: expected %d pokers, got %d", i, hands[i].Len(), tracker.NumPokers(Position(i)))
+		}
+	}
+	if tracker.Unseen() != hands[1]|hands[2] {
+		t.Fatalf("bad unseen pokers: %v", tracker.Unseen())
+	}
+
+	for k := 0; k < 20; k++ {
+		sample := tracker.Sample(r)
+		if sample[self] != hands[self] {
+			t.Fatalf("sample changed own hand")
+		}
+		if !sample[landlord].Contains(lastPokers) {
+			t.Fatalf("landlord sample %v misses last pokers %v", sample[landlord], lastPokers)
+		}
+		var all PokerSet
+		for i := range sample {
+			if sample[i].Len() != hands[i].Len() {
+				t.Fatalf("player %d: sampled %d pokers, expected %d", i, sample[i].Len(), hands[i].Len())
+			}
+			all.Add(sample[i])
+		}
+		if all != deck {
+			t.Fatalf("sample doesn't cover the deck")
+		}
+	}
+
+	e := tracker.Estimate(r, 200)
+	tracker.Unseen().Walk(func(p poker.Poker) bool {
+		sum := e.Probability(1, p) + e.Probability(2, p)
+		if sum < 0.999 || sum > 1.001 {
+			t.Fatalf("probabilities of %v sum to %v", p, sum)
+		}
+		if lastPokers.HasPoker(p) && e.Probability(landlord, p) != 1 {
+			t.Fatalf("last poker %v should belong to landlord", p)
+		}
+		return false
+	})
+}
+
+func TestTrackerConstraint(t *testing.T) {
+	var (
+		landlord = Position(0)
+		self     = Position(0)
+		hand     = FullDeck
+		r        = rand.New(rand.NewSource(2))
+	)
+	// 自己持有除 8 张单牌外的所有牌,剩余的牌由两个农民平分
+	rest := mustParsePokerSet(t, "♠3♥3♠4♥4♠K♥K♠A♥A")
+	hand.Remove(rest)
+	tracker := NewTracker(self, landlord, hand, emptyPokerSet)
+
+	// 地主出单张 Q, 下家不出
+	single := NewKind(1, 1, 0, 0).extend(NewPokerSetWithPoker(poker.NewPoker(poker.Spade, poker.PQ)), emptyPokerSet)
+	tracker.Play(landlord, single)
+	tracker.Play(landlord.Next(), Kind{})
+	if !tracker.Unlikely(landlord.Next(), single) {
+		t.Fatalf("passing player should unlikely beat %v", single)
+	}
+	if tracker.Unlikely(landlord.Prev(), single) {
+		t.Fatalf("player who hasn't passed has no constraint")
+	}
+
+	e := tracker.Estimate(r, 500)
+	for _, value := range []poker.Value{poker.PK, poker.PMA} {
+		if e.Expected(landlord.Next(), value) >= e.Expected(landlord.Prev(), value) {
+			t.Fatalf("passing player should hold fewer %v: %v vs %v", value,
+				e.Expected(landlord.Next(), value), e.Expected(landlord.Prev(), value))
+		}
+	}
+}
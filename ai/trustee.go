@@ -0,0 +1,317 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/gopherd/log"
+)
+
+// 托管 AI 每次决策时确定化(determinization)采样的次数,搜索次数平均分配给每个采样
+const trusteeSamples = 4
+
+var ErrBadRecord = errors.New("ai: bad record")
+
+// 托管时的牌局记录,只包含被托管玩家自己能看到的信息
+type Record struct {
+	// 自己的位置
+	Self Position
+	// 地主位置
+	Landlord Position
+	// 开始出牌时自己的手牌,地主包含底牌
+	Hand PokerSet
+	// 底牌
+	LastPokers PokerSet
+	// 从地主第一手开始的出牌记录,包括不出
+	History []Action
+}
+
+// 托管 AI
+//
+// 与 NewMCTSAI 不同,托管 AI 不知道其他玩家的手牌: 每次决策时按记牌器采样其他玩家的手牌,
+// 对每个采样从开始出牌重放出牌记录后搜索,合并各次搜索的候选动作决定出牌
+type trusteeAI struct {
+	// 出牌前的信息,由 Start 使用
+	landlord   Position
+	lastPokers PokerSet
+	self       Position
+	hand       PokerSet
+	scores     [NumPlayer]int
+
+	// 出牌阶段的状态
+	record  Record
+	tracker *Tracker
+	cfg     MCTSConfig
+}
+
+// 根据牌局记录创建托管 AI, 用于在牌局中途(如玩家断线时)代替玩家.
+// 之后的出牌继续通过 Play 通知. 配置中的 Think 被忽略,托管 AI 总是立即决定出牌
+func NewTrusteeAI(record Record, cfg MCTSConfig) (Analyzer, error) {
+	ai := &trusteeAI{cfg: cfg}
+	ai.cfg.Think = ThinkPolicy{}
+	if err := ai.reset(record); err != nil {
+		return nil, err
+	}
+	return ai, nil
+}
+
+// 检查记录并重建记牌器
+func (ai *trusteeAI) reset(record Record) error {
+	if !record.Self.Valid() || !record.Landlord.Valid() {
+		return fmt.Errorf("%w: bad position", ErrBadRecord)
+	}
+	if record.Hand.Empty() || !FullDeck.Contains(record.Hand) || !FullDeck.Contains(record.LastPokers) {
+		return fmt.Errorf("%w: bad hand", ErrBadRecord)
+	}
+	if record.Self == record.Landlord && !record.Hand.Contains(record.LastPokers) {
+		return fmt.Errorf("%w: landlord hand without last pokers", ErrBadRecord)
+	}
+	tracker := NewTracker(record.Self, record.Landlord, record.Hand, record.LastPokers)
+	tracker.SetOptions(ai.cfg.options())
+	next := record.Landlord
+	for i, action := range record.History {
+		if err := checkRecordAction(tracker, next, action); err != nil {
+			return fmt.Errorf("%w: action %d: %v", ErrBadRecord, i, err)
+		}
+		tracker.Play(action.player, action.kind)
+		next = next.Next()
+	}
+	ai.landlord = record.Landlord
+	ai.lastPokers = record.LastPokers
+	ai.self = record.Self
+	ai.record = record
+	ai.record.History = append([]Action(nil), record.History...)
+	ai.tracker = tracker
+	return nil
+}
+
+// 检查出牌记录中的动作是否可能发生
+func checkRecordAction(tracker *Tracker, next Position, action Action) error {
+	if action.player != next {
+		return fmt.Errorf("next position should be %v, but got %v", next, action.player)
+	}
+	for i := 0; i < NumPlayer; i++ {
+		if tracker.NumPokers(Position(i)) == 0 {
+			return errors.New("game over")
+		}
+	}
+	pokers := action.kind.Pokers()
+	switch {
+	case action.player == tracker.self:
+		if !tracker.Hand().Contains(pokers) {
+			return fmt.Errorf("pokers %v not in hand", pokers)
+		}
+	case !tracker.Unseen().Contains(pokers &^ tracker.Known(action.player)):
+		return fmt.Errorf("pokers %v already seen", pokers)
+	case pokers.Len() > tracker.NumPokers(action.player):
+		return fmt.Errorf("player %v has only %d pokers", action.player, tracker.NumPokers(action.player))
+	}
+	return nil
+}
+
+func (ai *trusteeAI) SetLandlord(pos Position)      { ai.landlord = pos }
+func (ai *trusteeAI) SetLastPokers(pokers PokerSet) { ai.lastPokers = pokers }
+func (ai *trusteeAI) SetSelf(pos Position)          { ai.self = pos }
+func (ai *trusteeAI) SetHand(hand PokerSet)         { ai.hand = hand }
+
+func (ai *trusteeAI) Rob(pos Position, score int)    { ai.scores[pos.Value()] = score }
+func (ai *trusteeAI) Double(pos Position, multi int) {}
+
+// 开始出牌,只使用自己的手牌
+func (ai *trusteeAI) Start(pokers [NumPlayer]PokerSet) {
+	record := Record{
+		Self:       ai.self,
+		Landlord:   ai.landlord,
+		Hand:       pokers[ai.self],
+		LastPokers: ai.lastPokers,
+	}
+	if err := ai.reset(record); err != nil {
+		panic(err)
+	}
+}
+
+func (ai *trusteeAI) Stop() {
+	ai.tracker = nil
+}
+
+func (ai *trusteeAI) next() Position {
+	history := ai.record.History
+	if len(history) == 0 {
+		return ai.record.Landlord
+	}
+	return history[len(history)-1].player.Next()
+}
+
+// 记录出牌
+func (ai *trusteeAI) Play(tag string, pos Position, kind Kind) {
+	log.Debug().Any("pos", pos).Any("kind", kind).Print("trusteeAI Play")
+	next := ai.next()
+	if next != pos {
+		panic(fmt.Sprintf("next position should be %v, but got %v", next, pos))
+	}
+	ai.tracker.Play(pos, kind)
+	ai.record.History = append(ai.record.History, NewAction(pos, kind))
+}
+
+// 建议叫地主分数,按牌力和风格决定
+func (ai *trusteeAI) RecommendRob() int {
+	if ai.hand.Empty() {
+		return intn(ai.cfg.Rand, 4)
+	}
+	maxBid := 0
+	for _, score := range ai.scores {
+		if score > maxBid {
+			maxBid = score
+		}
+	}
+	return ai.cfg.Style.rob(ai.hand, maxBid)
+}
+
+// 建议加倍倍数,按牌力和风格决定
+func (ai *trusteeAI) RecommendDouble() int {
+	hand := ai.hand
+	if ai.tracker != nil {
+		hand = ai.record.Hand
+	} else if ai.self == ai.landlord {
+		hand.Add(ai.lastPokers)
+	}
+	if hand.Empty() {
+		return intn(ai.cfg.Rand, 2) * 2
+	}
+	if ai.cfg.Style.double(hand) {
+		return 2
+	}
+	return 0
+}
+
+func (ai *trusteeAI) RecommendPlay(tag string) Kind {
+	kind, _ := ai.Analyze(tag)
+	return kind
+}
+
+// 建议出牌并返回合并后的搜索结果. 所有采样都不需要搜索并选择同一个出牌时结果为 Shortcut,
+// 此时 Best 和 PV 来自其中一个采样,只能作为参考
+func (ai *trusteeAI) Analyze(tag string) (Kind, SearchResult) {
+	return ai.AnalyzeContext(context.Background(), tag)
+}
+
+// 同 Analyze, ctx 结束时停止搜索并只合并已经完成的采样. 停止时还没有搜索结果则建议第一个提示
+func (ai *trusteeAI) AnalyzeContext(ctx context.Context, tag string) (Kind, SearchResult) {
+	var (
+		r       = ai.cfg.Rand
+		roots   [trusteeSamples]*Node
+		merged  SearchResult
+		weights []float64
+		maxcnt  int
+	)
+	shortcut := true
+	for i := range roots {
+		if i > 0 && ctx.Err() != nil {
+			break
+		}
+		player := ai.determinize(r)
+		if i == 0 {
+			maxcnt = player.iterations()/trusteeSamples + 1
+		}
+		result := player.search(ctx, player.root, maxcnt)
+		if result.Best == nil {
+			break
+		}
+		// 难度不记牌时搜索的根节点不是 player.root
+		roots[i] = result.Best.parent
+		shortcut = shortcut && result.Shortcut && (i == 0 || result.Best.action.Equal(merged.Candidates[0].Action))
+		addStats(&merged.Stats, result.Stats)
+		for _, c := range result.Candidates {
+			// 不需要搜索的出牌相当于所有搜索都选择了它
+			if result.Shortcut {
+				c.Visits = float64(maxcnt)
+			}
+			j := 0
+			for j < len(merged.Candidates) && !merged.Candidates[j].Action.Equal(c.Action) {
+				j++
+			}
+			if j == len(merged.Candidates) {
+				merged.Candidates = append(merged.Candidates, Candidate{Action: c.Action})
+				weights = append(weights, 0)
+			}
+			m := &merged.Candidates[j]
+			m.Visits += c.Visits
+			m.Value += c.Value * c.Visits
+			m.Prior += c.Prior / trusteeSamples
+			weights[j] += c.Visits
+		}
+	}
+	for i := range merged.Candidates {
+		if weights[i] > 0 {
+			merged.Candidates[i].Value /= weights[i]
+		}
+	}
+	sort.SliceStable(merged.Candidates, func(i, j int) bool {
+		return merged.Candidates[i].Visits > merged.Candidates[j].Visits
+	})
+	merged.Shortcut = shortcut
+	if len(merged.Candidates) == 0 {
+		return FirstHint(ai.tracker.Hand(), ai.tracker.lead, ai.tracker.leader, ai.record.Landlord, ai.record.Self, ai.cfg.options()), merged
+	}
+
+	// 选择该动作访问次数最多的采样中的节点作为 Best
+	best := merged.Candidates[0].Action
+	for _, root := range roots {
+		if root == nil {
+			continue
+		}
+		for _, child := range root.children {
+			if child.action.Equal(best) && (merged.Best == nil || child.n > merged.Best.n) {
+				merged.Best = child
+			}
+		}
+	}
+	for curr := merged.Best; curr != nil; curr = curr.mostVisitedChild() {
+		merged.PV = append(merged.PV, curr.action)
+	}
+	if shortcut {
+		merged.Candidates = merged.Candidates[:1]
+	}
+
+	action := best
+	if !shortcut {
+		action = pickAction(r, ai.cfg.Difficulty, merged, ai.tracker.lead)
+	}
+	return realizeKind(ai.tracker.Hand(), action.kind), merged
+}
+
+// 按记牌器采样其他玩家的手牌,构造一个完全信息的 AI 并重放出牌记录
+func (ai *trusteeAI) determinize(r *rand.Rand) *mctsAI {
+	hands := ai.tracker.Sample(r)
+	for i := range hands {
+		hands[i].Add(ai.tracker.Played(Position(i)))
+	}
+	player := &mctsAI{
+		landlord:   ai.record.Landlord,
+		lastPokers: ai.record.LastPokers,
+		self:       ai.record.Self,
+		sampled:    true,
+		cfg:        ai.cfg,
+	}
+	player.Start(hands)
+	for _, action := range ai.record.History {
+		player.Play("", action.player, action.kind)
+	}
+	return player
+}
+
+// 累加搜索统计数据
+func addStats(dst *SearchStats, src SearchStats) {
+	dst.NumIterations += src.NumIterations
+	dst.NumNewNodes += src.NumNewNodes
+	dst.NumTraverseNode += src.NumTraverseNode
+	dst.NumTreeNodes += src.NumTreeNodes
+	dst.TimeOfTraverse += src.TimeOfTraverse
+	dst.TimeOfExpand += src.TimeOfExpand
+	dst.TimeOfRollout += src.TimeOfRollout
+	dst.TimeOfBackup += src.TimeOfBackup
+	dst.TimeOfSearch += src.TimeOfSearch
+}
@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/gopherd/landlord/poker"
+)
+
+// 用一副完整的牌发牌,返回开始出牌时的手牌(地主包含底牌)和底牌
+func dealFullDeck(r *rand.Rand, landlord Position) ([NumPlayer]PokerSet, PokerSet) {
+	var pokers []poker.Poker
+	FullDeck.Walk(func(p poker.Poker) bool {
+		pokers = append(pokers, p)
+		return false
+	})
+	r.Shuffle(len(pokers), func(i, j int) { pokers[i], pokers[j] = pokers[j], pokers[i] })
+	var (
+		hands      [NumPlayer]PokerSet
+		lastPokers PokerSet
+	)
+	for i, p := range pokers {
+		if i < 3 {
+			lastPokers.Add(NewPokerSetWithPoker(p))
+		} else {
+			hands[i%NumPlayer].Add(NewPokerSetWithPoker(p))
+		}
+	}
+	hands[landlord].Add(lastPokers)
+	return hands, lastPokers
+}
+
+func TestNewTrusteeAI(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	hands, lastPokers := dealFullDeck(r, 0)
+	hint := func(pos Position) Kind { return Hint(hands[pos], Kind{}, false, DefaultOptions)[0] }
+	record := Record{Self: 1, Landlord: 0, Hand: hands[1], LastPokers: lastPokers}
+	if _, err := NewTrusteeAI(record, MCTSConfig{}); err != nil {
+		t.Fatalf("new trustee AI: %v", err)
+	}
+	for _, tc := range []struct {
+		name   string
+		record Record
+	}{
+		{"bad self", Record{Self: BadPosition, Landlord: 0, Hand: hands[1]}},
+		{"landlord without last pokers", Record{Self: 0, Landlord: 0, Hand: hands[1], LastPokers: lastPokers}},
+		{"wrong order", Record{Self: 1, Landlord: 0, Hand: hands[1], LastPokers: lastPokers, History: []Action{NewAction(1, hint(1))}}},
+		{"not in hand", Record{Self: 1, Landlord: 0, Hand: hands[1], LastPokers: lastPokers, History: []Action{NewAction(0, hint(0)), NewAction(1, hint(2))}}},
+		{"seen pokers", Record{Self: 1, Landlord: 0, Hand: hands[1], LastPokers: lastPokers, History: []Action{NewAction(0, hint(1))}}},
+	} {
+		if _, err := NewTrusteeAI(tc.record, MCTSConfig{}); !errors.Is(err, ErrBadRecord) {
+			t.Fatalf("%s: should be bad record, got %v", tc.name, err)
+		}
+	}
+}
+
+// 牌局中途接管一个农民,之后的出牌都合法
+func TestTrusteeAI(t *testing.T) {
+	var (
+		r        = rand.New(rand.NewSource(1))
+		landlord = Position(0)
+		trustee  = Position(1)
+		cfg      = MCTSConfig{Rand: r, Iterations: 200}
+	)
+	hands, lastPokers := dealFullDeck(r, landlord)
+	var players [NumPlayer]AI
+	for i := range players {
+		players[i] = NewMCTSAIWithConfig(cfg)
+		players[i].SetSelf(Position(i))
+		players[i].SetLandlord(landlord)
+		players[i].SetLastPokers(lastPokers)
+		players[i].Start(hands)
+	}
+	var (
+		rest    = hands
+		history []Action
+	)
+	for pos := landlord; ; pos = pos.Next() {
+		if len(history) == 6 {
+			player, err := NewTrusteeAI(Record{
+				Self:       trustee,
+				Landlord:   landlord,
+				Hand:       hands[trustee],
+				LastPokers: lastPokers,
+				History:    history,
+			}, cfg)
+			if err != nil {
+				t.Fatalf("new trustee AI: %v", err)
+			}
+			players[trustee] = player
+		}
+		tag := pos.Role(landlord)
+		kind := players[pos].RecommendPlay(tag)
+		if !rest[pos].Contains(kind.Pokers()) {
+			t.Fatalf("player %v doesn't have %v", pos, kind)
+		}
+		rest[pos].Remove(kind.Pokers())
+		history = append(history, NewAction(pos, kind))
+		for _, player := range players {
+			player.Play(tag, pos, kind)
+		}
+		if rest[pos].Empty() {
+			break
+		}
+	}
+}
+
+func TestTrusteeShortcut(t *testing.T) {
+	hand := mustParsePokerSet(t, "3 3 3 4")
+	player, err := NewTrusteeAI(Record{Self: 0, Landlord: 0, Hand: hand}, MCTSConfig{Rand: rand.New(rand.NewSource(1))})
+	if err != nil {
+		t.Fatalf("new trustee AI: %v", err)
+	}
+	kind, result := player.Analyze("L")
+	if !result.Shortcut || len(result.Candidates) != 1 || kind.Pokers() != hand {
+		t.Fatalf("should go out without search, got %v, %+v", kind, result)
+	}
+}
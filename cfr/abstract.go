@@ -0,0 +1,243 @@
+package cfr
+
+import (
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/poker"
+)
+
+// 动作抽象: 合法出牌按牌型族(单牌,对子,三带一,...)和是否为连续牌型分组,
+// 每组只保留最小和最大的出牌,加上不出共 NumActions 个抽象动作
+const (
+	numFamilies = 10
+	numGroups   = numFamilies * 2
+	actionPass  = 0
+	NumActions  = 1 + numGroups*2
+)
+
+// 牌型族,按牌型编号的百位划分
+var families = map[poker.Type]int{
+	1: 0, 2: 1, 3: 2, 4: 3, 5: 4, 6: 5, 7: 6, 8: 7, 18: 8, 29: 9,
+}
+
+// 出牌所属的分组, 不出返回 -1
+func group(kind ai.Kind) int {
+	if kind.Len() == 0 {
+		return -1
+	}
+	t := kind.Type()
+	g := families[t/100] * 2
+	if t%100 >= 2 {
+		g++
+	}
+	return g
+}
+
+// 当前可用的抽象动作及其对应的实际出牌
+type choices struct {
+	mask  [NumActions]bool
+	kinds [NumActions]ai.Kind
+}
+
+// 根据所有合法出牌创建抽象动作, kinds 的顺序同 PokerSet.MatchAll
+func newChoices(kinds []ai.Kind) *choices {
+	c := new(choices)
+	for _, kind := range kinds {
+		g := group(kind)
+		if g < 0 {
+			c.mask[actionPass] = true
+			continue
+		}
+		low, high := 1+g*2, 2+g*2
+		if !c.mask[low] {
+			c.mask[low], c.kinds[low] = true, kind
+		}
+		// 最大的出牌优先比较张数再比较主干大小,都相同时保留第一个(带牌较小)
+		h := c.kinds[high]
+		if !c.mask[high] || kind.Len() > h.Len() || kind.Len() == h.Len() && kind.MinValue() > h.MinValue() {
+			c.mask[high], c.kinds[high] = true, kind
+		}
+	}
+	return c
+}
+
+// 牌数分组
+func countBucket(n int) uint64 {
+	switch {
+	case n <= 4:
+		return uint64(n - 1)
+	case n <= 6:
+		return 4
+	case n <= 9:
+		return 5
+	case n <= 14:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// 对手或队友牌数分组
+func smallBucket(n int) uint64 {
+	switch {
+	case n <= 2:
+		return uint64(n - 1)
+	case n <= 4:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// 牌面值分组: 8 以下, 9~K, A 以上
+func valueBucket(v poker.Value) uint64 {
+	switch {
+	case v <= poker.P8:
+		return 0
+	case v <= poker.PK:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// 牌局局面,记录各玩家的手牌和出牌情况
+type situation struct {
+	landlord ai.Position
+	turn     ai.Position
+	hands    [ai.NumPlayer]ai.PokerSet
+	// 所有已出的牌
+	played ai.PokerSet
+	// 需要管的牌及出牌者
+	lead   ai.Kind
+	leader ai.Position
+	// 已出的炸弹数和火箭数
+	bombs, rockets int
+	// 各玩家出牌次数(不含不出)
+	plays [ai.NumPlayer]int
+}
+
+func newSituation(hands [ai.NumPlayer]ai.PokerSet, landlord ai.Position) situation {
+	return situation{
+		landlord: landlord,
+		turn:     landlord,
+		hands:    hands,
+		leader:   ai.BadPosition,
+	}
+}
+
+// 当前出牌玩家的所有合法出牌
+func (s *situation) legal(opt ai.Options) []ai.Kind {
+	return s.hands[s.turn].MatchAll(ai.Kind{}, s.lead, opt)
+}
+
+// 当前玩家出牌
+func (s *situation) play(kind ai.Kind) {
+	pos := s.turn
+	if kind.Len() > 0 {
+		s.hands[pos].Remove(kind.Pokers())
+		s.played.Add(kind.Pokers())
+		s.plays[pos]++
+		s.lead, s.leader = kind, pos
+		if kind.IsBomb() {
+			s.bombs++
+		} else if kind.IsRocket() {
+			s.rockets++
+		}
+	}
+	s.turn = pos.Next()
+	if s.turn == s.leader {
+		s.lead, s.leader = ai.Kind{}, ai.BadPosition
+	}
+}
+
+// 赢家,牌局没有结束时返回 BadPosition
+func (s *situation) winner() ai.Position {
+	for i, hand := range s.hands {
+		if hand.Empty() {
+			return ai.Position(i)
+		}
+	}
+	return ai.BadPosition
+}
+
+// 以 pos 视角计算已经结束的牌局的收益: 胜负乘以按规则 opt 计算的炸弹,火箭和春天倍数
+func (s *situation) utility(pos ai.Position, opt ai.Options) float64 {
+	winner := s.winner()
+	spring := winner == s.landlord && s.plays[s.landlord.Next()]+s.plays[s.landlord.Prev()] == 0 ||
+		winner != s.landlord && s.plays[s.landlord] <= 1
+	multi := float64(opt.Multiple(s.bombs, s.rockets, spring))
+	if winner.IsFriend(s.landlord, pos) {
+		return multi
+	}
+	return -multi
+}
+
+// 当前出牌玩家的信息集抽象: 角色,自己和其他玩家的牌数分组,炸弹数,是否有最大的单牌,
+// 需要管的牌所在分组,大小和是否为队友所出
+func (s *situation) key() uint64 {
+	var (
+		pos     = s.turn
+		hand    = s.hands[pos]
+		role    = uint64((int(pos) - int(s.landlord) + ai.NumPlayer) % ai.NumPlayer)
+		partner = pos.Partner(s.landlord)
+		key     = role
+	)
+	push := func(x uint64, bits uint) { key = key<<bits | x }
+
+	push(countBucket(hand.Len()), 3)
+	if pos == s.landlord {
+		n := s.hands[pos.Next()].Len()
+		if m := s.hands[pos.Prev()].Len(); m < n {
+			n = m
+		}
+		push(smallBucket(n), 2)
+		push(0, 2)
+	} else {
+		push(smallBucket(s.hands[s.landlord].Len()), 2)
+		push(smallBucket(s.hands[partner].Len()), 2)
+	}
+
+	var bombs uint64
+	for v := poker.P3; v <= poker.PM2; v++ {
+		if hand.Count(v) == 4 {
+			bombs++
+		}
+	}
+	if hand.Count(poker.PJoker1) == 1 && hand.Count(poker.PJoker2) == 1 {
+		bombs++
+	}
+	if bombs > 2 {
+		bombs = 2
+	}
+	push(bombs, 2)
+
+	// 手中最大的牌是否不小于其他玩家手中最大的牌
+	var control uint64 = 1
+	unseen := ai.FullDeck
+	unseen.Remove(hand | s.played)
+	for v := poker.PJoker2; v >= poker.P3; v-- {
+		if hand.Count(v) > 0 {
+			break
+		}
+		if unseen.Count(v) > 0 {
+			control = 0
+			break
+		}
+	}
+	push(control, 1)
+
+	if s.lead.Len() == 0 {
+		push(0, 5)
+		push(0, 2)
+		push(0, 1)
+	} else {
+		push(uint64(group(s.lead)+1), 5)
+		push(valueBucket(s.lead.MinValue()), 2)
+		if s.leader == partner {
+			push(1, 1)
+		} else {
+			push(0, 1)
+		}
+	}
+	return key
+}
@@ -0,0 +1,92 @@
+// 基于反事实遗憾最小化(CFR)的斗地主 AI.
+//
+// 博弈经过抽象后用蒙特卡罗 CFR 离线训练(见 Trainer),运行时根据训练得到的平均策略表出牌(见 NewAI).
+// 与在确定化状态上搜索的 MCTS 不同, CFR 的策略直接定义在信息集上,不存在策略融合(strategy fusion)的问题,
+// 但受限于抽象的精度
+package cfr
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+type cfrAI struct {
+	table *Table
+	r     *rand.Rand
+
+	self       ai.Position
+	landlord   ai.Position
+	lastPokers ai.PokerSet
+	s          situation
+	// 叫地主同 MCTS AI
+	bidder ai.AI
+}
+
+// 创建在规则 opt 下使用策略表 table 出牌的 AI, r 用于按策略随机选择,为空时使用全局随机数.
+// 策略表中没有的信息集使用 ai.Hint 的第一个建议. 策略表不是在规则 opt 下训练的时返回 ErrRulesMismatch
+func NewAI(table *Table, opt ai.Options, r *rand.Rand) (ai.AI, error) {
+	if table.opt != opt {
+		return nil, ErrRulesMismatch
+	}
+	return &cfrAI{
+		table:    table,
+		r:        r,
+		self:     ai.BadPosition,
+		landlord: ai.BadPosition,
+		bidder:   ai.NewMCTSAIWithConfig(ai.MCTSConfig{Rand: r, Options: opt}),
+	}, nil
+}
+
+func (c *cfrAI) SetLandlord(pos ai.Position)      { c.landlord = pos }
+func (c *cfrAI) SetLastPokers(pokers ai.PokerSet) { c.lastPokers = pokers }
+func (c *cfrAI) SetSelf(pos ai.Position)          { c.self = pos; c.bidder.SetSelf(pos) }
+func (c *cfrAI) SetHand(hand ai.PokerSet)         { c.bidder.(ai.HandSetter).SetHand(hand) }
+func (c *cfrAI) Rob(pos ai.Position, score int)   { c.bidder.Rob(pos, score) }
+func (c *cfrAI) Double(ai.Position, int)          {}
+func (c *cfrAI) Stop()                            {}
+
+func (c *cfrAI) Start(hands [ai.NumPlayer]ai.PokerSet) {
+	c.s = newSituation(hands, c.landlord)
+}
+
+func (c *cfrAI) Play(tag string, pos ai.Position, kind ai.Kind) {
+	if pos != c.s.turn {
+		panic(fmt.Sprintf("next position should be %v, but got %v", c.s.turn, pos))
+	}
+	c.s.play(kind)
+}
+
+// 建议叫地主分数,同 MCTS AI: 知道手牌时按牌力决定,否则随机选择
+func (c *cfrAI) RecommendRob() int { return c.bidder.RecommendRob() }
+
+// 不加倍
+func (c *cfrAI) RecommendDouble() int { return 0 }
+
+// 按平均策略选择一个抽象动作并出对应的牌
+func (c *cfrAI) RecommendPlay(tag string) ai.Kind {
+	if c.s.turn != c.self {
+		panic(fmt.Sprintf("it's %v's turn, not %v", c.s.turn, c.self))
+	}
+	choices := newChoices(c.s.legal(c.table.opt))
+	if strategy, ok := c.table.Strategy(c.s.key()); ok {
+		var (
+			p     [NumActions]float64
+			total float64
+		)
+		for a, ok := range choices.mask {
+			if ok {
+				p[a] = float64(strategy[a])
+				total += p[a]
+			}
+		}
+		if total > 0 {
+			for a := range p {
+				p[a] /= total
+			}
+			return choices.kinds[sampleAction(c.r, &p)]
+		}
+	}
+	return ai.FirstHint(c.s.hands[c.self], c.s.lead, c.s.leader, c.landlord, c.self, c.table.opt)
+}
@@ -0,0 +1,215 @@
+package cfr
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/game"
+	"github.com/gopherd/landlord/poker"
+)
+
+func mustParse(t *testing.T, s string) ai.PokerSet {
+	t.Helper()
+	pset, err := ai.ParsePokerSet(s)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return pset
+}
+
+func TestChoices(t *testing.T) {
+	hand := mustParse(t, "3 4 5 6 7 99 KK")
+	c := newChoices(hand.MatchAll(ai.Kind{}, ai.Kind{}, ai.DefaultOptions))
+	if c.mask[actionPass] {
+		t.Fatalf("pass should not be available when leading")
+	}
+	// 分组: 单牌 0, 顺子 1, 对子 2
+	const single, chain, pair = 0, 1, 2
+	if !c.mask[1+single*2] || !c.mask[2+single*2] {
+		t.Fatalf("singles should be available")
+	}
+	if low, high := c.kinds[1+single*2], c.kinds[2+single*2]; low.MinValue() != poker.P3 || high.MinValue() != poker.PK {
+		t.Fatalf("singles should be 3 and K, got %v and %v", low, high)
+	}
+	if low, high := c.kinds[1+pair*2], c.kinds[2+pair*2]; low.MinValue() != poker.P9 || high.MinValue() != poker.PK {
+		t.Fatalf("pairs should be 99 and KK, got %v and %v", low, high)
+	}
+	if low, high := c.kinds[1+chain*2], c.kinds[2+chain*2]; low.Len() != 5 || high.Len() != 5 {
+		t.Fatalf("chains should be 34567, got %v and %v", low, high)
+	}
+	for a, ok := range c.mask {
+		if ok && a != actionPass && c.kinds[a].Len() == 0 {
+			t.Fatalf("action %d has no kind", a)
+		}
+	}
+
+	lead := ai.Classify(mustParse(t, "QQ"), ai.DefaultOptions)[0]
+	c = newChoices(hand.MatchAll(ai.Kind{}, lead, ai.DefaultOptions))
+	if !c.mask[actionPass] || !c.mask[1+pair*2] || c.kinds[1+pair*2].MinValue() != poker.PK {
+		t.Fatalf("should be able to pass or play KK over QQ")
+	}
+}
+
+func TestSituation(t *testing.T) {
+	var hands [ai.NumPlayer]ai.PokerSet
+	hands[0] = mustParse(t, "3 4 55")
+	hands[1] = mustParse(t, "6 77")
+	hands[2] = mustParse(t, "8 99")
+	s := newSituation(hands, 0)
+	key := s.key()
+	s.play(ai.Classify(mustParse(t, "55"), ai.DefaultOptions)[0])
+	if s.turn != 1 || s.leader != 0 || s.lead.Len() != 2 {
+		t.Fatalf("bad situation after playing 55: %+v", s)
+	}
+	s.play(ai.Kind{})
+	s.play(ai.Kind{})
+	if s.turn != 0 || s.lead.Len() != 0 || s.leader.Valid() {
+		t.Fatalf("lead should be cleared after all passed: %+v", s)
+	}
+	if s.key() == key {
+		t.Fatalf("key should change after playing")
+	}
+	s.play(ai.Classify(mustParse(t, "4"), ai.DefaultOptions)[0])
+	s.play(ai.Kind{})
+	s.play(ai.Kind{})
+	s.play(ai.Classify(mustParse(t, "3"), ai.DefaultOptions)[0])
+	if s.winner() != 0 {
+		t.Fatalf("landlord should win")
+	}
+	// 农民都没出过牌,春天翻倍
+	if u := s.utility(0, ai.DefaultOptions); u != 2 {
+		t.Fatalf("landlord utility should be 2, got %v", u)
+	}
+	if u := s.utility(1, ai.DefaultOptions); u != -2 {
+		t.Fatalf("farmer utility should be -2, got %v", u)
+	}
+}
+
+func TestTrainer(t *testing.T) {
+	train := func(seed int64) *Table {
+		trainer := NewTrainer(rand.New(rand.NewSource(seed)))
+		trainer.Train(300)
+		if trainer.Iterations() != 300 || trainer.Len() == 0 {
+			t.Fatalf("bad trainer: %d iterations, %d infosets", trainer.Iterations(), trainer.Len())
+		}
+		return trainer.Table()
+	}
+	table := train(1)
+	if table.Len() == 0 {
+		t.Fatalf("table should not be empty")
+	}
+	if !reflect.DeepEqual(table, train(1)) {
+		t.Fatalf("training with the same seed should generate the same table")
+	}
+	for key := range table.strategies {
+		strategy, _ := table.Strategy(key)
+		var total float32
+		for _, p := range strategy {
+			if p < 0 {
+				t.Fatalf("negative probability in %v", strategy)
+			}
+			total += p
+		}
+		if total < 0.999 || total > 1.001 {
+			t.Fatalf("probabilities should sum to 1, got %v", total)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := table.WriteTo(&buf); err != nil {
+		t.Fatalf("write table: %v", err)
+	}
+	data := buf.Bytes()
+	got, err := ReadTable(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read table: %v", err)
+	}
+	if !reflect.DeepEqual(got, table) {
+		t.Fatalf("table should be the same after round trip")
+	}
+	if _, err := ReadTable(bytes.NewReader(data[:len(data)-1])); !errors.Is(err, ErrBadTable) {
+		t.Fatalf("truncated table should fail with ErrBadTable, got %v", err)
+	}
+	bad := append([]byte("XXXX"), data[4:]...)
+	if _, err := ReadTable(bytes.NewReader(bad)); !errors.Is(err, ErrBadTable) {
+		t.Fatalf("bad magic should fail with ErrBadTable, got %v", err)
+	}
+	if got.Options() != ai.DefaultOptions {
+		t.Fatalf("table should keep the training rules, got %+v", got.Options())
+	}
+	if _, err := NewAI(got, ai.PresetCasual.Options, nil); !errors.Is(err, ErrRulesMismatch) {
+		t.Fatalf("table trained under other rules should be rejected, got %v", err)
+	}
+}
+
+// 使用策略表(以及缺失时的提示)完成的牌局都是合法的
+func TestAI(t *testing.T) {
+	trainer := NewTrainer(rand.New(rand.NewSource(1)))
+	trainer.Train(200)
+	table := trainer.Table()
+	for seed := int64(0); seed < 5; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		g := game.New(ai.DefaultOptions, r)
+		if err := g.Rob(g.First(), game.MaxBid); err != nil {
+			t.Fatalf("rob: %v", err)
+		}
+		for g.Phase() == game.PhaseDouble {
+			if err := g.Double(g.Turn(), 0); err != nil {
+				t.Fatalf("double: %v", err)
+			}
+		}
+		var (
+			landlord = g.Landlord()
+			bots     [ai.NumPlayer]ai.AI
+			hands    [ai.NumPlayer]ai.PokerSet
+		)
+		for i := range bots {
+			hands[i] = g.StartHand(ai.Position(i))
+		}
+		for i := range bots {
+			bot, err := NewAI(table, ai.DefaultOptions, r)
+			if err != nil {
+				t.Fatalf("new AI: %v", err)
+			}
+			bots[i] = bot
+			bots[i].SetSelf(ai.Position(i))
+			bots[i].SetLandlord(landlord)
+			bots[i].Start(hands)
+		}
+		for g.Phase() == game.PhasePlay {
+			pos := g.Turn()
+			tag := pos.Role(landlord)
+			kind := bots[pos].RecommendPlay(tag)
+			if err := g.Play(pos, kind); err != nil {
+				t.Fatalf("seed %d: %v played %v: %v", seed, pos, kind, err)
+			}
+			for _, bot := range bots {
+				bot.Play(tag, pos, kind)
+			}
+		}
+	}
+}
+
+// 叫地主和 MCTS AI 一致
+func TestAIRob(t *testing.T) {
+	table := NewTrainer(rand.New(rand.NewSource(1))).Table()
+	g := game.New(ai.DefaultOptions, rand.New(rand.NewSource(1)))
+	for seed := int64(0); seed < 10; seed++ {
+		bot, err := NewAI(table, ai.DefaultOptions, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("new AI: %v", err)
+		}
+		mcts := ai.NewMCTSAIWithConfig(ai.MCTSConfig{Rand: rand.New(rand.NewSource(seed))})
+		if seed%2 == 1 {
+			bot.(ai.HandSetter).SetHand(g.Hand(0))
+			mcts.(ai.HandSetter).SetHand(g.Hand(0))
+		}
+		if a, b := bot.RecommendRob(), mcts.RecommendRob(); a != b {
+			t.Fatalf("seed %d: CFR AI bids %d, MCTS AI bids %d", seed, a, b)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package cfr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+const (
+	magic   = "LLCF"
+	Version = 2
+
+	// 规则的 JSON 编码的最大长度
+	maxRulesSize = 4096
+)
+
+var (
+	ErrBadTable      = errors.New("cfr: bad strategy table")
+	ErrRulesMismatch = errors.New("cfr: strategy table trained under other rules")
+)
+
+// 策略表: 信息集抽象到各抽象动作概率的映射
+type Table struct {
+	// 训练时的规则
+	opt        ai.Options
+	strategies map[uint64][NumActions]float32
+}
+
+// 信息集数量
+func (t *Table) Len() int { return len(t.strategies) }
+
+// 训练时的规则
+func (t *Table) Options() ai.Options { return t.opt }
+
+// 获取信息集 key 的平均策略
+func (t *Table) Strategy(key uint64) ([NumActions]float32, bool) {
+	s, ok := t.strategies[key]
+	return s, ok
+}
+
+// 写入策略表. 文件格式(小端序): 4 字节魔数, uint32 版本号, uint32 抽象动作数, uint32 长度和 JSON 编码的规则,
+// uint32 信息集数量, 然后按 key 从小到大依次是 uint64 key, uint8 非零概率的动作数 n, n 组 uint8 动作和 float32 概率
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	rules, err := json.Marshal(t.opt)
+	if err != nil {
+		return 0, err
+	}
+	keys := make([]uint64, 0, len(t.strategies))
+	for key := range t.strategies {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	var (
+		cw  = &countWriter{w: bufio.NewWriter(w)}
+		buf [8]byte
+	)
+	cw.write([]byte(magic))
+	for _, x := range []uint32{Version, NumActions, uint32(len(rules))} {
+		binary.LittleEndian.PutUint32(buf[:], x)
+		cw.write(buf[:4])
+	}
+	cw.write(rules)
+	binary.LittleEndian.PutUint32(buf[:], uint32(len(keys)))
+	cw.write(buf[:4])
+	for _, key := range keys {
+		strategy := t.strategies[key]
+		binary.LittleEndian.PutUint64(buf[:], key)
+		cw.write(buf[:])
+		var count byte
+		for _, p := range strategy {
+			if p > 0 {
+				count++
+			}
+		}
+		cw.write([]byte{count})
+		for a, p := range strategy {
+			if p > 0 {
+				buf[0] = byte(a)
+				binary.LittleEndian.PutUint32(buf[1:], math.Float32bits(p))
+				cw.write(buf[:5])
+			}
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// 记录写入字节数和第一个错误
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) write(p []byte) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+// 读取策略表
+func ReadTable(r io.Reader) (*Table, error) {
+	br := bufio.NewReader(r)
+	var header [16]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadTable, err)
+	}
+	var (
+		version = binary.LittleEndian.Uint32(header[4:])
+		actions = binary.LittleEndian.Uint32(header[8:])
+		size    = binary.LittleEndian.Uint32(header[12:])
+	)
+	switch {
+	case string(header[:4]) != magic:
+		return nil, fmt.Errorf("%w: bad magic %q", ErrBadTable, header[:4])
+	case version != Version:
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadTable, version)
+	case actions != NumActions:
+		return nil, fmt.Errorf("%w: table has %d actions, want %d", ErrBadTable, actions, NumActions)
+	case size > maxRulesSize:
+		return nil, fmt.Errorf("%w: rules too long", ErrBadTable)
+	}
+	rules := make([]byte, size)
+	if _, err := io.ReadFull(br, rules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadTable, err)
+	}
+	t := &Table{strategies: make(map[uint64][NumActions]float32)}
+	if err := json.Unmarshal(rules, &t.opt); err != nil {
+		return nil, fmt.Errorf("%w: bad rules: %v", ErrBadTable, err)
+	}
+	if err := t.opt.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadTable, err)
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(br, buf[:4]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadTable, err)
+	}
+	count := binary.LittleEndian.Uint32(buf[:])
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadTable, err)
+		}
+		key := binary.LittleEndian.Uint64(buf[:])
+		n, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadTable, err)
+		}
+		var strategy [NumActions]float32
+		for j := 0; j < int(n); j++ {
+			if _, err := io.ReadFull(br, buf[:5]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrBadTable, err)
+			}
+			if int(buf[0]) >= NumActions {
+				return nil, fmt.Errorf("%w: bad action %d", ErrBadTable, buf[0])
+			}
+			strategy[buf[0]] = math.Float32frombits(binary.LittleEndian.Uint32(buf[1:]))
+		}
+		t.strategies[key] = strategy
+	}
+	return t, nil
+}
+
+// 从文件加载策略表
+func LoadTable(filename string) (*Table, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTable(f)
+}
@@ -0,0 +1,243 @@
+package cfr
+
+import (
+	"math/rand"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+// 训练时自己出牌的探索概率
+const exploration = 0.6
+
+// 信息集的累计遗憾值和累计策略
+type entry struct {
+	regret [NumActions]float32
+	sum    [NumActions]float32
+}
+
+// 遗憾匹配: 按正遗憾值的比例选择可用的动作,都不为正时均匀选择
+func (e *entry) strategy(mask *[NumActions]bool) [NumActions]float64 {
+	var (
+		sigma [NumActions]float64
+		total float64
+		count int
+	)
+	for a, ok := range mask {
+		if ok {
+			count++
+			if e.regret[a] > 0 {
+				sigma[a] = float64(e.regret[a])
+				total += sigma[a]
+			}
+		}
+	}
+	for a, ok := range mask {
+		if !ok {
+			continue
+		}
+		if total > 0 {
+			sigma[a] /= total
+		} else {
+			sigma[a] = 1 / float64(count)
+		}
+	}
+	return sigma
+}
+
+// 训练器: 在抽象的博弈上执行结果采样的蒙特卡罗 CFR (outcome sampling MCCFR),
+// 遗憾值按 CFR+ 的方式截断为非负. 每次迭代随机发牌并采样一局完整的出牌过程,
+// 依次以地主,地主下家,地主上家的视角更新
+type Trainer struct {
+	r          *rand.Rand
+	opt        ai.Options
+	entries    map[uint64]*entry
+	iterations int
+}
+
+// 使用默认规则创建训练器, r 用于发牌和采样
+func NewTrainer(r *rand.Rand) *Trainer {
+	return NewTrainerWithOptions(ai.DefaultOptions, r)
+}
+
+// 使用规则 opt 创建训练器,生成的策略表只能用于相同规则的牌局
+func NewTrainerWithOptions(opt ai.Options, r *rand.Rand) *Trainer {
+	return &Trainer{
+		r:       r,
+		opt:     opt,
+		entries: make(map[uint64]*entry),
+	}
+}
+
+// 已完成的迭代次数
+func (t *Trainer) Iterations() int { return t.iterations }
+
+// 已访问的信息集数量
+func (t *Trainer) Len() int { return len(t.entries) }
+
+// 执行 n 次迭代
+func (t *Trainer) Train(n int) {
+	for i := 0; i < n; i++ {
+		t.iterate(t.iterations % ai.NumPlayer)
+		t.iterations++
+	}
+}
+
+// 随机发牌,地主多拿 3 张底牌
+func (t *Trainer) deal() situation {
+	var pokers []ai.PokerSet
+	for b := 0; b < 64; b++ {
+		if p := ai.PokerSet(1) << b; ai.FullDeck.Contains(p) {
+			pokers = append(pokers, p)
+		}
+	}
+	t.r.Shuffle(len(pokers), func(i, j int) { pokers[i], pokers[j] = pokers[j], pokers[i] })
+	landlord := ai.Position(t.r.Intn(ai.NumPlayer))
+	var hands [ai.NumPlayer]ai.PokerSet
+	for i, p := range pokers {
+		pos := ai.Position(i % ai.NumPlayer)
+		if i >= len(pokers)-3 {
+			pos = landlord
+		}
+		hands[pos].Add(p)
+	}
+	return newSituation(hands, landlord)
+}
+
+// 采样路径上的一步
+type step struct {
+	entry  *entry
+	mask   [NumActions]bool
+	sigma  [NumActions]float64
+	action int
+	// 是否为训练玩家的决策
+	mine bool
+	// 到达该步时训练玩家和其他玩家的到达概率,以及采样概率
+	reachSelf, reachOpp, sample float64
+}
+
+// 以相对地主的角色 role 为训练玩家执行一次迭代
+func (t *Trainer) iterate(role int) {
+	var (
+		s         = t.deal()
+		traverser = ai.Position((int(s.landlord) + role) % ai.NumPlayer)
+		steps     []step
+		reachSelf = 1.0
+		reachOpp  = 1.0
+		sample    = 1.0
+	)
+	for !s.winner().Valid() {
+		c := newChoices(s.legal(t.opt))
+		key := s.key()
+		e, ok := t.entries[key]
+		if !ok {
+			e = new(entry)
+			t.entries[key] = e
+		}
+		st := step{
+			entry:     e,
+			mask:      c.mask,
+			sigma:     e.strategy(&c.mask),
+			mine:      s.turn == traverser,
+			reachSelf: reachSelf,
+			reachOpp:  reachOpp,
+			sample:    sample,
+		}
+		// 训练玩家混合均匀分布进行探索,其他玩家按当前策略出牌
+		var q [NumActions]float64
+		if st.mine {
+			var count int
+			for _, ok := range c.mask {
+				if ok {
+					count++
+				}
+			}
+			for a, ok := range c.mask {
+				if ok {
+					q[a] = exploration/float64(count) + (1-exploration)*st.sigma[a]
+				}
+			}
+		} else {
+			q = st.sigma
+		}
+		st.action = sampleAction(t.r, &q)
+		if st.mine {
+			reachSelf *= st.sigma[st.action]
+		} else {
+			reachOpp *= st.sigma[st.action]
+		}
+		sample *= q[st.action]
+		steps = append(steps, st)
+		s.play(c.kinds[st.action])
+	}
+
+	// 从后往前更新训练玩家的遗憾值和累计策略, tail 为从下一步到结束的到达概率
+	var (
+		u    = s.utility(traverser, t.opt)
+		tail = 1.0
+	)
+	for i := len(steps) - 1; i >= 0; i-- {
+		st := &steps[i]
+		if st.mine {
+			w := u * st.reachOpp / sample
+			for a, ok := range st.mask {
+				if !ok {
+					continue
+				}
+				var r float64
+				if a == st.action {
+					r = w * tail * (1 - st.sigma[a])
+				} else {
+					r = -w * tail * st.sigma[st.action]
+				}
+				if regret := float64(st.entry.regret[a]) + r; regret > 0 {
+					st.entry.regret[a] = float32(regret)
+				} else {
+					st.entry.regret[a] = 0
+				}
+				st.entry.sum[a] += float32(st.reachSelf / st.sample * st.sigma[a])
+			}
+		}
+		tail *= st.sigma[st.action]
+	}
+}
+
+// 生成平均策略表
+func (t *Trainer) Table() *Table {
+	table := &Table{opt: t.opt, strategies: make(map[uint64][NumActions]float32, len(t.entries))}
+	for key, e := range t.entries {
+		var total float32
+		for _, x := range e.sum {
+			total += x
+		}
+		if total <= 0 {
+			continue
+		}
+		var strategy [NumActions]float32
+		for a, x := range e.sum {
+			strategy[a] = x / total
+		}
+		table.strategies[key] = strategy
+	}
+	return table
+}
+
+// 按概率分布 p 选择一个动作
+func sampleAction(r *rand.Rand, p *[NumActions]float64) int {
+	var x float64
+	if r == nil {
+		x = rand.Float64()
+	} else {
+		x = r.Float64()
+	}
+	last := -1
+	for a, prob := range p {
+		if prob <= 0 {
+			continue
+		}
+		last = a
+		if x -= prob; x < 0 {
+			return a
+		}
+	}
+	return last
+}
@@ -0,0 +1,64 @@
+// landlord-bot 是一个无界面的 AI 服务,通过标准输入输出与其他语言编写的游戏引擎通信.
+//
+// 协议为每行一个 JSON 对象: 引擎每写入一行请求,服务返回一行响应.
+// 请求格式为 {"id": 1, "method": "...", "params": {...}}, 响应格式为
+// {"id": 1, "result": {...}} 或 {"id": 1, "error": "..."}.
+// 第一个请求必须是握手 hello, 参数中的 version 必须等于 ProtocolVersion:
+//
+//	{"id":1,"method":"hello","params":{"version":1,"rules":"classic"}}
+//	{"id":2,"method":"set_self","params":{"pos":1}}
+//	{"id":3,"method":"set_hand","params":{"pokers":[...]}}
+//	{"id":4,"method":"recommend_rob"}
+//	{"id":5,"method":"set_landlord","params":{"pos":0}}
+//	{"id":6,"method":"start","params":{"hands":[[...],[...],[...]]}}
+//	{"id":7,"method":"play","params":{"tag":"L","pos":0,"kind":{"type":101,"pokers":[3]}}}
+//	{"id":8,"method":"recommend_play","params":{"tag":"N"}}
+//
+// 其他方法与 ai.AI 接口一一对应,牌使用 poker.Poker 的整数值表示.
+// set_hand 是可选的,引擎发送后 AI 可以根据手牌叫地主和加倍,否则随机选择
+//
+// snapshot 返回 AI 当前状态的二进制编码, restore 在握手后从该状态恢复,
+// 用于服务崩溃重启或者牌局迁移后不需要从头重新发送所有请求
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+func main() {
+	var (
+		presets = flag.String("presets", "", "load rule presets from a JSON or YAML file")
+		level   = flag.String("difficulty", "expert", "difficulty of the bot: beginner, intermediate or expert")
+		style   = flag.String("style", "default", "play style of the bot: "+strings.Join(ai.StyleNames(), ", "))
+	)
+	flag.Parse()
+
+	if *presets != "" {
+		if _, err := ai.LoadPresetsFile(*presets); err != nil {
+			fmt.Fprintf(os.Stderr, "load presets: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	difficulty, err := ai.ParseDifficulty(*level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	st, err := ai.ParseStyle(*style)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	newAI := func(opt ai.Options) ai.AI {
+		return ai.NewMCTSAIWithConfig(ai.MCTSConfig{Difficulty: difficulty, Style: st, Options: opt})
+	}
+	if err := serve(os.Stdin, os.Stdout, newAI); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/poker"
+)
+
+// 协议版本,握手时双方必须一致
+const ProtocolVersion = 1
+
+// 协议方法,与 ai.AI 接口的方法一一对应,另外 hello 用于握手, set_hand 对应可选的 ai.HandSetter 接口,
+// snapshot 和 restore 对应可选的 ai.Snapshotter 接口
+const (
+	MethodHello           = "hello"
+	MethodSetLandlord     = "set_landlord"
+	MethodSetLastPokers   = "set_last_pokers"
+	MethodSetSelf         = "set_self"
+	MethodSetHand         = "set_hand"
+	MethodRob             = "rob"
+	MethodDouble          = "double"
+	MethodPlay            = "play"
+	MethodRecommendRob    = "recommend_rob"
+	MethodRecommendDouble = "recommend_double"
+	MethodRecommendPlay   = "recommend_play"
+	MethodStart           = "start"
+	MethodStop            = "stop"
+	MethodSnapshot        = "snapshot"
+	MethodRestore         = "restore"
+)
+
+var (
+	errHandshake      = errors.New("handshake required")
+	errVersion        = errors.New("unsupported protocol version")
+	errUnknownMethod  = errors.New("unknown method")
+	errInvalidParams  = errors.New("invalid params")
+	errInvalidPokers  = errors.New("invalid pokers")
+	errNotInitialized = errors.New("game not started")
+	errNotSupported   = errors.New("not supported by AI")
+)
+
+// 请求: 每行一个 JSON 对象
+type Request struct {
+	// 请求编号,原样返回
+	ID json.RawMessage `json:"id,omitempty"`
+	// 方法名
+	Method string `json:"method"`
+	// 参数
+	Params Params `json:"params"`
+}
+
+// 请求参数,各方法只使用其中的一部分
+type Params struct {
+	// hello: 协议版本和规则预设名称(可选,默认为 classic)
+	Version int    `json:"version,omitempty"`
+	Rules   string `json:"rules,omitempty"`
+	// set_landlord, set_self, rob, double, play: 玩家位置,这些方法必须指定
+	Pos *ai.Position `json:"pos,omitempty"`
+	// rob: 叫分
+	Score int `json:"score,omitempty"`
+	// double: 加倍倍数
+	Multi int `json:"multi,omitempty"`
+	// play, recommend_play: 标签
+	Tag string `json:"tag,omitempty"`
+	// set_last_pokers: 底牌; set_hand: 发牌后自己的手牌
+	Pokers []int32 `json:"pokers,omitempty"`
+	// play: 出的牌,为空表示不出
+	Kind *KindMessage `json:"kind,omitempty"`
+	// start: 各玩家开始出牌时的手牌
+	Hands [][]int32 `json:"hands,omitempty"`
+	// restore: snapshot 返回的 AI 状态
+	State []byte `json:"state,omitempty"`
+}
+
+// 牌型: 与 ai.Kind 的 JSON 格式相同, type 为 0 时根据牌自动判断牌型
+type KindMessage struct {
+	Type   poker.Type `json:"type"`
+	Pokers []int32    `json:"pokers"`
+}
+
+// 响应: 每个请求都有一个响应,出错时只有 error 字段
+type Response struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result *Result         `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// 响应结果,各方法只使用其中的一部分
+type Result struct {
+	// hello: 协议版本和实际使用的规则预设
+	Version int    `json:"version,omitempty"`
+	Rules   string `json:"rules,omitempty"`
+	// recommend_rob
+	Score *int `json:"score,omitempty"`
+	// recommend_double
+	Multi *int `json:"multi,omitempty"`
+	// recommend_play: 建议出的牌,牌为空表示不出
+	Kind *KindMessage `json:"kind,omitempty"`
+	// snapshot: AI 状态的二进制编码(JSON 中为 base64), 可以在新的进程中握手后 restore
+	State []byte `json:"state,omitempty"`
+}
+
+// 协议服务: 从 r 读取请求,向 w 写入响应,直到 r 结束
+type server struct {
+	newAI     func(ai.Options) ai.AI
+	ai        ai.AI
+	opt       ai.Options
+	handshake bool
+	started   bool
+}
+
+func serve(r io.Reader, w io.Writer, newAI func(ai.Options) ai.AI) error {
+	var (
+		s       = &server{newAI: newAI}
+		scanner = bufio.NewScanner(r)
+		encoder = json.NewEncoder(w)
+	)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var (
+			req  Request
+			resp Response
+		)
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = fmt.Sprintf("%v: %v", errInvalidParams, err)
+		} else {
+			resp = s.handle(req)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *server) handle(req Request) (resp Response) {
+	resp.ID = req.ID
+	defer func() {
+		// AI 在调用顺序错误时会 panic,转换为错误响应
+		if e := recover(); e != nil {
+			resp.Result = nil
+			resp.Error = fmt.Sprint(e)
+		}
+	}()
+	result, err := s.call(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Result = &result
+	}
+	return
+}
+
+func (s *server) call(method string, params Params) (Result, error) {
+	var result Result
+	if method == MethodHello {
+		return s.hello(params)
+	}
+	if !s.handshake {
+		return result, errHandshake
+	}
+	var pos ai.Position
+	switch method {
+	case MethodSetLandlord, MethodSetSelf, MethodRob, MethodDouble, MethodPlay:
+		if params.Pos == nil {
+			return result, fmt.Errorf("%w: missing pos", errInvalidParams)
+		}
+		if pos = *params.Pos; !pos.Valid() {
+			return result, fmt.Errorf("%w: bad pos %d", errInvalidParams, pos)
+		}
+	}
+	switch method {
+	case MethodSetLandlord:
+		s.ai.SetLandlord(pos)
+	case MethodSetLastPokers:
+		pokers, err := toPokerSet(params.Pokers)
+		if err != nil {
+			return result, err
+		}
+		s.ai.SetLastPokers(pokers)
+	case MethodSetSelf:
+		s.ai.SetSelf(pos)
+	case MethodSetHand:
+		pokers, err := toPokerSet(params.Pokers)
+		if err != nil {
+			return result, err
+		}
+		if h, ok := s.ai.(ai.HandSetter); ok {
+			h.SetHand(pokers)
+		}
+	case MethodRob:
+		s.ai.Rob(pos, params.Score)
+	case MethodDouble:
+		s.ai.Double(pos, params.Multi)
+	case MethodPlay:
+		if !s.started {
+			return result, errNotInitialized
+		}
+		kind, err := s.toKind(params.Kind)
+		if err != nil {
+			return result, err
+		}
+		s.ai.Play(params.Tag, pos, kind)
+	case MethodRecommendRob:
+		score := s.ai.RecommendRob()
+		result.Score = &score
+	case MethodRecommendDouble:
+		multi := s.ai.RecommendDouble()
+		result.Multi = &multi
+	case MethodRecommendPlay:
+		if !s.started {
+			return result, errNotInitialized
+		}
+		result.Kind = newKindMessage(s.ai.RecommendPlay(params.Tag))
+	case MethodStart:
+		if len(params.Hands) != ai.NumPlayer {
+			return result, fmt.Errorf("%w: need %d hands", errInvalidParams, ai.NumPlayer)
+		}
+		var hands [ai.NumPlayer]ai.PokerSet
+		for i, pokers := range params.Hands {
+			hand, err := toPokerSet(pokers)
+			if err != nil {
+				return result, err
+			}
+			hands[i] = hand
+		}
+		s.ai.Start(hands)
+		s.started = true
+	case MethodStop:
+		s.ai.Stop()
+		s.started = false
+	case MethodSnapshot:
+		snapshotter, ok := s.ai.(ai.Snapshotter)
+		if !ok {
+			return result, errNotSupported
+		}
+		data, err := snapshotter.Snapshot().MarshalBinary()
+		if err != nil {
+			return result, err
+		}
+		result.State = data
+	case MethodRestore:
+		snapshotter, ok := s.ai.(ai.Snapshotter)
+		if !ok {
+			return result, errNotSupported
+		}
+		var snapshot ai.Snapshot
+		if err := snapshot.UnmarshalBinary(params.State); err != nil {
+			return result, fmt.Errorf("%w: %v", errInvalidParams, err)
+		}
+		if err := snapshotter.Restore(snapshot); err != nil {
+			return result, fmt.Errorf("%w: %v", errInvalidParams, err)
+		}
+		s.started = snapshot.Started
+	default:
+		return result, fmt.Errorf("%w: %q", errUnknownMethod, method)
+	}
+	return result, nil
+}
+
+// 握手: 版本一致时创建新的 AI,重复握手会重新开始
+func (s *server) hello(params Params) (Result, error) {
+	if params.Version != ProtocolVersion {
+		return Result{}, fmt.Errorf("%w: %d, expected %d", errVersion, params.Version, ProtocolVersion)
+	}
+	name := params.Rules
+	if name == "" {
+		name = ai.PresetClassic.Name
+	}
+	preset, ok := ai.LookupPreset(name)
+	if !ok {
+		return Result{}, fmt.Errorf("%w: unknown rules %q", errInvalidParams, name)
+	}
+	s.opt = preset.Options
+	s.ai = s.newAI(s.opt)
+	s.handshake = true
+	s.started = false
+	return Result{Version: ProtocolVersion, Rules: preset.Name}, nil
+}
+
+// 将牌转换为牌集,不允许重复或无效的牌
+func toPokerSet(pokers []int32) (ai.PokerSet, error) {
+	var pset ai.PokerSet
+	for _, p := range pokers {
+		added := ai.NewPokerSetWithInt32s([]int32{p})
+		if !ai.FullDeck.Contains(added) || added.Len() != 1 || pset.Contains(added) {
+			return pset, fmt.Errorf("%w: %d", errInvalidPokers, p)
+		}
+		pset.Add(added)
+	}
+	return pset, nil
+}
+
+func newKindMessage(kind ai.Kind) *KindMessage {
+	msg := &KindMessage{Pokers: kind.Pokers().ToInt32s(make([]int32, 0, kind.Len()))}
+	if kind.Len() > 0 {
+		msg.Type = kind.Type()
+	}
+	return msg
+}
+
+func (s *server) toKind(msg *KindMessage) (ai.Kind, error) {
+	if msg == nil || len(msg.Pokers) == 0 {
+		return ai.Kind{}, nil
+	}
+	pokers, err := toPokerSet(msg.Pokers)
+	if err != nil {
+		return ai.Kind{}, err
+	}
+	kinds := ai.Classify(pokers, s.opt)
+	for _, kind := range kinds {
+		if msg.Type == 0 || kind.Type() == msg.Type {
+			return kind, nil
+		}
+	}
+	return ai.Kind{}, fmt.Errorf("%w: %s is not a valid play of type %d", errInvalidPokers, pokers.Notation(), msg.Type)
+}
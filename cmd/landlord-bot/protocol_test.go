@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/game"
+)
+
+// 通过管道驱动协议服务的引擎端
+type engine struct {
+	t      *testing.T
+	w      *io.PipeWriter
+	r      *bufio.Scanner
+	nextID int
+	done   chan error
+}
+
+func newEngine(t *testing.T, newAI func(ai.Options) ai.AI) *engine {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	e := &engine{
+		t:    t,
+		w:    reqW,
+		r:    bufio.NewScanner(respR),
+		done: make(chan error, 1),
+	}
+	go func() {
+		err := serve(reqR, respW, newAI)
+		respW.Close()
+		e.done <- err
+	}()
+	t.Cleanup(func() {
+		reqW.Close()
+		if err := <-e.done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return e
+}
+
+// 发送原始的一行请求并读取响应
+func (e *engine) send(line string) Response {
+	e.t.Helper()
+	if _, err := io.WriteString(e.w, line+"\n"); err != nil {
+		e.t.Fatalf("write request: %v", err)
+	}
+	if !e.r.Scan() {
+		e.t.Fatalf("read response: %v", e.r.Err())
+	}
+	var resp Response
+	if err := json.Unmarshal(e.r.Bytes(), &resp); err != nil {
+		e.t.Fatalf("bad response %q: %v", e.r.Text(), err)
+	}
+	return resp
+}
+
+func (e *engine) call(method string, params Params) Response {
+	e.t.Helper()
+	e.nextID++
+	data, err := json.Marshal(Request{
+		ID:     json.RawMessage(fmt.Sprint(e.nextID)),
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		e.t.Fatalf("marshal request: %v", err)
+	}
+	resp := e.send(string(data))
+	if string(resp.ID) != fmt.Sprint(e.nextID) {
+		e.t.Fatalf("response id %s, expected %d", resp.ID, e.nextID)
+	}
+	return resp
+}
+
+func (e *engine) mustCall(method string, params Params) *Result {
+	e.t.Helper()
+	resp := e.call(method, params)
+	if resp.Error != "" || resp.Result == nil {
+		e.t.Fatalf("%s: %s", method, resp.Error)
+	}
+	return resp.Result
+}
+
+// 记录调用的 AI
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) record(format string, args ...interface{}) {
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) SetLandlord(pos ai.Position) { r.record("SetLandlord(%d)", pos) }
+func (r *recorder) SetLastPokers(pokers ai.PokerSet) {
+	r.record("SetLastPokers(%s)", pokers.Notation())
+}
+func (r *recorder) SetSelf(pos ai.Position)                { r.record("SetSelf(%d)", pos) }
+func (r *recorder) SetHand(hand ai.PokerSet)               { r.record("SetHand(%s)", hand.Notation()) }
+func (r *recorder) Rob(pos ai.Position, score int)         { r.record("Rob(%d,%d)", pos, score) }
+func (r *recorder) Double(pos ai.Position, multi int)      { r.record("Double(%d,%d)", pos, multi) }
+func (r *recorder) RecommendRob() int                      { r.record("RecommendRob()"); return 2 }
+func (r *recorder) RecommendDouble() int                   { r.record("RecommendDouble()"); return 0 }
+func (r *recorder) Start(pokers [ai.NumPlayer]ai.PokerSet) { r.record("Start(%d)", pokers[0].Len()) }
+func (r *recorder) Stop()                                  { r.record("Stop()") }
+
+func (r *recorder) Play(tag string, pos ai.Position, kind ai.Kind) {
+	r.record("Play(%s,%d,%s)", tag, pos, kind.Pokers().Notation())
+}
+
+func (r *recorder) RecommendPlay(tag string) ai.Kind {
+	r.record("RecommendPlay(%s)", tag)
+	return ai.Classify(ai.NewPokerSetWithInt32s([]int32{5}), ai.DefaultOptions)[0]
+}
+
+// 请求参数中的玩家位置
+func posParam(pos ai.Position) *ai.Position { return &pos }
+
+func TestHandshake(t *testing.T) {
+	e := newEngine(t, func(ai.Options) ai.AI { return new(recorder) })
+	if resp := e.call(MethodSetSelf, Params{Pos: posParam(1)}); !strings.Contains(resp.Error, errHandshake.Error()) {
+		t.Fatalf("expected handshake error, got %+v", resp)
+	}
+	if resp := e.call(MethodHello, Params{Version: ProtocolVersion + 1}); !strings.Contains(resp.Error, errVersion.Error()) {
+		t.Fatalf("expected version error, got %+v", resp)
+	}
+	if resp := e.call(MethodHello, Params{Version: ProtocolVersion, Rules: "nope"}); resp.Error == "" {
+		t.Fatalf("expected unknown rules error")
+	}
+	result := e.mustCall(MethodHello, Params{Version: ProtocolVersion})
+	if result.Version != ProtocolVersion || result.Rules != ai.PresetClassic.Name {
+		t.Fatalf("bad hello result %+v", result)
+	}
+	if resp := e.send("not json"); resp.Error == "" {
+		t.Fatalf("expected error for malformed request")
+	}
+	if resp := e.call("fly", Params{}); !strings.Contains(resp.Error, errUnknownMethod.Error()) {
+		t.Fatalf("expected unknown method, got %+v", resp)
+	}
+}
+
+// 每个协议方法都对应一个 AI 方法
+func TestMethods(t *testing.T) {
+	bot := new(recorder)
+	e := newEngine(t, func(ai.Options) ai.AI { return bot })
+	e.mustCall(MethodHello, Params{Version: ProtocolVersion})
+
+	e.mustCall(MethodSetSelf, Params{Pos: posParam(1)})
+	e.mustCall(MethodSetHand, Params{Pokers: []int32{7, 8}})
+	if r := e.mustCall(MethodRecommendRob, Params{}); r.Score == nil || *r.Score != 2 {
+		t.Fatalf("bad recommend_rob result %+v", r)
+	}
+	e.mustCall(MethodRob, Params{Pos: posParam(0), Score: 3})
+	e.mustCall(MethodSetLandlord, Params{Pos: posParam(0)})
+	e.mustCall(MethodSetLastPokers, Params{Pokers: []int32{3, 4, 5}})
+	if r := e.mustCall(MethodRecommendDouble, Params{}); r.Multi == nil || *r.Multi != 0 {
+		t.Fatalf("bad recommend_double result %+v", r)
+	}
+	e.mustCall(MethodDouble, Params{Pos: posParam(0), Multi: 2})
+	if resp := e.call(MethodPlay, Params{Pos: posParam(0)}); !strings.Contains(resp.Error, errNotInitialized.Error()) {
+		t.Fatalf("play before start should fail, got %+v", resp)
+	}
+	e.mustCall(MethodStart, Params{Hands: [][]int32{{3, 4, 5, 6}, {7}, {8}}})
+	e.mustCall(MethodPlay, Params{Tag: "L", Pos: posParam(0), Kind: &KindMessage{Pokers: []int32{3}}})
+	e.mustCall(MethodPlay, Params{Tag: "N", Pos: posParam(1)})
+	if resp := e.call(MethodPlay, Params{Pos: posParam(0), Kind: &KindMessage{Pokers: []int32{3, 4}}}); !strings.Contains(resp.Error, errInvalidPokers.Error()) {
+		t.Fatalf("expected invalid pokers, got %+v", resp)
+	}
+	if resp := e.call(MethodSetLastPokers, Params{Pokers: []int32{3, 3}}); !strings.Contains(resp.Error, errInvalidPokers.Error()) {
+		t.Fatalf("expected invalid pokers, got %+v", resp)
+	}
+	if resp := e.call(MethodRob, Params{Pos: posParam(5)}); !strings.Contains(resp.Error, errInvalidParams.Error()) {
+		t.Fatalf("expected invalid params, got %+v", resp)
+	}
+	// 没有指定位置时不能当作 0 号位置
+	if resp := e.call(MethodRob, Params{Score: 3}); !strings.Contains(resp.Error, errInvalidParams.Error()) {
+		t.Fatalf("expected invalid params without pos, got %+v", resp)
+	}
+	if resp := e.call(MethodSnapshot, Params{}); !strings.Contains(resp.Error, errNotSupported.Error()) {
+		t.Fatalf("expected not supported, got %+v", resp)
+	}
+	r := e.mustCall(MethodRecommendPlay, Params{Tag: "P"})
+	if r.Kind == nil || len(r.Kind.Pokers) != 1 {
+		t.Fatalf("bad recommend_play result %+v", r)
+	}
+	e.mustCall(MethodStop, Params{})
+
+	expected := []string{
+		"SetSelf(1)",
+		"SetHand(78)",
+		"RecommendRob()",
+		"Rob(0,3)",
+		"SetLandlord(0)",
+		"SetLastPokers(345)",
+		"RecommendDouble()",
+		"Double(0,2)",
+		"Start(4)",
+		"Play(L,0,3)",
+		"Play(N,1,)",
+		"RecommendPlay(P)",
+		"Stop()",
+	}
+	if strings.Join(bot.calls, " ") != strings.Join(expected, " ") {
+		t.Fatalf("calls:\n%v\nexpected:\n%v", bot.calls, expected)
+	}
+}
+
+// 使用真实的 AI 完成一局出牌,每次推荐的出牌都必须合法
+func TestPlayGame(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip full game in short mode")
+	}
+	g := game.New(ai.DefaultOptions, rand.New(rand.NewSource(3)))
+	for g.Phase() == game.PhaseBid {
+		score := 0
+		if g.Bid() == 0 {
+			score = 1
+		}
+		if err := g.Rob(g.Turn(), score); err != nil {
+			t.Fatalf("rob: %v", err)
+		}
+	}
+	for g.Phase() == game.PhaseDouble {
+		if err := g.Double(g.Turn(), 0); err != nil {
+			t.Fatalf("double: %v", err)
+		}
+	}
+	var (
+		landlord = g.Landlord()
+		engines  [ai.NumPlayer]*engine
+		hands    [][]int32
+		decoder  = &server{opt: ai.DefaultOptions}
+	)
+	for i := 0; i < ai.NumPlayer; i++ {
+		hands = append(hands, g.Hand(ai.Position(i)).ToInt32s(nil))
+	}
+	for i := range engines {
+		e := newEngine(t, func(ai.Options) ai.AI { return ai.NewMCTSAI() })
+		e.mustCall(MethodHello, Params{Version: ProtocolVersion})
+		e.mustCall(MethodSetSelf, Params{Pos: posParam(ai.Position(i))})
+		e.mustCall(MethodSetLandlord, Params{Pos: posParam(landlord)})
+		e.mustCall(MethodSetLastPokers, Params{Pokers: g.LastPokers().ToInt32s(nil)})
+		e.mustCall(MethodStart, Params{Hands: hands})
+		engines[i] = e
+	}
+	for plays := 0; g.Phase() == game.PhasePlay; plays++ {
+		pos := g.Turn()
+		if plays == 10 {
+			// 模拟 AI 服务重启: 保存状态后在新的服务中恢复
+			state := engines[pos].mustCall(MethodSnapshot, Params{}).State
+			e := newEngine(t, func(ai.Options) ai.AI { return ai.NewMCTSAI() })
+			e.mustCall(MethodHello, Params{Version: ProtocolVersion})
+			if resp := e.call(MethodRestore, Params{State: state[:len(state)-1]}); !strings.Contains(resp.Error, errInvalidParams.Error()) {
+				t.Fatalf("expected invalid params, got %+v", resp)
+			}
+			e.mustCall(MethodRestore, Params{State: state})
+			engines[pos] = e
+		}
+		tag := pos.Role(landlord)
+		result := engines[pos].mustCall(MethodRecommendPlay, Params{Tag: tag})
+		if result.Kind == nil {
+			t.Fatalf("no kind recommended")
+		}
+		kind, err := decoder.toKind(result.Kind)
+		if err != nil {
+			t.Fatalf("bad kind %+v: %v", result.Kind, err)
+		}
+		if err := g.Play(pos, kind); err != nil {
+			t.Fatalf("player %d recommended illegal play %v: %v", pos, kind, err)
+		}
+		for _, e := range engines {
+			e.mustCall(MethodPlay, Params{Tag: tag, Pos: posParam(pos), Kind: result.Kind})
+		}
+	}
+	for _, e := range engines {
+		e.mustCall(MethodStop, Params{})
+	}
+}
@@ -0,0 +1,131 @@
+// landlord-cfr 训练 CFR 策略表,以及与 MCTS AI 进行对战评测
+//
+//	landlord-cfr train -iterations 1000000 -rules classic -out cfr.tab
+//	landlord-cfr bench -table cfr.tab -deals 200
+//
+// 策略表记录了训练时的规则,评测按该规则进行
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/cfr"
+	"github.com/gopherd/landlord/tournament"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "train":
+		err = train(os.Args[2:])
+	case "bench":
+		err = bench(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: landlord-cfr train|bench [flags]")
+	os.Exit(2)
+}
+
+func train(args []string) error {
+	var (
+		flags      = flag.NewFlagSet("train", flag.ExitOnError)
+		iterations = flags.Int("iterations", 1000000, "number of MCCFR iterations")
+		seed       = flags.Int64("seed", 1, "random seed")
+		out        = flags.String("out", "cfr.tab", "output strategy table")
+		rules      = flags.String("rules", ai.PresetClassic.Name, "rule preset name, the table can only be used under the same rules")
+	)
+	flags.Parse(args)
+
+	preset, ok := ai.LookupPreset(*rules)
+	if !ok {
+		return fmt.Errorf("unknown rules %q", *rules)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	const batch = 10000
+	var (
+		t     = cfr.NewTrainerWithOptions(preset.Options, rand.New(rand.NewSource(*seed)))
+		start = time.Now()
+	)
+	for t.Iterations() < *iterations && ctx.Err() == nil {
+		n := *iterations - t.Iterations()
+		if n > batch {
+			n = batch
+		}
+		t.Train(n)
+		if t.Iterations()%(batch*10) == 0 {
+			log.Printf("%d iterations, %d infosets, %v", t.Iterations(), t.Len(), time.Since(start).Round(time.Second))
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	table := t.Table()
+	if _, err := table.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	log.Printf("%d iterations, %d infosets written to %s", t.Iterations(), table.Len(), *out)
+	return f.Close()
+}
+
+func bench(args []string) error {
+	var (
+		flags      = flag.NewFlagSet("bench", flag.ExitOnError)
+		filename   = flags.String("table", "cfr.tab", "strategy table")
+		iterations = flags.Int("iterations", 0, "MCTS iterations per decision, 0 means decided by number of pokers")
+		cfg        tournament.Config
+	)
+	flags.IntVar(&cfg.Deals, "deals", 100, "number of deals, each played twice with roles swapped")
+	flags.IntVar(&cfg.Workers, "workers", 0, "number of deals played in parallel, 0 means number of CPUs")
+	flags.Int64Var(&cfg.Seed, "seed", 1, "random seed, deal i uses seed+i")
+	flags.Parse(args)
+
+	table, err := cfr.LoadTable(*filename)
+	if err != nil {
+		return err
+	}
+	// 按策略表训练时的规则评测
+	cfg.Options = table.Options()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	start := time.Now()
+	report, err := tournament.Match(ctx, cfg,
+		tournament.Player{Name: "cfr", New: func(r *rand.Rand, opt ai.Options) ai.AI {
+			bot, err := cfr.NewAI(table, opt, r)
+			if err != nil {
+				panic(err)
+			}
+			return bot
+		}},
+		tournament.Player{Name: "mcts", New: func(r *rand.Rand, opt ai.Options) ai.AI {
+			return ai.NewMCTSAIWithConfig(ai.MCTSConfig{Rand: r, Iterations: *iterations, Options: opt})
+		}},
+	)
+	fmt.Println(report)
+	log.Printf("done in %v", time.Since(start).Round(time.Millisecond))
+	return err
+}
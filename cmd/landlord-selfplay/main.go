@@ -0,0 +1,65 @@
+// landlord-selfplay 通过 AI 自我对局生成训练数据,文件格式见 selfplay 包
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/selfplay"
+)
+
+func main() {
+	var (
+		out   = flag.String("out", "selfplay.dat", "output file")
+		rules = flag.String("rules", ai.PresetClassic.Name, "rule preset name")
+		cfg   selfplay.Config
+	)
+	flag.IntVar(&cfg.Games, "games", 100, "number of games")
+	flag.IntVar(&cfg.Workers, "workers", 0, "number of games played in parallel, 0 means number of CPUs")
+	flag.Int64Var(&cfg.Seed, "seed", 1, "random seed, game i uses seed+i")
+	flag.IntVar(&cfg.Iterations, "iterations", 0, "MCTS iterations per decision, 0 means decided by number of pokers")
+	flag.Parse()
+
+	preset, ok := ai.LookupPreset(*rules)
+	if !ok {
+		log.Fatalf("unknown rules %q", *rules)
+	}
+	cfg.Options = preset.Options
+
+	if err := run(*out, cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// 进行自我对局并写入文件 out. 出错或中断时已经完成的对局仍然完整写入
+func run(out string, cfg selfplay.Config) (err error) {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	w, err := selfplay.NewWriter(f)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	start := time.Now()
+	stats, err := selfplay.Run(ctx, cfg, w)
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	log.Printf("%d games (%d redeals, landlord won %d), %d records in %v",
+		stats.Games, stats.Redeals, stats.LandlordWins, stats.Records, time.Since(start).Round(time.Millisecond))
+	return err
+}
@@ -0,0 +1,55 @@
+// landlord-server 是斗地主游戏服务器,接口说明见 server 包
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/server"
+)
+
+func main() {
+	var (
+		addr    = flag.String("addr", ":8080", "listen address")
+		presets = flag.String("presets", "", "load rule presets from a JSON or YAML file")
+		level   = flag.String("difficulty", "expert", "difficulty of bots: beginner, intermediate or expert")
+		style   = flag.String("style", "default", "play style of bots: "+strings.Join(ai.StyleNames(), ", "))
+		cfg     = server.DefaultConfig
+		think   ai.ThinkPolicy
+	)
+	flag.DurationVar(&cfg.TurnTimeout, "turn-timeout", cfg.TurnTimeout, "time limit of each turn")
+	flag.DurationVar(&cfg.OfflineTimeout, "offline-timeout", cfg.OfflineTimeout, "time limit of each turn for disconnected players, 0 means same as turn-timeout")
+	flag.DurationVar(&cfg.BotDelay, "bot-delay", cfg.BotDelay, "delay before bots act")
+	flag.DurationVar(&cfg.RoundDelay, "round-delay", cfg.RoundDelay, "delay before next round, negative means never")
+	flag.DurationVar(&think.Min, "think-min", 0, "minimum thinking time of bots on non-forced moves")
+	flag.DurationVar(&think.Max, "think-max", 0, "maximum thinking time of bots on critical moves, 0 means thinking time is not used")
+	flag.Int64Var(&cfg.Seed, "seed", 0, "random seed, 0 means current time")
+	flag.Parse()
+
+	if *presets != "" {
+		if _, err := ai.LoadPresetsFile(*presets); err != nil {
+			log.Fatalf("load presets: %v", err)
+		}
+	}
+	difficulty, err := ai.ParseDifficulty(*level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	st, err := ai.ParseStyle(*style)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.NewAI = func(opt ai.Options) ai.AI {
+		return ai.NewMCTSAIWithConfig(ai.MCTSConfig{Difficulty: difficulty, Style: st, Think: think, Options: opt})
+	}
+	cfg.NewTrustee = func(record ai.Record, opt ai.Options) (ai.AI, error) {
+		return ai.NewTrusteeAI(record, ai.MCTSConfig{Style: st, Options: opt})
+	}
+	s := server.New(cfg)
+	defer s.Close()
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
@@ -0,0 +1,111 @@
+// landlord 是一个终端斗地主游戏: 玩家和两个 AI 对战,用于体验和评估 AI 的出牌.
+//
+// 出牌时使用文本表示输入要出的牌(如 33344, X 表示 10, # 表示小王, $ 表示大王),其他命令:
+//
+//	p      不出
+//	h      提示,多次输入依次循环提示
+//	t      显示记牌器
+//	u      悔牌(仅练习模式)
+//	q      退出
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+func main() {
+	var (
+		seed     = flag.Int64("seed", 0, "random seed, 0 means current time")
+		rules    = flag.String("rules", ai.PresetClassic.Name, "rule preset name")
+		presets  = flag.String("presets", "", "load rule presets from a JSON or YAML file")
+		seat     = flag.Int("seat", 0, "player's seat: 0, 1 or 2")
+		practice = flag.Bool("practice", false, "practice mode: show bots' hands and allow undo")
+	)
+	flag.Parse()
+
+	if *presets != "" {
+		if _, err := ai.LoadPresetsFile(*presets); err != nil {
+			fatalf("load presets: %v", err)
+		}
+	}
+	preset, ok := ai.LookupPreset(*rules)
+	if !ok {
+		fatalf("unknown rules %q", *rules)
+	}
+	if !ai.Position(*seat).Valid() {
+		fatalf("invalid seat %d", *seat)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	t := &table{
+		in:       bufio.NewScanner(os.Stdin),
+		out:      os.Stdout,
+		rand:     rand.New(rand.NewSource(*seed)),
+		opt:      preset.Options,
+		human:    ai.Position(*seat),
+		practice: *practice,
+	}
+	fmt.Fprintf(t.out, "规则: %s, 随机种子: %d\n", preset.Name, *seed)
+	for round := 1; ; round++ {
+		fmt.Fprintf(t.out, "\n========== 第 %d 局 ==========\n", round)
+		if !t.run() {
+			break
+		}
+		fmt.Fprintf(t.out, "\n累计得分: %v\n", t.total)
+		if !t.confirm("再来一局?") {
+			break
+		}
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+// 退出游戏
+type quit struct{}
+
+func (t *table) readLine(prompt string) string {
+	fmt.Fprint(t.out, prompt)
+	if !t.in.Scan() {
+		panic(quit{})
+	}
+	line := strings.TrimSpace(t.in.Text())
+	if line == "q" || line == "quit" {
+		panic(quit{})
+	}
+	return line
+}
+
+func (t *table) confirm(prompt string) bool {
+	for {
+		switch strings.ToLower(t.readLine(prompt + " (y/n) ")) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+func (t *table) readInt(prompt string, min, max int) int {
+	for {
+		n, err := strconv.Atoi(t.readLine(prompt))
+		if err == nil && n >= min && n <= max {
+			return n
+		}
+		fmt.Fprintf(t.out, "请输入 %d 到 %d 之间的数字\n", min, max)
+	}
+}
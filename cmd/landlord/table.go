@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/game"
+	"github.com/gopherd/landlord/poker"
+)
+
+// 牌桌: 一个玩家和两个 AI
+type table struct {
+	in       *bufio.Scanner
+	out      io.Writer
+	rand     *rand.Rand
+	opt      ai.Options
+	human    ai.Position
+	practice bool
+
+	game    *game.Game
+	bots    [ai.NumPlayer]ai.AI
+	tracker *ai.Tracker
+	total   [ai.NumPlayer]int
+
+	// 当前提示列表和下一个提示的下标
+	hints    []ai.Kind
+	hintNext int
+}
+
+// 进行一局游戏,返回 false 表示玩家退出
+func (t *table) run() (ok bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, isQuit := e.(quit); !isQuit {
+				panic(e)
+			}
+			ok = false
+		}
+	}()
+	for {
+		t.game = game.New(t.opt, t.rand)
+		for i := range t.bots {
+			if ai.Position(i) == t.human {
+				t.bots[i] = nil
+				continue
+			}
+			bot := ai.NewMCTSAIWithConfig(ai.MCTSConfig{Options: t.opt})
+			bot.SetSelf(ai.Position(i))
+			bot.(ai.HandSetter).SetHand(t.game.Hand(ai.Position(i)))
+			t.bots[i] = bot
+		}
+		t.bid()
+		if t.game.Landlord().Valid() {
+			break
+		}
+		fmt.Fprintln(t.out, "没有人叫地主,重新发牌")
+	}
+	t.double()
+	t.start()
+	t.play()
+	t.settle()
+	return true
+}
+
+// 玩家名称
+func (t *table) name(pos ai.Position) string {
+	var role string
+	if landlord := t.game.Landlord(); landlord.Valid() {
+		if pos == landlord {
+			role = "地主"
+		} else {
+			role = "农民"
+		}
+	}
+	if pos == t.human {
+		return "你" + role
+	}
+	return fmt.Sprintf("AI%d%s", pos, role)
+}
+
+func (t *table) bid() {
+	fmt.Fprintln(t.out, "你的手牌:")
+	t.showPokers(t.game.Hand(t.human))
+	for t.game.Phase() == game.PhaseBid {
+		pos := t.game.Turn()
+		var score int
+		if pos == t.human {
+			min := t.game.Bid() + 1
+			prompt := fmt.Sprintf("叫分 (0 不叫, %d-%d): ", min, game.MaxBid)
+			for {
+				score = t.readInt(prompt, 0, game.MaxBid)
+				if score == 0 || score >= min {
+					break
+				}
+			}
+		} else {
+			score = t.bots[pos].RecommendRob()
+			if score <= t.game.Bid() || score > game.MaxBid {
+				score = 0
+			}
+		}
+		if err := t.game.Rob(pos, score); err != nil {
+			panic(err)
+		}
+		for _, bot := range t.bots {
+			if bot != nil {
+				bot.Rob(pos, score)
+			}
+		}
+		if score == 0 {
+			fmt.Fprintf(t.out, "%s: 不叫\n", t.name(pos))
+		} else {
+			fmt.Fprintf(t.out, "%s: %d 分\n", t.name(pos), score)
+		}
+	}
+}
+
+func (t *table) double() {
+	landlord := t.game.Landlord()
+	fmt.Fprintf(t.out, "%s 是地主, 底牌:\n", t.name(landlord))
+	t.showPokers(t.game.LastPokers())
+	for _, bot := range t.bots {
+		if bot != nil {
+			bot.SetLandlord(landlord)
+			bot.SetLastPokers(t.game.LastPokers())
+		}
+	}
+	for t.game.Phase() == game.PhaseDouble {
+		pos := t.game.Turn()
+		var multi int
+		if pos == t.human {
+			if t.confirm("加倍?") {
+				multi = game.DoubleMultiple
+			}
+		} else if t.bots[pos].RecommendDouble() > 1 {
+			multi = game.DoubleMultiple
+		}
+		if err := t.game.Double(pos, multi); err != nil {
+			panic(err)
+		}
+		for _, bot := range t.bots {
+			if bot != nil {
+				bot.Double(pos, multi)
+			}
+		}
+		if multi > 0 {
+			fmt.Fprintf(t.out, "%s: 加倍\n", t.name(pos))
+		} else {
+			fmt.Fprintf(t.out, "%s: 不加倍\n", t.name(pos))
+		}
+	}
+}
+
+// 开始出牌: 初始化 AI 和记牌器,然后重放已有的出牌记录
+func (t *table) start() {
+	var hands [ai.NumPlayer]ai.PokerSet
+	for i := range hands {
+		hands[i] = t.game.StartHand(ai.Position(i))
+	}
+	landlord := t.game.Landlord()
+	for _, bot := range t.bots {
+		if bot != nil {
+			bot.Start(hands)
+		}
+	}
+	t.tracker = ai.NewTracker(t.human, landlord, hands[t.human], t.game.LastPokers())
+	t.tracker.SetOptions(t.opt)
+	for _, action := range t.game.History() {
+		t.notify(action.Player(), action.Kind())
+	}
+}
+
+// 通知 AI 和记牌器出牌结果
+func (t *table) notify(pos ai.Position, kind ai.Kind) {
+	tag := pos.Role(t.game.Landlord())
+	for _, bot := range t.bots {
+		if bot != nil {
+			bot.Play(tag, pos, kind)
+		}
+	}
+	t.tracker.Play(pos, kind)
+}
+
+func (t *table) play() {
+	for t.game.Phase() == game.PhasePlay {
+		pos := t.game.Turn()
+		var kind ai.Kind
+		if pos == t.human {
+			var undo bool
+			kind, undo = t.humanPlay()
+			if undo {
+				continue
+			}
+		} else {
+			kind = t.botPlay(pos)
+		}
+		if err := t.game.Play(pos, kind); err != nil {
+			panic(err)
+		}
+		t.notify(pos, kind)
+		if kind.Len() == 0 {
+			fmt.Fprintf(t.out, "%s: 不出\n", t.name(pos))
+		} else {
+			fmt.Fprintf(t.out, "%s: %s (剩 %d 张)\n", t.name(pos), kind.Pokers().Notation(), t.game.NumPokers(pos))
+		}
+	}
+}
+
+func (t *table) botPlay(pos ai.Position) ai.Kind {
+	kind := t.bots[pos].RecommendPlay(pos.Role(t.game.Landlord()))
+	if err := t.game.Check(pos, kind); err != nil {
+		// AI 给出了不合法的出牌时使用第一个提示
+		lead, leader := t.game.Lead()
+		kind = ai.FirstHint(t.game.Hand(pos), lead, leader, t.game.Landlord(), pos, t.opt)
+	}
+	return kind
+}
+
+// 读取玩家的出牌,返回 true 表示玩家悔牌
+func (t *table) humanPlay() (ai.Kind, bool) {
+	lead, leader := t.game.Lead()
+	t.hints = nil
+	t.hintNext = 0
+	t.showTable()
+	for {
+		line := t.readLine("出牌> ")
+		switch strings.ToLower(line) {
+		case "?", "help":
+			fmt.Fprintln(t.out, "输入要出的牌,如 33344 (X 表示 10, # 小王, $ 大王); p 不出, h 提示, t 记牌器, u 悔牌, q 退出")
+			continue
+		case "h":
+			t.hint(lead, leader)
+			continue
+		case "t":
+			t.showTracker()
+			continue
+		case "u":
+			if t.undo() {
+				return ai.Kind{}, true
+			}
+			continue
+		case "p", "pass":
+			line = ""
+		}
+		kind, err := t.game.Parse(t.human, line)
+		if err == nil {
+			err = t.game.Check(t.human, kind)
+		}
+		if err != nil {
+			fmt.Fprintln(t.out, err)
+			continue
+		}
+		return kind, false
+	}
+}
+
+func (t *table) hint(lead ai.Kind, leader ai.Position) {
+	if t.hints == nil {
+		fromTeammate := leader.Valid() && leader.IsFriend(t.game.Landlord(), t.human)
+		t.hints = ai.Hint(t.game.Hand(t.human), lead, fromTeammate, t.opt)
+	}
+	if len(t.hints) == 0 {
+		fmt.Fprintln(t.out, "没有可以出的牌")
+		return
+	}
+	kind := t.hints[t.hintNext%len(t.hints)]
+	t.hintNext++
+	if kind.Len() == 0 {
+		fmt.Fprintln(t.out, "提示: 不出")
+	} else {
+		fmt.Fprintf(t.out, "提示: %s\n", kind.Pokers().Notation())
+	}
+}
+
+// 悔牌: 撤销到玩家上一次出牌之前
+func (t *table) undo() bool {
+	if !t.practice {
+		fmt.Fprintln(t.out, "只有练习模式可以悔牌")
+		return false
+	}
+	history := t.game.History()
+	last := -1
+	for i, action := range history {
+		if action.Player() == t.human {
+			last = i
+		}
+	}
+	if last < 0 {
+		fmt.Fprintln(t.out, "没有可以悔的牌")
+		return false
+	}
+	for len(t.game.History()) > last {
+		if _, err := t.game.Undo(); err != nil && !errors.Is(err, game.ErrNothingUndo) {
+			panic(err)
+		}
+	}
+	for i := range t.bots {
+		if t.bots[i] != nil {
+			t.bots[i].Stop()
+		}
+	}
+	t.start()
+	fmt.Fprintln(t.out, "已悔牌")
+	return true
+}
+
+func (t *table) settle() {
+	result, err := t.game.Settle()
+	if err != nil {
+		panic(err)
+	}
+	for _, bot := range t.bots {
+		if bot != nil {
+			bot.Stop()
+		}
+	}
+	fmt.Fprintln(t.out, "\n---------- 结算 ----------")
+	for i := range result.Scores {
+		pos := ai.Position(i)
+		if rest := t.game.Hand(pos); !rest.Empty() {
+			fmt.Fprintf(t.out, "%s 剩余: %s\n", t.name(pos), rest.Notation())
+		}
+	}
+	bombs, rockets := t.game.Bombs()
+	fmt.Fprintf(t.out, "%s 获胜, 叫分 %d, 炸弹 %d, 火箭 %d", t.name(result.Winner), t.game.Bid(), bombs, rockets)
+	if result.Spring {
+		fmt.Fprint(t.out, ", 春天")
+	}
+	fmt.Fprintf(t.out, ", 倍数 %d\n", result.Multiple)
+	for i, score := range result.Scores {
+		fmt.Fprintf(t.out, "%s: %+d\n", t.name(ai.Position(i)), score)
+		t.total[i] += score
+	}
+}
+
+func (t *table) showPokers(pset ai.PokerSet) {
+	fmt.Fprintln(t.out, poker.FormatValues(pset.GetValues()))
+}
+
+func (t *table) showTable() {
+	fmt.Fprintln(t.out)
+	for i := ai.Position(1); i < ai.NumPlayer; i++ {
+		pos := (t.human + i) % ai.NumPlayer
+		fmt.Fprintf(t.out, "%s: %d 张", t.name(pos), t.game.NumPokers(pos))
+		if t.practice {
+			fmt.Fprintf(t.out, " %s", t.game.Hand(pos).Notation())
+		}
+		fmt.Fprintln(t.out)
+	}
+	if lead, leader := t.game.Lead(); lead.Len() > 0 {
+		fmt.Fprintf(t.out, "需要管上 %s 的 %s\n", t.name(leader), lead.Pokers().Notation())
+	} else {
+		fmt.Fprintln(t.out, "轮到你出牌")
+	}
+	fmt.Fprintln(t.out, "你的手牌:")
+	t.showPokers(t.game.Hand(t.human))
+}
+
+// 显示记牌器: 各牌值还没出现的张数
+func (t *table) showTracker() {
+	var (
+		unseen = t.tracker.Unseen()
+		head   strings.Builder
+		body   strings.Builder
+	)
+	for value := poker.P3; value <= poker.PJoker2; value++ {
+		fmt.Fprintf(&head, "%2s", value.String())
+		fmt.Fprintf(&body, "%2d", unseen.Count(value))
+	}
+	fmt.Fprintf(t.out, "记牌器(对手还有的牌):\n%s\n%s\n", head.String(), body.String())
+}
@@ -0,0 +1,386 @@
+// 斗地主牌局: 发牌,叫地主,加倍,出牌和结算
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/poker"
+)
+
+const (
+	// 每个玩家发牌张数
+	NumHandPokers = 17
+	// 底牌张数
+	NumLastPokers = 3
+	// 最高叫分
+	MaxBid = 3
+	// 加倍倍数
+	DoubleMultiple = 2
+)
+
+var (
+	ErrWrongPhase  = errors.New("game: wrong phase")
+	ErrNotYourTurn = errors.New("game: not your turn")
+	ErrInvalidBid  = errors.New("game: invalid bid")
+	ErrInvalidPlay = errors.New("game: invalid play")
+	ErrNothingUndo = errors.New("game: nothing to undo")
+)
+
+// 牌局阶段
+type Phase int
+
+const (
+	PhaseBid    Phase = iota // 叫地主
+	PhaseDouble              // 加倍
+	PhasePlay                // 出牌
+	PhaseOver                // 结束
+)
+
+func (phase Phase) String() string {
+	switch phase {
+	case PhaseBid:
+		return "bid"
+	case PhaseDouble:
+		return "double"
+	case PhasePlay:
+		return "play"
+	case PhaseOver:
+		return "over"
+	}
+	return fmt.Sprintf("Phase(%d)", int(phase))
+}
+
+// 一局斗地主
+type Game struct {
+	opt   ai.Options
+	phase Phase
+	turn  ai.Position
+	// 第一个叫地主的玩家
+	first ai.Position
+
+	// 发牌结果
+	deal [ai.NumPlayer]ai.PokerSet
+	// 底牌
+	lastPokers ai.PokerSet
+	// 当前手牌
+	hands [ai.NumPlayer]ai.PokerSet
+
+	// 叫分, -1 表示还没叫
+	bids     [ai.NumPlayer]int
+	bid      int
+	landlord ai.Position
+	// 加倍, -1 表示还没选择, 0 表示不加倍
+	doubles [ai.NumPlayer]int
+
+	// 出牌记录
+	history []ai.Action
+	lead    ai.Kind
+	leader  ai.Position
+	plays   [ai.NumPlayer]int
+	bombs   int
+	rockets int
+}
+
+// 随机发牌创建牌局, r 为空时使用全局随机数
+func New(opt ai.Options, r *rand.Rand) *Game {
+	deck := make([]poker.Poker, 0, ai.FullDeck.Len())
+	ai.FullDeck.Walk(func(p poker.Poker) bool {
+		deck = append(deck, p)
+		return false
+	})
+	shuffle := rand.Shuffle
+	intn := rand.Intn
+	if r != nil {
+		shuffle = r.Shuffle
+		intn = r.Intn
+	}
+	shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+	var (
+		hands [ai.NumPlayer]ai.PokerSet
+		last  ai.PokerSet
+	)
+	for i, p := range deck {
+		if i < len(hands)*NumHandPokers {
+			hands[i%len(hands)].Add(ai.NewPokerSetWithPoker(p))
+		} else {
+			last.Add(ai.NewPokerSetWithPoker(p))
+		}
+	}
+	return NewWithHands(opt, hands, last, ai.Position(intn(ai.NumPlayer)))
+}
+
+// 使用指定的手牌和底牌创建牌局, first 为第一个叫地主的玩家
+func NewWithHands(opt ai.Options, hands [ai.NumPlayer]ai.PokerSet, lastPokers ai.PokerSet, first ai.Position) *Game {
+	g := &Game{
+		opt:        opt,
+		phase:      PhaseBid,
+		turn:       first,
+		first:      first,
+		deal:       hands,
+		lastPokers: lastPokers,
+		hands:      hands,
+		landlord:   ai.BadPosition,
+		leader:     ai.BadPosition,
+	}
+	for i := range g.bids {
+		g.bids[i] = -1
+		g.doubles[i] = -1
+	}
+	return g
+}
+
+func (g *Game) Options() ai.Options              { return g.opt }
+func (g *Game) Phase() Phase                     { return g.phase }
+func (g *Game) Turn() ai.Position                { return g.turn }
+func (g *Game) First() ai.Position               { return g.first }
+func (g *Game) Landlord() ai.Position            { return g.landlord }
+func (g *Game) Bid() int                         { return g.bid }
+func (g *Game) LastPokers() ai.PokerSet          { return g.lastPokers }
+func (g *Game) Hand(pos ai.Position) ai.PokerSet { return g.hands[pos] }
+func (g *Game) Hands() [ai.NumPlayer]ai.PokerSet { return g.hands }
+func (g *Game) Deal() [ai.NumPlayer]ai.PokerSet  { return g.deal }
+func (g *Game) Doubles() [ai.NumPlayer]int       { return g.doubles }
+func (g *Game) History() []ai.Action             { return g.history }
+func (g *Game) Lead() (ai.Kind, ai.Position)     { return g.lead, g.leader }
+func (g *Game) NumPlays(pos ai.Position) int     { return g.plays[pos] }
+func (g *Game) Bombs() (bombs, rockets int)      { return g.bombs, g.rockets }
+func (g *Game) NumPokers(pos ai.Position) int    { return g.hands[pos].Len() }
+
+// 出牌开始时的手牌,地主包含底牌
+func (g *Game) StartHand(pos ai.Position) ai.PokerSet {
+	if pos == g.landlord {
+		return g.deal[pos] | g.lastPokers
+	}
+	return g.deal[pos]
+}
+
+// 叫地主: score 为 0 表示不叫,否则必须大于之前的叫分且不超过 MaxBid.
+// 叫到 MaxBid 或所有玩家都叫过后确定地主,都不叫时牌局直接结束(没有地主)
+func (g *Game) Rob(pos ai.Position, score int) error {
+	if g.phase != PhaseBid {
+		return ErrWrongPhase
+	}
+	if pos != g.turn {
+		return ErrNotYourTurn
+	}
+	if score < 0 || score > MaxBid || (score > 0 && score <= g.bid) {
+		return fmt.Errorf("%w: %d", ErrInvalidBid, score)
+	}
+	g.bids[pos] = score
+	if score > 0 {
+		g.bid = score
+		g.landlord = pos
+	}
+	g.turn = pos.Next()
+	if score == MaxBid || g.turn == g.first {
+		if g.landlord.Valid() {
+			g.hands[g.landlord].Add(g.lastPokers)
+			g.phase = PhaseDouble
+			g.turn = g.landlord
+		} else {
+			g.phase = PhaseOver
+			g.turn = ai.BadPosition
+		}
+	}
+	return nil
+}
+
+// 叫分, -1 表示还没叫
+func (g *Game) Bids() [ai.NumPlayer]int { return g.bids }
+
+// 加倍: multi 为 0 表示不加倍, DoubleMultiple 表示加倍. 从地主开始依次选择,之后地主开始出牌
+func (g *Game) Double(pos ai.Position, multi int) error {
+	if g.phase != PhaseDouble {
+		return ErrWrongPhase
+	}
+	if pos != g.turn {
+		return ErrNotYourTurn
+	}
+	if multi != 0 && multi != DoubleMultiple {
+		return fmt.Errorf("%w: double %d", ErrInvalidBid, multi)
+	}
+	g.doubles[pos] = multi
+	g.turn = pos.Next()
+	if g.turn == g.landlord {
+		g.phase = PhasePlay
+	}
+	return nil
+}
+
+// 检查 pos 出牌 kind 是否合法
+func (g *Game) Check(pos ai.Position, kind ai.Kind) error {
+	if g.phase != PhasePlay {
+		return ErrWrongPhase
+	}
+	if pos != g.turn {
+		return ErrNotYourTurn
+	}
+	if kind.Len() == 0 {
+		if g.lead.Len() == 0 {
+			return fmt.Errorf("%w: must play when leading", ErrInvalidPlay)
+		}
+		return nil
+	}
+	if !g.hands[pos].Contains(kind.Pokers()) {
+		return fmt.Errorf("%w: %s not in hand", ErrInvalidPlay, kind.Pokers().Notation())
+	}
+	if !kind.Valid(g.opt) {
+		return fmt.Errorf("%w: %s is not a valid play", ErrInvalidPlay, kind.Pokers().Notation())
+	}
+	if !kind.Beats(g.lead, g.opt) {
+		return fmt.Errorf("%w: %s can't beat %s", ErrInvalidPlay, kind.Pokers().Notation(), g.lead.Pokers().Notation())
+	}
+	return nil
+}
+
+// 出牌, kind 为空表示不出
+func (g *Game) Play(pos ai.Position, kind ai.Kind) error {
+	if err := g.Check(pos, kind); err != nil {
+		return err
+	}
+	g.play(pos, kind)
+	return nil
+}
+
+func (g *Game) play(pos ai.Position, kind ai.Kind) {
+	g.history = append(g.history, ai.NewAction(pos, kind))
+	g.turn = pos.Next()
+	if kind.Len() > 0 {
+		g.hands[pos].Remove(kind.Pokers())
+		g.plays[pos]++
+		g.lead, g.leader = kind, pos
+		if kind.IsRocket() {
+			g.rockets++
+		} else if kind.IsBomb() {
+			g.bombs++
+		}
+		if g.hands[pos].Empty() {
+			g.phase = PhaseOver
+			g.turn = ai.BadPosition
+			return
+		}
+	}
+	if g.turn == g.leader {
+		g.lead = ai.Kind{}
+	}
+}
+
+// 将牌面文本解析为 pos 要出的牌: 文本为空表示不出,
+// 牌可以组成多种牌型时优先选择炸弹,其次是第一个能管上当前出牌的牌型
+func (g *Game) Parse(pos ai.Position, s string) (ai.Kind, error) {
+	pokers, err := g.hands[pos].Select(s)
+	if err != nil {
+		return ai.Kind{}, err
+	}
+	return g.ParsePokers(pos, pokers)
+}
+
+// 将 pos 手中确定的牌解析为要出的牌型,规则同 Parse. 牌为空表示不出,牌不在手中时返回 ErrInvalidPlay
+func (g *Game) ParsePokers(pos ai.Position, pokers ai.PokerSet) (ai.Kind, error) {
+	if !g.hands[pos].Contains(pokers) {
+		return ai.Kind{}, fmt.Errorf("%w: %s not in hand", ErrInvalidPlay, pokers)
+	}
+	if pokers.Empty() {
+		return ai.Kind{}, nil
+	}
+	kinds := ai.Classify(pokers, g.opt)
+	if len(kinds) == 0 {
+		return ai.Kind{}, fmt.Errorf("%w: %s is not a valid play", ErrInvalidPlay, pokers.Notation())
+	}
+	for _, kind := range kinds {
+		if (kind.IsBomb() || kind.IsRocket()) && kind.Beats(g.lead, g.opt) {
+			return kind, nil
+		}
+	}
+	for _, kind := range kinds {
+		if kind.Beats(g.lead, g.opt) {
+			return kind, nil
+		}
+	}
+	return kinds[0], nil
+}
+
+// 撤销最后一次出牌,返回被撤销的出牌
+func (g *Game) Undo() (ai.Action, error) {
+	if len(g.history) == 0 {
+		return ai.Action{}, ErrNothingUndo
+	}
+	history := g.history[:len(g.history)-1]
+	undone := g.history[len(g.history)-1]
+	g.phase = PhasePlay
+	g.turn = g.landlord
+	g.history = nil
+	g.lead, g.leader = ai.Kind{}, ai.BadPosition
+	g.plays = [ai.NumPlayer]int{}
+	g.bombs, g.rockets = 0, 0
+	for i := range g.hands {
+		g.hands[i] = g.StartHand(ai.Position(i))
+	}
+	for _, action := range history {
+		g.play(action.Player(), action.Kind())
+	}
+	return undone, nil
+}
+
+// 结算结果
+type Result struct {
+	// 赢家, 没有地主时为 BadPosition
+	Winner ai.Position `json:"winner"`
+	// 是否春天(含反春)
+	Spring bool `json:"spring"`
+	// 出牌过程倍数(炸弹,火箭和春天)
+	Multiple int `json:"multiple"`
+	// 各玩家得分
+	Scores [ai.NumPlayer]int `json:"scores"`
+}
+
+// 结算: 每个农民输赢的分数为 叫分×出牌过程倍数×地主加倍×农民自己的加倍,超过封顶倍数时按封顶倍数计算,
+// 地主输赢的分数为两个农民之和. 牌局没有结束时返回 ErrWrongPhase
+func (g *Game) Settle() (Result, error) {
+	result := Result{Winner: ai.BadPosition}
+	if g.phase != PhaseOver {
+		return result, ErrWrongPhase
+	}
+	if !g.landlord.Valid() {
+		return result, nil
+	}
+	for i, hand := range g.hands {
+		if hand.Empty() {
+			result.Winner = ai.Position(i)
+		}
+	}
+	if result.Winner == g.landlord {
+		result.Spring = g.plays[g.landlord.Next()]+g.plays[g.landlord.Prev()] == 0
+	} else {
+		result.Spring = g.plays[g.landlord] <= 1
+	}
+	result.Multiple = g.opt.Multiple(g.bombs, g.rockets, result.Spring)
+	sign := 1
+	if result.Winner != g.landlord {
+		sign = -1
+	}
+	for i := range result.Scores {
+		pos := ai.Position(i)
+		if pos == g.landlord {
+			continue
+		}
+		multi := result.Multiple * doubled(g.doubles[g.landlord]) * doubled(g.doubles[pos])
+		if g.opt.MaxMultiple > 0 && multi > g.opt.MaxMultiple {
+			multi = g.opt.MaxMultiple
+		}
+		score := g.bid * multi
+		result.Scores[pos] -= sign * score
+		result.Scores[g.landlord] += sign * score
+	}
+	return result, nil
+}
+
+func doubled(multi int) int {
+	if multi > 1 {
+		return multi
+	}
+	return 1
+}
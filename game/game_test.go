@@ -0,0 +1,145 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+func mustParsePokerSet(t *testing.T, s string) ai.PokerSet {
+	t.Helper()
+	pset, err := ai.ParsePokerSet(s)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return pset
+}
+
+func TestDeal(t *testing.T) {
+	g := New(ai.DefaultOptions, rand.New(rand.NewSource(1)))
+	var all ai.PokerSet
+	for pos := ai.Position(0); pos < ai.NumPlayer; pos++ {
+		if n := g.NumPokers(pos); n != NumHandPokers {
+			t.Fatalf("player %d has %d pokers", pos, n)
+		}
+		all |= g.Hand(pos)
+	}
+	if g.LastPokers().Len() != NumLastPokers || all|g.LastPokers() != ai.FullDeck {
+		t.Fatalf("bad deal")
+	}
+	if g.Phase() != PhaseBid || !g.Turn().Valid() {
+		t.Fatalf("bad initial phase %v, turn %v", g.Phase(), g.Turn())
+	}
+}
+
+func TestBid(t *testing.T) {
+	g := New(ai.DefaultOptions, rand.New(rand.NewSource(2)))
+	first := g.Turn()
+	for i := 0; i < ai.NumPlayer; i++ {
+		if err := g.Rob(g.Turn(), 0); err != nil {
+			t.Fatalf("rob: %v", err)
+		}
+	}
+	if g.Phase() != PhaseOver || g.Landlord().Valid() {
+		t.Fatalf("nobody bids, game should be over without landlord")
+	}
+
+	g = NewWithHands(ai.DefaultOptions, g.Deal(), g.LastPokers(), first)
+	if err := g.Rob(first.Next(), 1); !errors.Is(err, ErrNotYourTurn) {
+		t.Fatalf("expected not your turn, got %v", err)
+	}
+	if err := g.Rob(first, 2); err != nil {
+		t.Fatalf("rob: %v", err)
+	}
+	if err := g.Rob(first.Next(), 1); !errors.Is(err, ErrInvalidBid) {
+		t.Fatalf("expected invalid bid, got %v", err)
+	}
+	if err := g.Rob(first.Next(), 3); err != nil {
+		t.Fatalf("rob: %v", err)
+	}
+	if g.Phase() != PhaseDouble || g.Landlord() != first.Next() || g.Bid() != 3 {
+		t.Fatalf("landlord should be %v with bid 3", first.Next())
+	}
+	if g.NumPokers(g.Landlord()) != NumHandPokers+NumLastPokers {
+		t.Fatalf("landlord should take last pokers")
+	}
+}
+
+func TestPlayAndSettle(t *testing.T) {
+	hands := [ai.NumPlayer]ai.PokerSet{
+		mustParsePokerSet(t, "3333 4"),
+		mustParsePokerSet(t, "♥5 ♥6"),
+		mustParsePokerSet(t, "♣7 ♣8"),
+	}
+	g := NewWithHands(ai.DefaultOptions, hands, mustParsePokerSet(t, "♦K"), 0)
+	for _, step := range []struct {
+		pos   ai.Position
+		score int
+	}{{0, 1}, {1, 0}, {2, 0}} {
+		if err := g.Rob(step.pos, step.score); err != nil {
+			t.Fatalf("rob: %v", err)
+		}
+	}
+	for pos, multi := range []int{DoubleMultiple, 0, DoubleMultiple} {
+		if err := g.Double(ai.Position(pos), multi); err != nil {
+			t.Fatalf("double: %v", err)
+		}
+	}
+	if g.Phase() != PhasePlay || g.Turn() != 0 {
+		t.Fatalf("landlord should lead")
+	}
+	if _, err := g.Parse(0, ""); err != nil {
+		t.Fatalf("parse pass: %v", err)
+	}
+	if err := g.Play(0, ai.Kind{}); !errors.Is(err, ErrInvalidPlay) {
+		t.Fatalf("leader can't pass, got %v", err)
+	}
+	play := func(pos ai.Position, s string) {
+		t.Helper()
+		kind, err := g.Parse(pos, s)
+		if err == nil {
+			err = g.Play(pos, kind)
+		}
+		if err != nil {
+			t.Fatalf("player %d play %q: %v", pos, s, err)
+		}
+	}
+	play(0, "3333")
+	if lead, leader := g.Lead(); leader != 0 || !lead.IsBomb() {
+		t.Fatalf("bad lead %v by %v", lead, leader)
+	}
+	play(1, "")
+	play(2, "")
+	play(0, "K")
+	if kind, _ := g.Parse(1, "5"); g.Play(1, kind) == nil {
+		t.Fatalf("5 can't beat K")
+	}
+	play(1, "")
+	play(2, "")
+
+	if _, err := g.Undo(); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if g.Turn() != 2 || len(g.History()) != 5 {
+		t.Fatalf("bad state after undo: turn %v, history %v", g.Turn(), g.History())
+	}
+	play(2, "")
+	play(0, "4")
+
+	if g.Phase() != PhaseOver {
+		t.Fatalf("game should be over")
+	}
+	result, err := g.Settle()
+	if err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	// 春天和一个炸弹: 1 * 2 * 2 = 4, 地主加倍, 农民 2 加倍
+	if result.Winner != 0 || !result.Spring || result.Multiple != 4 {
+		t.Fatalf("bad result %+v", result)
+	}
+	if result.Scores != [ai.NumPlayer]int{8 + 16, -8, -16} {
+		t.Fatalf("bad scores %v", result.Scores)
+	}
+}
@@ -2,10 +2,12 @@ module github.com/gopherd/landlord
 
 go 1.17
 
-require github.com/golang/protobuf v1.5.2
-
 require (
-	github.com/gopherd/doge v0.0.20 // indirect
-	github.com/gopherd/log v0.1.8 // indirect
-	google.golang.org/protobuf v1.26.0 // indirect
+	github.com/golang/protobuf v1.5.2
+	github.com/gopherd/doge v0.0.20
+	github.com/gopherd/log v0.1.8
+	github.com/gorilla/websocket v1.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require google.golang.org/protobuf v1.26.0 // indirect
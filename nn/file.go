@@ -0,0 +1,100 @@
+package nn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+const (
+	magic   = "LLNN"
+	Version = 1
+	// 各维度的上限,防止错误的文件导致分配过多内存
+	maxDim    = 1 << 14
+	maxBlocks = 256
+)
+
+var ErrBadModel = errors.New("nn: bad model")
+
+// 文件头,所有整数和参数都使用小端序
+type header struct {
+	Magic    [4]byte
+	Version  uint32
+	Features uint32
+	Actions  uint32
+	Hidden   uint32
+	Blocks   uint32
+	Embed    uint32
+}
+
+// 写入模型. 文件格式: 文件头(见 header)之后依次是 Input, 各残差块的 A 和 B, Value, Policy, Action
+// 每一层的 Weights 和 Bias, 参数都是 float32
+func (m *Model) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	h := header{
+		Version:  Version,
+		Features: uint32(ai.FeatureSize),
+		Actions:  uint32(ai.ActionSize),
+		Hidden:   uint32(m.Hidden()),
+		Blocks:   uint32(len(m.Blocks)),
+		Embed:    uint32(m.Embed()),
+	}
+	copy(h.Magic[:], magic)
+	if err := binary.Write(bw, binary.LittleEndian, &h); err != nil {
+		return 0, err
+	}
+	n := int64(binary.Size(&h))
+	for _, d := range m.layers() {
+		for _, params := range [][]float32{d.Weights, d.Bias} {
+			if err := binary.Write(bw, binary.LittleEndian, params); err != nil {
+				return n, err
+			}
+			n += int64(len(params) * 4)
+		}
+	}
+	return n, bw.Flush()
+}
+
+// 读取模型
+func Read(r io.Reader) (*Model, error) {
+	br := bufio.NewReader(r)
+	var h header
+	if err := binary.Read(br, binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadModel, err)
+	}
+	switch {
+	case string(h.Magic[:]) != magic:
+		return nil, fmt.Errorf("%w: bad magic %q", ErrBadModel, h.Magic[:])
+	case h.Version != Version:
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadModel, h.Version)
+	case h.Features != uint32(ai.FeatureSize) || h.Actions != uint32(ai.ActionSize):
+		return nil, fmt.Errorf("%w: model expects %d features and %d action slots, but encoding has %d and %d",
+			ErrBadModel, h.Features, h.Actions, ai.FeatureSize, ai.ActionSize)
+	case h.Hidden == 0 || h.Hidden > maxDim || h.Embed == 0 || h.Embed > maxDim || h.Blocks > maxBlocks:
+		return nil, fmt.Errorf("%w: bad shape (hidden %d, blocks %d, embed %d)", ErrBadModel, h.Hidden, h.Blocks, h.Embed)
+	}
+	m := New(int(h.Hidden), int(h.Blocks), int(h.Embed))
+	for _, d := range m.layers() {
+		for _, params := range [][]float32{d.Weights, d.Bias} {
+			if err := binary.Read(br, binary.LittleEndian, params); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrBadModel, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// 从文件加载模型
+func Load(filename string) (*Model, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
@@ -0,0 +1,176 @@
+// 纯 Go 实现的神经网络推理,用于 MCTS 的先验概率和局面估值,不依赖 CGO 和 GPU.
+//
+// 网络结构:
+//
+//	h = ReLU(Input·x)                          x 为 ai.InfoSet.Features()
+//	h = ReLU(h + B·ReLU(A·h))                  每个残差块
+//	value = Value·h                            局面估值
+//	logit_i = (Policy·h)·ReLU(Action·a_i)      a_i 为 ai.EncodeKind(kinds[i])
+//	priors = softmax(logits)
+//
+// 其中每一层都是带偏置的全连接层. 模型文件格式见 Model.WriteTo
+package nn
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+// 全连接层: y = Weights·x + Bias
+type Dense struct {
+	In, Out int
+	// Out 行 In 列,行优先
+	Weights []float32
+	Bias    []float32
+}
+
+func NewDense(in, out int) *Dense {
+	return &Dense{
+		In:      in,
+		Out:     out,
+		Weights: make([]float32, in*out),
+		Bias:    make([]float32, out),
+	}
+}
+
+// 计算 Weights·x + Bias 并写入 dst, dst 长度不足时重新分配
+func (d *Dense) Forward(dst, x []float32) []float32 {
+	if cap(dst) < d.Out {
+		dst = make([]float32, d.Out)
+	}
+	dst = dst[:d.Out]
+	for i := range dst {
+		var (
+			sum = d.Bias[i]
+			row = d.Weights[i*d.In : (i+1)*d.In]
+		)
+		for j, w := range row {
+			sum += w * x[j]
+		}
+		dst[i] = sum
+	}
+	return dst
+}
+
+// 使用均匀分布随机初始化权重(He 初始化),偏置置 0
+func (d *Dense) init(r *rand.Rand) {
+	limit := math.Sqrt(6 / float64(d.In))
+	for i := range d.Weights {
+		d.Weights[i] = float32((r.Float64()*2 - 1) * limit)
+	}
+	for i := range d.Bias {
+		d.Bias[i] = 0
+	}
+}
+
+// 残差块
+type Residual struct {
+	A, B *Dense
+}
+
+// 模型
+type Model struct {
+	Input  *Dense
+	Blocks []Residual
+	Value  *Dense
+	Policy *Dense
+	Action *Dense
+}
+
+// 创建隐藏层宽度为 hidden, 包含 blocks 个残差块, 动作嵌入维度为 embed 的模型,所有参数为 0
+func New(hidden, blocks, embed int) *Model {
+	m := &Model{
+		Input:  NewDense(ai.FeatureSize, hidden),
+		Blocks: make([]Residual, blocks),
+		Value:  NewDense(hidden, 1),
+		Policy: NewDense(hidden, embed),
+		Action: NewDense(ai.ActionSize, embed),
+	}
+	for i := range m.Blocks {
+		m.Blocks[i] = Residual{A: NewDense(hidden, hidden), B: NewDense(hidden, hidden)}
+	}
+	return m
+}
+
+// 隐藏层宽度
+func (m *Model) Hidden() int { return m.Input.Out }
+
+// 动作嵌入维度
+func (m *Model) Embed() int { return m.Action.Out }
+
+// 使用随机数 r 随机初始化所有参数
+func (m *Model) Init(r *rand.Rand) {
+	for _, d := range m.layers() {
+		d.init(r)
+	}
+}
+
+// 按文件中的顺序返回所有层
+func (m *Model) layers() []*Dense {
+	layers := []*Dense{m.Input}
+	for _, block := range m.Blocks {
+		layers = append(layers, block.A, block.B)
+	}
+	return append(layers, m.Value, m.Policy, m.Action)
+}
+
+// 计算特征 x 的隐藏层输出
+func (m *Model) hidden(x []float32) []float32 {
+	h := relu(m.Input.Forward(nil, x))
+	var a, b []float32
+	for _, block := range m.Blocks {
+		a = relu(block.A.Forward(a, h))
+		b = block.B.Forward(b, a)
+		for i := range h {
+			h[i] += b[i]
+		}
+		relu(h)
+	}
+	return h
+}
+
+// 实现 ai.Evaluator: 先验概率为所有合法出牌得分的 softmax
+func (m *Model) Evaluate(info ai.InfoSet, kinds []ai.Kind) ([]float64, float64) {
+	h := m.hidden(info.Features())
+	value := float64(m.Value.Forward(nil, h)[0])
+	if len(kinds) == 0 {
+		return nil, value
+	}
+	var (
+		query  = m.Policy.Forward(nil, h)
+		embed  []float32
+		priors = make([]float64, len(kinds))
+		max    = math.Inf(-1)
+	)
+	for i, kind := range kinds {
+		embed = relu(m.Action.Forward(embed, ai.EncodeKind(kind)))
+		var logit float32
+		for j, q := range query {
+			logit += q * embed[j]
+		}
+		priors[i] = float64(logit)
+		if priors[i] > max {
+			max = priors[i]
+		}
+	}
+	var total float64
+	for i := range priors {
+		priors[i] = math.Exp(priors[i] - max)
+		total += priors[i]
+	}
+	for i := range priors {
+		priors[i] /= total
+	}
+	return priors, value
+}
+
+func relu(x []float32) []float32 {
+	for i, v := range x {
+		if v < 0 {
+			x[i] = 0
+		}
+	}
+	return x
+}
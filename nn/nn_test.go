@@ -0,0 +1,120 @@
+package nn
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+func TestDense(t *testing.T) {
+	d := NewDense(2, 2)
+	copy(d.Weights, []float32{1, 2, 3, 4})
+	copy(d.Bias, []float32{1, -1})
+	if y := d.Forward(nil, []float32{1, 1}); !reflect.DeepEqual(y, []float32{4, 6}) {
+		t.Fatalf("bad output %v", y)
+	}
+}
+
+func testInfoSet(t *testing.T) (ai.InfoSet, []ai.Kind) {
+	t.Helper()
+	hand, err := ai.ParsePokerSet("33 456789 KK 2 $")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := ai.InfoSet{
+		Self:      0,
+		Landlord:  0,
+		Hand:      hand,
+		NumPokers: [ai.NumPlayer]int{hand.Len(), 17, 17},
+		Leader:    ai.BadPosition,
+	}
+	return info, hand.MatchAll(ai.Kind{}, ai.Kind{}, ai.DefaultOptions)
+}
+
+func TestEvaluate(t *testing.T) {
+	info, kinds := testInfoSet(t)
+	priors, value := New(8, 1, 4).Evaluate(info, kinds)
+	if value != 0 || len(priors) != len(kinds) {
+		t.Fatalf("bad evaluation: %v, %v", priors, value)
+	}
+	for _, p := range priors {
+		if math.Abs(p-1/float64(len(kinds))) > 1e-9 {
+			t.Fatalf("model with zero parameters should give uniform priors: %v", priors)
+		}
+	}
+
+	m := New(16, 2, 8)
+	m.Init(rand.New(rand.NewSource(1)))
+	priors, _ = m.Evaluate(info, kinds)
+	var total float64
+	for _, p := range priors {
+		total += p
+	}
+	if math.Abs(total-1) > 1e-9 || priors[0] == priors[len(priors)-1] {
+		t.Fatalf("bad priors %v", priors)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	m := New(16, 2, 8)
+	m.Init(rand.New(rand.NewSource(1)))
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("write: %d, %v", n, err)
+	}
+	m2, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !reflect.DeepEqual(m, m2) {
+		t.Fatalf("model changed after reading")
+	}
+
+	data := buf.Bytes()
+	if _, err := Read(bytes.NewReader(data[:len(data)-1])); !errors.Is(err, ErrBadModel) {
+		t.Fatalf("truncated model should fail: %v", err)
+	}
+	bad := append([]byte("XXXX"), data[4:]...)
+	if _, err := Read(bytes.NewReader(bad)); !errors.Is(err, ErrBadModel) {
+		t.Fatalf("bad magic should fail: %v", err)
+	}
+}
+
+// 使用神经网络估值而不推演的 MCTS AI
+func TestSearch(t *testing.T) {
+	m := New(16, 1, 8)
+	m.Init(rand.New(rand.NewSource(1)))
+	var hands [ai.NumPlayer]ai.PokerSet
+	for i, s := range []string{"33 456789 KK 2 $", "55 66 QQ A", "T J 22 #"} {
+		pset, err := ai.ParsePokerSet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hands[i] = pset
+	}
+	bot := ai.NewMCTSAIWithConfig(ai.MCTSConfig{
+		Rand:       rand.New(rand.NewSource(1)),
+		Iterations: 200,
+		Evaluator:  m,
+	})
+	bot.SetSelf(0)
+	bot.SetLandlord(0)
+	bot.Start(hands)
+	kind, result := bot.Analyze("L")
+	if kind.Len() == 0 || !hands[0].Contains(kind.Pokers()) || len(result.Candidates) == 0 {
+		t.Fatalf("bad play %v", kind)
+	}
+	var total float64
+	for _, c := range result.Candidates {
+		total += c.Prior
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Fatalf("priors should come from the model, total %v", total)
+	}
+}
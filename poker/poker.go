@@ -231,6 +231,8 @@ const (
 
 	// 单牌和顺子
 	Single1  Type = 101
+	Single3  Type = 103
+	Single4  Type = 104
 	Single5  Type = 105
 	Single6  Type = 106
 	Single7  Type = 107
@@ -242,6 +244,7 @@ const (
 
 	// 对子和连对
 	Double1  Type = 201
+	Double2  Type = 202
 	Double3  Type = 203
 	Double4  Type = 204
 	Double5  Type = 205
@@ -272,11 +275,20 @@ const (
 	Three5 Type = 505
 	Three6 Type = 506
 
-	// 4带2单
+	// 4带2单(航天飞机带单: 每4张带2张单牌,如 33334444+5678)
 	FourSingle1 Type = 601
+	FourSingle2 Type = 602
+	FourSingle3 Type = 603
 
-	// 4带2对
+	// 4带2对(航天飞机带对: 每4张带2对,如 33334444+55667788)
 	FourDouble1 Type = 701
+	FourDouble2 Type = 702
+
+	// 航天飞机不带(4张的连续,如 33334444)
+	Four2 Type = 802
+	Four3 Type = 803
+	Four4 Type = 804
+	Four5 Type = 805
 
 	// 炸弹和火箭
 	Bomb   Type = 1801
@@ -0,0 +1,373 @@
+package selfplay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+// 文件格式: 文件头为 4 字节魔数和 1 字节版本号,之后是若干条记录,
+// 每条记录由 uvarint 编码的长度和记录内容组成,记录内容的编码见 Record.MarshalBinary
+const (
+	magic   = "LLSP"
+	Version = 2
+)
+
+var ErrBadFormat = errors.New("selfplay: bad format")
+
+// 一次出牌决策的记录,特征只包含决策玩家可以看到的信息(信息集)
+type Record struct {
+	// 对局编号
+	Game int
+	// 决策序号,从 0 开始
+	Step int
+	// 决策玩家
+	Self ai.Position
+	// 地主位置
+	Landlord ai.Position
+	// 叫地主分数
+	Bid int
+	// 各玩家加倍倍数, 0 表示不加倍
+	Doubles [ai.NumPlayer]int
+	// 底牌
+	LastPokers ai.PokerSet
+	// 自己的手牌
+	Hand ai.PokerSet
+	// 各玩家已经出过的牌
+	Played [ai.NumPlayer]ai.PokerSet
+	// 各玩家剩余牌数
+	NumPokers [ai.NumPlayer]int
+	// 需要管的牌及出牌者,主动出牌时为空牌型和 BadPosition
+	Lead   ai.Kind
+	Leader ai.Position
+	// 之前的出牌记录(含不出),从地主的第一手开始依次轮流
+	History []ai.Kind
+	// 所有合法的出牌,顺序同 PokerSet.MatchAll
+	Legal []ai.Kind
+	// 各合法出牌在搜索中的访问次数,与 Legal 一一对应
+	Visits []uint32
+	// 实际选择的出牌在 Legal 中的下标
+	Choice int
+
+	// 以下为对局结果
+
+	// 胜者
+	Winner ai.Position
+	// 决策玩家的得分
+	Score int
+	// 倍数
+	Multiple int
+	// 是否春天(或反春)
+	Spring bool
+}
+
+// 决策玩家的信息集
+func (r *Record) InfoSet() ai.InfoSet {
+	info := ai.InfoSet{
+		Self:       r.Self,
+		Landlord:   r.Landlord,
+		Hand:       r.Hand,
+		LastPokers: r.LastPokers,
+		Played:     r.Played,
+		NumPokers:  r.NumPokers,
+		Lead:       r.Lead,
+		Leader:     r.Leader,
+		History:    make([]ai.Action, len(r.History)),
+	}
+	pos := r.Landlord
+	for i, kind := range r.History {
+		info.History[i] = ai.NewAction(pos, kind)
+		if kind.IsBomb() || kind.IsRocket() {
+			info.Bombs++
+		}
+		pos = pos.Next()
+	}
+	return info
+}
+
+// 访问次数分布,所有访问次数为 0 时返回 nil
+func (r *Record) Policy() []float64 {
+	var total float64
+	for _, v := range r.Visits {
+		total += float64(v)
+	}
+	if total == 0 {
+		return nil
+	}
+	policy := make([]float64, len(r.Visits))
+	for i, v := range r.Visits {
+		policy[i] = float64(v) / total
+	}
+	return policy
+}
+
+// 二进制编码,整数使用 varint 编码,牌型使用 Kind.MarshalBinary 并以 1 字节长度开头
+func (r *Record) MarshalBinary() ([]byte, error) {
+	if len(r.Visits) != len(r.Legal) || r.Choice < 0 || r.Choice >= len(r.Legal) {
+		return nil, fmt.Errorf("%w: choice %d of %d legal plays with %d visits", ErrBadFormat, r.Choice, len(r.Legal), len(r.Visits))
+	}
+	var e encoder
+	e.uvarint(uint64(r.Game))
+	e.uvarint(uint64(r.Step))
+	e.position(r.Self)
+	e.position(r.Landlord)
+	e.uvarint(uint64(r.Bid))
+	for _, multi := range r.Doubles {
+		e.uvarint(uint64(multi))
+	}
+	e.uvarint(uint64(r.LastPokers))
+	e.uvarint(uint64(r.Hand))
+	for _, pokers := range r.Played {
+		e.uvarint(uint64(pokers))
+	}
+	for _, n := range r.NumPokers {
+		e.uvarint(uint64(n))
+	}
+	e.kind(r.Lead)
+	e.position(r.Leader)
+	e.uvarint(uint64(len(r.History)))
+	for _, kind := range r.History {
+		e.kind(kind)
+	}
+	e.uvarint(uint64(len(r.Legal)))
+	for i, kind := range r.Legal {
+		e.kind(kind)
+		e.uvarint(uint64(r.Visits[i]))
+	}
+	e.uvarint(uint64(r.Choice))
+	e.position(r.Winner)
+	e.varint(int64(r.Score))
+	e.uvarint(uint64(r.Multiple))
+	e.bool(r.Spring)
+	return e.buf.Bytes(), e.err
+}
+
+func (r *Record) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	*r = Record{
+		Game:     int(d.uvarint()),
+		Step:     int(d.uvarint()),
+		Self:     d.position(),
+		Landlord: d.position(),
+		Bid:      int(d.uvarint()),
+	}
+	for i := range r.Doubles {
+		r.Doubles[i] = int(d.uvarint())
+	}
+	r.LastPokers = ai.PokerSet(d.uvarint())
+	r.Hand = ai.PokerSet(d.uvarint())
+	for i := range r.Played {
+		r.Played[i] = ai.PokerSet(d.uvarint())
+	}
+	for i := range r.NumPokers {
+		r.NumPokers[i] = int(d.uvarint())
+	}
+	r.Lead = d.kind()
+	r.Leader = d.position()
+	n := d.uvarint()
+	if n > uint64(len(data)) {
+		return ErrBadFormat
+	}
+	r.History = make([]ai.Kind, n)
+	for i := range r.History {
+		r.History[i] = d.kind()
+	}
+	n = d.uvarint()
+	if n > uint64(len(data)) {
+		return ErrBadFormat
+	}
+	r.Legal = make([]ai.Kind, n)
+	r.Visits = make([]uint32, n)
+	for i := range r.Legal {
+		r.Legal[i] = d.kind()
+		r.Visits[i] = uint32(d.uvarint())
+	}
+	r.Choice = int(d.uvarint())
+	r.Winner = d.position()
+	r.Score = int(d.varint())
+	r.Multiple = int(d.uvarint())
+	r.Spring = d.bool()
+	if d.err == nil && (len(d.data) != 0 || r.Choice >= len(r.Legal)) {
+		d.err = ErrBadFormat
+	}
+	return d.err
+}
+
+type encoder struct {
+	buf bytes.Buffer
+	tmp [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *encoder) uvarint(x uint64) { e.buf.Write(e.tmp[:binary.PutUvarint(e.tmp[:], x)]) }
+func (e *encoder) varint(x int64)   { e.buf.Write(e.tmp[:binary.PutVarint(e.tmp[:], x)]) }
+
+// 位置编码为 1 字节, BadPosition 编码为 0xFF
+func (e *encoder) position(pos ai.Position) { e.buf.WriteByte(byte(pos)) }
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *encoder) kind(kind ai.Kind) {
+	b, err := kind.MarshalBinary()
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	e.buf.WriteByte(byte(len(b)))
+	e.buf.Write(b)
+}
+
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = ErrBadFormat
+	}
+	d.data = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *decoder) varint() int64 {
+	x, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail()
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) position() ai.Position {
+	pos := ai.Position(int8(d.byte()))
+	if pos != ai.BadPosition && !pos.Valid() {
+		d.fail()
+	}
+	return pos
+}
+
+func (d *decoder) bool() bool { return d.byte() != 0 }
+
+func (d *decoder) kind() ai.Kind {
+	n := int(d.byte())
+	if n > len(d.data) {
+		d.fail()
+		return ai.Kind{}
+	}
+	var kind ai.Kind
+	if err := kind.UnmarshalBinary(d.data[:n]); err != nil {
+		d.fail()
+		return ai.Kind{}
+	}
+	d.data = d.data[n:]
+	return kind
+}
+
+// 记录写入器
+type Writer struct {
+	w   *bufio.Writer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+// 创建记录写入器并写入文件头
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(magic)
+	if err := bw.WriteByte(Version); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw}, nil
+}
+
+// 写入一条记录
+func (w *Writer) Write(r *Record) error {
+	data, err := r.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	w.w.Write(w.tmp[:binary.PutUvarint(w.tmp[:], uint64(len(data)))])
+	_, err = w.w.Write(data)
+	return err
+}
+
+// 将缓冲的数据写入底层 io.Writer
+func (w *Writer) Flush() error { return w.w.Flush() }
+
+// 记录读取器
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// 创建记录读取器并检查文件头
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	var header [len(magic) + 1]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadFormat, err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrBadFormat, header[:len(magic)])
+	}
+	if header[len(magic)] != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadFormat, header[len(magic)])
+	}
+	return &Reader{r: br}, nil
+}
+
+// 读取下一条记录,没有更多记录时返回 io.EOF
+func (r *Reader) Read() (*Record, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %v", ErrBadFormat, err)
+	}
+	if n > 1<<24 {
+		return nil, fmt.Errorf("%w: record too large (%d bytes)", ErrBadFormat, n)
+	}
+	if uint64(cap(r.buf)) < n {
+		r.buf = make([]byte, n)
+	}
+	r.buf = r.buf[:n]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadFormat, err)
+	}
+	record := new(Record)
+	if err := record.UnmarshalBinary(r.buf); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,302 @@
+// 自我对局: 由 MCTS AI 通过游戏引擎进行大量对局,记录每次出牌决策用于训练模型.
+//
+// 第 i 局使用 Seed+i 作为随机数种子发牌并初始化各个 AI 的随机数,
+// 所以相同的配置总是生成相同的记录,与并行数无关
+package selfplay
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/game"
+)
+
+// 自我对局配置
+type Config struct {
+	// 对局数
+	Games int
+	// 并行数, 0 表示 CPU 核数
+	Workers int
+	// 随机数种子
+	Seed int64
+	// AI 每次搜索的迭代次数, 0 表示根据剩余牌数自动决定
+	Iterations int
+	// 规则,零值表示 ai.DefaultOptions
+	Options ai.Options
+}
+
+// 统计数据
+type Stats struct {
+	// 完成的对局数
+	Games int
+	// 都不叫地主导致的重新发牌次数
+	Redeals int
+	// 写入的记录数
+	Records int
+	// 地主获胜的对局数
+	LandlordWins int
+}
+
+// 一局的结果
+type outcome struct {
+	index   int
+	records []Record
+	redeals int
+	err     error
+}
+
+// 并行进行自我对局,按对局编号顺序将记录写入 w. ctx 取消后不再开始新的对局
+func Run(ctx context.Context, cfg Config, w *Writer) (Stats, error) {
+	var stats Stats
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > cfg.Games {
+		workers = cfg.Games
+	}
+	opt := cfg.Options
+	if opt == (ai.Options{}) {
+		opt = ai.DefaultOptions
+	}
+	// 出错时不再开始新的对局
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		jobs    = make(chan int)
+		results = make(chan outcome, workers)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				records, redeals, err := Play(index, cfg.Seed+int64(index), cfg.Iterations, opt)
+				results <- outcome{index, records, redeals, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := 0; i < cfg.Games; i++ {
+			select {
+			case jobs <- i:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// 先完成的对局暂存起来,保证按编号顺序写入
+	var (
+		pending = make(map[int]outcome)
+		next    int
+		err     error
+	)
+	for result := range results {
+		pending[result.index] = result
+		for err == nil {
+			o, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err = o.err; err != nil {
+				break
+			}
+			stats.Games++
+			stats.Redeals += o.redeals
+			for i := range o.records {
+				if err = w.Write(&o.records[i]); err != nil {
+					break
+				}
+				stats.Records++
+			}
+			if err != nil {
+				break
+			}
+			if len(o.records) > 0 && o.records[0].Winner == o.records[0].Landlord {
+				stats.LandlordWins++
+			}
+		}
+		if err != nil {
+			cancel()
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return stats, err
+}
+
+// 使用随机数种子 seed 在规则 opt 下进行一局自我对局,返回该局所有出牌决策的记录和重新发牌次数
+func Play(index int, seed int64, iterations int, opt ai.Options) ([]Record, int, error) {
+	r := rand.New(rand.NewSource(seed))
+	for redeals := 0; ; redeals++ {
+		g := game.New(opt, r)
+		var bots [ai.NumPlayer]ai.Analyzer
+		for i := range bots {
+			bots[i] = ai.NewMCTSAIWithConfig(ai.MCTSConfig{
+				Rand:       rand.New(rand.NewSource(r.Int63())),
+				Iterations: iterations,
+				Options:    opt,
+			})
+			bots[i].SetSelf(ai.Position(i))
+		}
+		if !bid(g, &bots) {
+			continue
+		}
+		records, err := play(index, g, &bots)
+		return records, redeals, err
+	}
+}
+
+// 叫地主和加倍,都不叫时返回 false
+func bid(g *game.Game, bots *[ai.NumPlayer]ai.Analyzer) bool {
+	for g.Phase() == game.PhaseBid {
+		pos := g.Turn()
+		score := bots[pos].RecommendRob()
+		if score <= g.Bid() || score > game.MaxBid {
+			score = 0
+		}
+		if err := g.Rob(pos, score); err != nil {
+			panic(err)
+		}
+		for _, bot := range bots {
+			bot.Rob(pos, score)
+		}
+	}
+	landlord := g.Landlord()
+	if !landlord.Valid() {
+		return false
+	}
+	for _, bot := range bots {
+		bot.SetLandlord(landlord)
+		bot.SetLastPokers(g.LastPokers())
+	}
+	for g.Phase() == game.PhaseDouble {
+		pos := g.Turn()
+		var multi int
+		if bots[pos].RecommendDouble() > 1 {
+			multi = game.DoubleMultiple
+		}
+		if err := g.Double(pos, multi); err != nil {
+			panic(err)
+		}
+		for _, bot := range bots {
+			bot.Double(pos, multi)
+		}
+	}
+	return true
+}
+
+// 出牌直到牌局结束,记录每次出牌决策并在结束后填写对局结果
+func play(index int, g *game.Game, bots *[ai.NumPlayer]ai.Analyzer) ([]Record, error) {
+	var (
+		landlord = g.Landlord()
+		hands    [ai.NumPlayer]ai.PokerSet
+		played   [ai.NumPlayer]ai.PokerSet
+		records  []Record
+	)
+	for i := range hands {
+		hands[i] = g.StartHand(ai.Position(i))
+	}
+	for _, bot := range bots {
+		bot.Start(hands)
+	}
+	for g.Phase() == game.PhasePlay {
+		pos := g.Turn()
+		tag := pos.Role(landlord)
+		kind, result := bots[pos].Analyze(tag)
+		record := newRecord(index, len(records), g, pos, played)
+		for _, c := range result.Candidates {
+			for i, legal := range record.Legal {
+				if legal.Equal(c.Action.Kind()) {
+					record.Visits[i] = uint32(c.Visits)
+					break
+				}
+			}
+		}
+		record.Choice = -1
+		for i, legal := range record.Legal {
+			if legal.Equal(kind) {
+				record.Choice = i
+				break
+			}
+		}
+		if record.Choice < 0 {
+			return nil, fmt.Errorf("selfplay: game %d: %v played %v which is not legal", index, pos, kind)
+		}
+		// 不经搜索的出牌是确定的最优解
+		if result.Shortcut {
+			record.Visits[record.Choice] = 1
+		}
+		if err := g.Play(pos, kind); err != nil {
+			return nil, fmt.Errorf("selfplay: game %d: %w", index, err)
+		}
+		records = append(records, record)
+		played[pos].Add(kind.Pokers())
+		for _, bot := range bots {
+			bot.Play(tag, pos, kind)
+		}
+	}
+	for _, bot := range bots {
+		bot.Stop()
+	}
+	result, err := g.Settle()
+	if err != nil {
+		return nil, fmt.Errorf("selfplay: game %d: %w", index, err)
+	}
+	for i := range records {
+		r := &records[i]
+		r.Winner = result.Winner
+		r.Score = result.Scores[r.Self]
+		r.Multiple = result.Multiple
+		r.Spring = result.Spring
+	}
+	return records, nil
+}
+
+// 根据 pos 可以看到的信息创建决策记录
+func newRecord(index, step int, g *game.Game, pos ai.Position, played [ai.NumPlayer]ai.PokerSet) Record {
+	lead, leader := g.Lead()
+	if lead.Len() == 0 {
+		leader = ai.BadPosition
+	}
+	r := Record{
+		Game:       index,
+		Step:       step,
+		Self:       pos,
+		Landlord:   g.Landlord(),
+		Bid:        g.Bid(),
+		Doubles:    g.Doubles(),
+		LastPokers: g.LastPokers(),
+		Hand:       g.Hand(pos),
+		Played:     played,
+		Lead:       lead,
+		Leader:     leader,
+		History:    make([]ai.Kind, len(g.History())),
+		Legal:      g.Hand(pos).MatchAll(ai.Kind{}, lead, g.Options()),
+	}
+	for i, action := range g.History() {
+		r.History[i] = action.Kind()
+	}
+	for i := range r.NumPokers {
+		r.NumPokers[i] = g.NumPokers(ai.Position(i))
+	}
+	r.Visits = make([]uint32, len(r.Legal))
+	return r
+}
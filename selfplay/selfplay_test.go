@@ -0,0 +1,112 @@
+package selfplay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+func generate(t *testing.T, cfg Config) ([]byte, Stats) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	stats, err := Run(context.Background(), cfg, w)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	return buf.Bytes(), stats
+}
+
+// 相同的种子生成相同的数据,与并行数无关
+func TestDeterminism(t *testing.T) {
+	cfg := Config{Games: 3, Workers: 3, Seed: 7, Iterations: 30}
+	data1, stats := generate(t, cfg)
+	cfg.Workers = 1
+	data2, _ := generate(t, cfg)
+	if !bytes.Equal(data1, data2) {
+		t.Fatalf("self-play with the same seed should generate the same data")
+	}
+	if stats.Games != cfg.Games || stats.Records == 0 {
+		t.Fatalf("bad stats %+v", stats)
+	}
+	cfg.Seed++
+	data3, _ := generate(t, cfg)
+	if bytes.Equal(data1, data3) {
+		t.Fatalf("different seeds should generate different data")
+	}
+}
+
+func TestRecords(t *testing.T) {
+	cfg := Config{Games: 2, Workers: 2, Seed: 1, Iterations: 30}
+	data, stats := generate(t, cfg)
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	var records []*Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != stats.Records {
+		t.Fatalf("read %d records, want %d", len(records), stats.Records)
+	}
+	for i, record := range records {
+		if len(record.Legal) == 0 || record.Legal[record.Choice].Len() > 0 && !record.Hand.Contains(record.Legal[record.Choice].Pokers()) {
+			t.Fatalf("record %d: bad choice %d of %v", i, record.Choice, record.Legal)
+		}
+		var visits uint32
+		for _, v := range record.Visits {
+			visits += v
+		}
+		if visits == 0 || record.Policy() == nil {
+			t.Fatalf("record %d: no visits", i)
+		}
+		if record.NumPokers[record.Self] != record.Hand.Len() {
+			t.Fatalf("record %d: hand %v has %d pokers", i, record.Hand, record.NumPokers[record.Self])
+		}
+		won := (record.Winner == record.Landlord) == (record.Self == record.Landlord)
+		if won != (record.Score > 0) {
+			t.Fatalf("record %d: winner %v, landlord %v, self %v, score %d", i, record.Winner, record.Landlord, record.Self, record.Score)
+		}
+		if len(record.History) != record.Step {
+			t.Fatalf("record %d: step %d with %d history plays", i, record.Step, len(record.History))
+		}
+		if f := record.InfoSet().Features(); len(f) != ai.FeatureSize {
+			t.Fatalf("record %d: %d features", i, len(f))
+		}
+		if record.Step == 0 {
+			if record.Self != record.Landlord || record.Lead.Len() != 0 || record.Leader != ai.BadPosition {
+				t.Fatalf("record %d: landlord should lead first", i)
+			}
+		}
+		// 重新编码后结果不变
+		b, err := record.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var decoded Record
+		if err := decoded.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if !reflect.DeepEqual(&decoded, record) {
+			t.Fatalf("record %d changed after encoding: %+v", i, decoded)
+		}
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("bad"))); err == nil {
+		t.Fatalf("bad header should fail")
+	}
+}
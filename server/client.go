@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+// 客户端: 以玩家或观察者身份连接牌桌
+type Client struct {
+	ws *websocket.Conn
+	// 座位, 观察者为 BadPosition
+	Seat ai.Position
+	// 重连凭证
+	Token string
+}
+
+// 以玩家身份加入牌桌, base 为服务器地址(如 ws://127.0.0.1:8080), token 不为空时表示断线重连.
+// 连接成功后会等待 welcome 消息,返回时 Seat 和 Token 已经设置
+func DialPlayer(base, table string, seat ai.Position, token string) (*Client, *Snapshot, error) {
+	query := url.Values{"seat": {fmt.Sprint(seat)}}
+	if token != "" {
+		query.Set("token", token)
+	}
+	c, err := dial(fmt.Sprintf("%s/tables/%s/play?%s", base, url.PathEscape(table), query.Encode()))
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := c.Recv()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	if msg.Type != MsgWelcome {
+		c.Close()
+		return nil, nil, fmt.Errorf("server: expected welcome, got %q %s", msg.Type, msg.Error)
+	}
+	c.Seat, c.Token = msg.Seat, msg.Token
+	return c, msg.Snapshot, nil
+}
+
+// 以观察者身份连接牌桌
+func DialWatcher(base, table string) (*Client, error) {
+	c, err := dial(fmt.Sprintf("%s/tables/%s/watch", base, url.PathEscape(table)))
+	if err != nil {
+		return nil, err
+	}
+	c.Seat = ai.BadPosition
+	return c, nil
+}
+
+func dial(rawurl string) (*Client, error) {
+	ws, resp, err := websocket.DefaultDialer.Dial(rawurl, nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w: %s", err, resp.Status)
+		}
+		return nil, err
+	}
+	return &Client{ws: ws}, nil
+}
+
+// 接收一条消息
+func (c *Client) Recv() (Message, error) {
+	var msg Message
+	err := c.ws.ReadJSON(&msg)
+	return msg, err
+}
+
+// 发送一条消息
+func (c *Client) Send(msg Message) error { return c.ws.WriteJSON(msg) }
+
+// 叫地主, score 为 0 表示不叫
+func (c *Client) Rob(score int) error { return c.Send(Message{Type: MsgRob, Score: score}) }
+
+// 加倍, multi 为 0 表示不加倍
+func (c *Client) Double(multi int) error { return c.Send(Message{Type: MsgDouble, Multi: multi}) }
+
+// 出牌, pokers 为空表示不出
+func (c *Client) Play(pokers ai.PokerSet) error {
+	return c.Send(Message{Type: MsgPlay, Pokers: pokers.ToInt32s(nil)})
+}
+
+// 关闭连接
+func (c *Client) Close() error { return c.ws.Close() }
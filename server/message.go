@@ -0,0 +1,82 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/game"
+)
+
+// 消息类型
+const (
+	// 客户端 -> 服务器
+	MsgRob    = "rob"    // 叫地主: score
+	MsgDouble = "double" // 加倍: multi
+	MsgPlay   = "play"   // 出牌: pokers, 为空表示不出
+
+	// 服务器 -> 客户端
+	MsgWelcome  = "welcome"  // 加入座位成功: seat, token, snapshot
+	MsgSnapshot = "snapshot" // 牌桌状态: snapshot
+	MsgEvent    = "event"    // 玩家动作: event
+	MsgError    = "error"    // 错误: error
+)
+
+// WebSocket 消息,每条消息是一个 JSON 对象,各类型只使用其中的一部分字段
+type Message struct {
+	Type string `json:"type"`
+
+	Score  int     `json:"score,omitempty"`
+	Multi  int     `json:"multi,omitempty"`
+	Pokers []int32 `json:"pokers,omitempty"`
+
+	Seat     ai.Position `json:"seat"`
+	Token    string      `json:"token,omitempty"`
+	Snapshot *Snapshot   `json:"snapshot,omitempty"`
+	Event    *Event      `json:"event,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// 玩家动作
+type Event struct {
+	Round  int         `json:"round"`
+	Seat   ai.Position `json:"seat"`
+	Action string      `json:"action"`
+	Score  int         `json:"score,omitempty"`
+	Multi  int         `json:"multi,omitempty"`
+	Pokers []int32     `json:"pokers,omitempty"`
+	// 是否为超时或掉线时由 AI 代替完成的动作
+	Auto bool `json:"auto,omitempty"`
+}
+
+// 座位信息
+type SeatInfo struct {
+	// 是否为内置 AI
+	Bot bool `json:"bot"`
+	// 是否有玩家占用
+	Taken bool `json:"taken"`
+	// 玩家是否在线
+	Online bool `json:"online"`
+}
+
+// 牌桌状态: 只包含观察者可见的信息,玩家可以看到自己的手牌
+type Snapshot struct {
+	Table string `json:"table"`
+	Round int    `json:"round"`
+	// 每次等待新的动作时递增,客户端可以用来避免对同一状态重复动作
+	Seq       int                    `json:"seq"`
+	Phase     string                 `json:"phase"`
+	Seats     [ai.NumPlayer]SeatInfo `json:"seats"`
+	Turn      ai.Position            `json:"turn"`
+	Deadline  *time.Time             `json:"deadline,omitempty"`
+	Landlord  ai.Position            `json:"landlord"`
+	Bid       int                    `json:"bid"`
+	Bids      [ai.NumPlayer]int      `json:"bids"`
+	Doubles   [ai.NumPlayer]int      `json:"doubles"`
+	NumPokers [ai.NumPlayer]int      `json:"num_pokers"`
+	Hand      []int32                `json:"hand,omitempty"`
+	Last      []int32                `json:"last_pokers,omitempty"`
+	Lead      []int32                `json:"lead,omitempty"`
+	Leader    ai.Position            `json:"leader"`
+	Result    *game.Result           `json:"result,omitempty"`
+	Scores    [ai.NumPlayer]int      `json:"scores"`
+}
@@ -0,0 +1,279 @@
+// 斗地主游戏服务器: 通过 HTTP 和 WebSocket 托管多个牌桌
+//
+// HTTP 接口:
+//
+//	POST /tables                 创建牌桌,请求体为 TableConfig, 返回 {"id": "..."}
+//	GET  /tables                 列出所有牌桌的状态
+//	GET  /tables/{id}            获取牌桌状态(观察者视角)
+//	GET  /tables/{id}/play       WebSocket: 以玩家身份加入,参数 seat 为座位, token 用于断线重连
+//	GET  /tables/{id}/watch      WebSocket: 以观察者身份接收牌桌事件
+//
+// WebSocket 消息格式见 Message
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+// 服务器配置
+type Config struct {
+	// 玩家每次动作的时限,超时后由 AI 代替玩家完成动作
+	TurnTimeout time.Duration
+	// 断线玩家每次动作的时限,超时后由托管 AI 代替玩家完成动作. 0 表示与 TurnTimeout 相同
+	OfflineTimeout time.Duration
+	// 内置 AI 每次动作前的等待时间
+	BotDelay time.Duration
+	// 一局结束后开始下一局的等待时间,小于 0 表示不自动开始下一局
+	RoundDelay time.Duration
+	// 随机数种子,用于发牌
+	Seed int64
+	// 按牌桌的规则创建内置 AI, 默认为 ai.NewMCTSAIWithConfig
+	NewAI func(ai.Options) ai.AI
+	// 从玩家视角的牌局记录和牌桌的规则创建代替玩家出牌的托管 AI, 默认为 ai.NewTrusteeAI
+	NewTrustee func(ai.Record, ai.Options) (ai.AI, error)
+}
+
+var DefaultConfig = Config{
+	TurnTimeout:    30 * time.Second,
+	OfflineTimeout: 3 * time.Second,
+	BotDelay:       time.Second,
+	RoundDelay:     5 * time.Second,
+}
+
+// 创建牌桌的参数
+type TableConfig struct {
+	// 规则预设名称,默认为 classic
+	Rules string `json:"rules"`
+	// 由内置 AI 占用的座位
+	Bots []ai.Position `json:"bots"`
+}
+
+// 游戏服务器
+type Server struct {
+	cfg      Config
+	upgrader websocket.Upgrader
+
+	mu     sync.Mutex
+	nextID int
+	tables map[string]*Table
+}
+
+func New(cfg Config) *Server {
+	if cfg.NewAI == nil {
+		cfg.NewAI = func(opt ai.Options) ai.AI { return ai.NewMCTSAIWithConfig(ai.MCTSConfig{Options: opt}) }
+	}
+	if cfg.NewTrustee == nil {
+		cfg.NewTrustee = func(record ai.Record, opt ai.Options) (ai.AI, error) {
+			return ai.NewTrusteeAI(record, ai.MCTSConfig{Options: opt})
+		}
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	return &Server{
+		cfg:    cfg,
+		tables: make(map[string]*Table),
+	}
+}
+
+// 创建牌桌
+func (s *Server) CreateTable(tc TableConfig) (*Table, error) {
+	name := tc.Rules
+	if name == "" {
+		name = ai.PresetClassic.Name
+	}
+	preset, ok := ai.LookupPreset(name)
+	if !ok {
+		return nil, fmt.Errorf("server: unknown rules %q", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	cfg := s.cfg
+	cfg.Seed += int64(s.nextID)
+	t, err := newTable(strconv.Itoa(s.nextID), cfg, preset.Options, tc.Bots)
+	if err != nil {
+		return nil, err
+	}
+	s.tables[t.id] = t
+	t.mu.Lock()
+	t.maybeStart()
+	t.mu.Unlock()
+	return t, nil
+}
+
+// 获取牌桌
+func (s *Server) Table(id string) *Table {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tables[id]
+}
+
+// 所有牌桌,按编号排列
+func (s *Server) Tables() []*Table {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tables := make([]*Table, 0, len(s.tables))
+	for _, t := range s.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		a, _ := strconv.Atoi(tables[i].id)
+		b, _ := strconv.Atoi(tables[j].id)
+		return a < b
+	})
+	return tables
+}
+
+// 关闭所有牌桌
+func (s *Server) Close() {
+	for _, t := range s.Tables() {
+		t.Close()
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 0 || parts[0] != "tables" {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			var snapshots []Snapshot
+			for _, t := range s.Tables() {
+				snapshots = append(snapshots, t.Snapshot())
+			}
+			writeJSON(w, http.StatusOK, snapshots)
+		case http.MethodPost:
+			var tc TableConfig
+			if err := json.NewDecoder(r.Body).Decode(&tc); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			t, err := s.CreateTable(tc)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"id": t.id})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	t := s.Table(parts[1])
+	if t == nil || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 2 {
+		writeJSON(w, http.StatusOK, t.Snapshot())
+		return
+	}
+	switch parts[2] {
+	case "play":
+		s.servePlayer(w, r, t)
+	case "watch":
+		s.serveWatcher(w, r, t)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) servePlayer(w http.ResponseWriter, r *http.Request, t *Table) {
+	n, err := strconv.Atoi(r.URL.Query().Get("seat"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrBadSeat)
+		return
+	}
+	var (
+		pos = ai.Position(n)
+		c   = newConn()
+	)
+	// 升级前先占座,失败时可以返回 HTTP 错误
+	if _, err := t.join(pos, r.URL.Query().Get("token"), c); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrSeatTaken) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		t.leave(pos, c)
+		return
+	}
+	go writeLoop(ws, c)
+	defer t.leave(pos, c)
+	for {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			return
+		}
+		if err := t.handle(pos, c, msg); err != nil {
+			c.push(Message{Type: MsgError, Seat: pos, Error: err.Error()})
+		}
+	}
+}
+
+func (s *Server) serveWatcher(w http.ResponseWriter, r *http.Request, t *Table) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := newConn()
+	if err := t.watch(c); err != nil {
+		ws.Close()
+		return
+	}
+	go writeLoop(ws, c)
+	defer t.unwatch(c)
+	for {
+		// 观察者不发送消息,读取只用于检测断开
+		if _, _, err := ws.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// 将连接发送队列中的消息写入 WebSocket, 连接关闭时关闭 WebSocket
+func writeLoop(ws *websocket.Conn, c *conn) {
+	defer ws.Close()
+	for {
+		select {
+		case <-c.closed:
+			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		case msg := <-c.send:
+			if err := ws.WriteJSON(msg); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
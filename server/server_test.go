@@ -0,0 +1,473 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+func newTestServer(t *testing.T, cfg Config) (*Server, string) {
+	cfg.NewAI = ai.NewHintAI
+	cfg.NewTrustee = func(record ai.Record, opt ai.Options) (ai.AI, error) {
+		return ai.NewTrusteeAI(record, ai.MCTSConfig{Iterations: 40, Options: opt})
+	}
+	cfg.Seed = 1
+	s := New(cfg)
+	hs := httptest.NewServer(s)
+	t.Cleanup(func() {
+		s.Close()
+		hs.Close()
+	})
+	return s, "ws" + strings.TrimPrefix(hs.URL, "http")
+}
+
+// 等待满足条件的消息
+func waitFor(t *testing.T, c *Client, what string, cond func(Message) bool) Message {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		msg, err := c.Recv()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", what, err)
+		}
+		if cond(msg) {
+			return msg
+		}
+	}
+	t.Fatalf("timeout waiting for %s", what)
+	return Message{}
+}
+
+func isOver(msg Message) bool {
+	return msg.Type == MsgSnapshot && msg.Snapshot.Result != nil
+}
+
+// 玩家加入后不做任何动作,所有动作都由超时后的 AI 代替完成
+func TestTurnTimeout(t *testing.T) {
+	s, base := newTestServer(t, Config{TurnTimeout: 10 * time.Millisecond, RoundDelay: -1})
+	table, err := s.CreateTable(TableConfig{Bots: []ai.Position{1, 2}})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	watcher, err := DialWatcher(base, table.ID())
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	defer watcher.Close()
+	player, snapshot, err := DialPlayer(base, table.ID(), 0, "")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	defer player.Close()
+	if player.Seat != 0 || player.Token == "" || snapshot == nil {
+		t.Fatalf("bad welcome: seat %v, token %q", player.Seat, player.Token)
+	}
+
+	var autos int
+	msg := waitFor(t, watcher, "game over", func(msg Message) bool {
+		if msg.Type == MsgEvent && msg.Event.Seat == 0 && msg.Event.Auto {
+			autos++
+		}
+		if msg.Type == MsgSnapshot && msg.Snapshot.Hand != nil {
+			t.Fatalf("watcher can see hand")
+		}
+		return isOver(msg)
+	})
+	if autos == 0 {
+		t.Fatalf("player's actions should be done by AI after timeout")
+	}
+	result := msg.Snapshot.Result
+	if !result.Winner.Valid() || result.Scores[0]+result.Scores[1]+result.Scores[2] != 0 {
+		t.Fatalf("bad result %+v", result)
+	}
+}
+
+// 玩家自己叫地主和出牌,中途断线重连
+func TestPlayAndReconnect(t *testing.T) {
+	s, base := newTestServer(t, Config{TurnTimeout: time.Minute, RoundDelay: -1})
+	table, err := s.CreateTable(TableConfig{Bots: []ai.Position{1, 2}})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	player, _, err := DialPlayer(base, table.ID(), 0, "")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	defer func() { player.Close() }()
+	if _, _, err := DialPlayer(base, table.ID(), 0, ""); err == nil {
+		t.Fatalf("seat should be taken")
+	}
+	if _, _, err := DialPlayer(base, table.ID(), 1, ""); err == nil {
+		t.Fatalf("bot seat can't be joined")
+	}
+
+	var (
+		acted       = -1
+		reconnected bool
+		invalid     bool
+		snap        *Snapshot
+	)
+	for {
+		msg, err := player.Recv()
+		if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		switch msg.Type {
+		case MsgError:
+			// 不合法的出牌返回错误,状态不变,重新出牌
+			if !invalid {
+				t.Fatalf("unexpected error: %s", msg.Error)
+			}
+		case MsgSnapshot:
+			snap = msg.Snapshot
+			if snap.Result != nil {
+				if !reconnected || snap.Result.Scores[0]+snap.Result.Scores[1]+snap.Result.Scores[2] != 0 {
+					t.Fatalf("bad result %+v", snap.Result)
+				}
+				return
+			}
+			if snap.Turn != 0 || snap.Seq == acted {
+				continue
+			}
+		default:
+			continue
+		}
+		acted = snap.Seq
+		if snap.Deadline == nil {
+			t.Fatalf("player's turn should have a deadline")
+		}
+		hand := ai.NewPokerSetWithInt32s(snap.Hand)
+		switch snap.Phase {
+		case "bid":
+			err = player.Rob(3)
+		case "double":
+			err = player.Double(2)
+		case "play":
+			if !reconnected {
+				// 断线重连后状态不变
+				reconnected = true
+				player.Close()
+				player, snap, err = DialPlayer(base, table.ID(), 0, player.Token)
+				if err != nil {
+					t.Fatalf("reconnect: %v", err)
+				}
+				if ai.NewPokerSetWithInt32s(snap.Hand) != hand || snap.Turn != 0 || snap.Seq != acted {
+					t.Fatalf("bad snapshot after reconnect: %+v", snap)
+				}
+			}
+			switch {
+			case len(snap.Lead) > 0:
+				err = player.Play(0)
+			case !invalid:
+				invalid = true
+				err = player.Play(ai.NewPokerSetWithInt32s([]int32{snap.Hand[0], snap.Hand[len(snap.Hand)-1]}))
+			default:
+				err = player.Play(ai.Hint(hand, ai.Kind{}, false, ai.DefaultOptions)[0].Pokers())
+			}
+		}
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+}
+
+// 玩家断线后由托管 AI 代替出牌,重连后交还控制
+func TestOfflineTrustee(t *testing.T) {
+	s, base := newTestServer(t, Config{TurnTimeout: time.Minute, OfflineTimeout: 10 * time.Millisecond, RoundDelay: -1})
+	table, err := s.CreateTable(TableConfig{Bots: []ai.Position{1, 2}})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	watcher, err := DialWatcher(base, table.ID())
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	defer watcher.Close()
+	player, _, err := DialPlayer(base, table.ID(), 0, "")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	defer func() { player.Close() }()
+
+	var (
+		acted    = -1
+		returned bool
+	)
+	for {
+		msg, err := player.Recv()
+		if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		if msg.Type != MsgSnapshot {
+			continue
+		}
+		snap := msg.Snapshot
+		if snap.Result != nil {
+			if !returned {
+				t.Fatalf("game over before player returned")
+			}
+			return
+		}
+		if snap.Turn != 0 || snap.Seq == acted {
+			continue
+		}
+		acted = snap.Seq
+		switch snap.Phase {
+		case "bid":
+			err = player.Rob(3)
+		case "double":
+			err = player.Double(0)
+		case "play":
+			if !returned {
+				// 断线后轮到自己时很快由托管 AI 出牌
+				returned = true
+				player.Close()
+				waitFor(t, watcher, "trustee play", func(msg Message) bool {
+					return msg.Type == MsgEvent && msg.Event.Seat == 0 && msg.Event.Action == MsgPlay && msg.Event.Auto
+				})
+				player, snap, err = DialPlayer(base, table.ID(), 0, player.Token)
+				if err != nil {
+					t.Fatalf("reconnect: %v", err)
+				}
+				if snap.Result != nil {
+					return
+				}
+				acted = -1
+				continue
+			}
+			if snap.Deadline == nil || time.Until(*snap.Deadline) < 30*time.Second {
+				t.Fatalf("returned player should have a full turn, deadline %v", snap.Deadline)
+			}
+			hand := ai.NewPokerSetWithInt32s(snap.Hand)
+			if len(snap.Lead) > 0 {
+				err = player.Play(0)
+			} else {
+				err = player.Play(ai.Hint(hand, ai.Kind{}, false, ai.DefaultOptions)[0].Pokers())
+			}
+		}
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+}
+
+// 搜索时阻塞直到 release 关闭的内置 AI
+type blockingBot struct {
+	ai.Analyzer
+	thinking chan struct{}
+	release  chan struct{}
+}
+
+func (b *blockingBot) Snapshot() ai.Snapshot       { return b.Analyzer.(ai.Snapshotter).Snapshot() }
+func (b *blockingBot) Restore(s ai.Snapshot) error { return b.Analyzer.(ai.Snapshotter).Restore(s) }
+
+func (b *blockingBot) AnalyzeContext(ctx context.Context, tag string) (ai.Kind, ai.SearchResult) {
+	select {
+	case b.thinking <- struct{}{}:
+	default:
+	}
+	<-b.release
+	return b.Analyzer.AnalyzeContext(ctx, tag)
+}
+
+// 内置 AI 搜索时不持有牌桌的锁
+func TestSearchWithoutLock(t *testing.T) {
+	var (
+		thinking = make(chan struct{}, 1)
+		release  = make(chan struct{})
+		cfg      = Config{RoundDelay: -1, Seed: 1}
+	)
+	cfg.NewAI = func(opt ai.Options) ai.AI {
+		return &blockingBot{ai.NewMCTSAIWithConfig(ai.MCTSConfig{Iterations: 10, Options: opt}), thinking, release}
+	}
+	table, err := newTable("1", cfg, ai.DefaultOptions, []ai.Position{0, 1, 2})
+	if err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	defer table.Close()
+	defer close(release)
+	table.mu.Lock()
+	table.maybeStart()
+	table.mu.Unlock()
+
+	select {
+	case <-thinking:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("bot should start thinking")
+	}
+	done := make(chan Snapshot)
+	go func() { done <- table.Snapshot() }()
+	select {
+	case snapshot := <-done:
+		if snapshot.Phase != "play" {
+			t.Fatalf("bot should be thinking in play phase, got %v", snapshot.Phase)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("table is locked while bot is thinking")
+	}
+}
+
+// 玩家出的牌按实际的花色解析,不在手中的牌不能出
+func TestParseKindSuits(t *testing.T) {
+	cfg := Config{RoundDelay: -1, BotDelay: time.Hour, Seed: 1}
+	cfg.NewAI = ai.NewHintAI
+	table, err := newTable("1", cfg, ai.DefaultOptions, []ai.Position{0, 1, 2})
+	if err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	defer table.Close()
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	table.startRound()
+	hand := table.game.Hand(0)
+	var own, other ai.PokerSet
+	for _, p := range hand.ToInt32s(nil) {
+		pset := ai.NewPokerSetWithInt32s([]int32{p})
+		// 找一张自己有,但同点数其他花色不全在手中的牌
+		for _, q := range ai.FullDeck.ToInt32s(nil) {
+			qset := ai.NewPokerSetWithInt32s([]int32{q})
+			if !hand.Contains(qset) && qset.Notation() == pset.Notation() {
+				own, other = pset, qset
+			}
+		}
+	}
+	if own.Empty() {
+		t.Fatalf("no suitable poker in %v", hand)
+	}
+	kind, err := table.parseKind(0, own.ToInt32s(nil))
+	if err != nil || kind.Pokers() != own {
+		t.Fatalf("should play %v, got %v, %v", own, kind, err)
+	}
+	if kind, err := table.parseKind(0, other.ToInt32s(nil)); err == nil {
+		t.Fatalf("%v is not in hand, but parsed as %v", other, kind)
+	}
+}
+
+// 关闭牌桌时停止内置 AI 的搜索
+func TestCloseStopsSearch(t *testing.T) {
+	var (
+		thinking = make(chan struct{}, 1)
+		done     = make(chan struct{}, 1)
+		cfg      = Config{RoundDelay: -1, Seed: 1}
+	)
+	cfg.NewAI = func(opt ai.Options) ai.AI {
+		return &slowBot{ai.NewMCTSAIWithConfig(ai.MCTSConfig{Iterations: 1 << 30, Options: opt}), thinking, done}
+	}
+	table, err := newTable("1", cfg, ai.DefaultOptions, []ai.Position{0, 1, 2})
+	if err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	table.mu.Lock()
+	table.maybeStart()
+	table.mu.Unlock()
+	select {
+	case <-thinking:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("bot should start thinking")
+	}
+	table.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("search should stop after the table is closed")
+	}
+}
+
+// 搜索次数很多,开始和结束搜索时发出通知的内置 AI
+type slowBot struct {
+	ai.Analyzer
+	thinking chan struct{}
+	done     chan struct{}
+}
+
+func (b *slowBot) Snapshot() ai.Snapshot       { return b.Analyzer.(ai.Snapshotter).Snapshot() }
+func (b *slowBot) Restore(s ai.Snapshot) error { return b.Analyzer.(ai.Snapshotter).Restore(s) }
+
+func (b *slowBot) AnalyzeContext(ctx context.Context, tag string) (ai.Kind, ai.SearchResult) {
+	b.thinking <- struct{}{}
+	defer func() { b.done <- struct{}{} }()
+	return b.Analyzer.AnalyzeContext(ctx, tag)
+}
+
+// 断线重连不能延长动作时间,只有托管 AI 代替出过牌后重连才有完整的动作时间
+func TestReconnectDeadline(t *testing.T) {
+	cfg := Config{TurnTimeout: time.Hour, OfflineTimeout: time.Minute, RoundDelay: -1, Seed: 1}
+	cfg.NewAI = ai.NewHintAI
+	table, err := newTable("1", cfg, ai.DefaultOptions, []ai.Position{1, 2})
+	if err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	defer table.Close()
+	c := newConn()
+	token, err := table.join(0, "", c)
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		table.mu.Lock()
+		waiting := table.waiting(0)
+		table.mu.Unlock()
+		if waiting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("player's turn never comes")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	reconnect := func() time.Time {
+		table.leave(0, c)
+		time.Sleep(20 * time.Millisecond)
+		c = newConn()
+		if _, err := table.join(0, token, c); err != nil {
+			t.Fatalf("reconnect: %v", err)
+		}
+		table.mu.Lock()
+		defer table.mu.Unlock()
+		return table.deadline
+	}
+
+	table.mu.Lock()
+	turn := table.deadline
+	table.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		if d := reconnect(); d.After(turn.Add(time.Millisecond)) || d.Before(turn.Add(-time.Millisecond)) {
+			t.Fatalf("reconnect should restore deadline %v, got %v", turn, d)
+		}
+	}
+	table.mu.Lock()
+	table.seats[0].handback = true
+	table.mu.Unlock()
+	if d := reconnect(); !d.After(turn.Add(10 * time.Millisecond)) {
+		t.Fatalf("player should have a full turn after trustee play, got %v", d)
+	}
+}
+
+// 没有等待的动作时快照中没有截止时间
+func TestSnapshotDeadline(t *testing.T) {
+	cfg := Config{TurnTimeout: time.Hour, BotDelay: time.Hour, RoundDelay: -1, Seed: 1, NewAI: ai.NewHintAI}
+	table, err := newTable("1", cfg, ai.DefaultOptions, []ai.Position{1, 2})
+	if err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	defer table.Close()
+	data, err := json.Marshal(table.Snapshot())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), "deadline") {
+		t.Fatalf("waiting table should have no deadline: %s", data)
+	}
+	if _, err := table.join(0, "", newConn()); err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	if snap := table.Snapshot(); snap.Deadline == nil || time.Until(*snap.Deadline) <= 0 {
+		t.Fatalf("started table should have a deadline, got %v", snap.Deadline)
+	}
+}
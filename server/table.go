@@ -0,0 +1,670 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/gopherd/log"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/game"
+)
+
+var (
+	ErrSeatTaken   = errors.New("server: seat taken")
+	ErrBadSeat     = errors.New("server: bad seat")
+	ErrBadMessage  = errors.New("server: bad message")
+	ErrTableClosed = errors.New("server: table closed")
+)
+
+// 连接发送队列长度,队列满时断开连接
+const sendQueueSize = 64
+
+// 一个客户端连接(玩家或观察者)
+type conn struct {
+	send      chan Message
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newConn() *conn {
+	return &conn{
+		send:   make(chan Message, sendQueueSize),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *conn) close() {
+	c.closeOnce.Do(func() { close(c.closed) })
+}
+
+// 发送消息,不会阻塞
+func (c *conn) push(msg Message) {
+	select {
+	case <-c.closed:
+	case c.send <- msg:
+	default:
+		c.close()
+	}
+}
+
+// 座位
+type seat struct {
+	bot   bool
+	token string
+	conn  *conn
+	// 内置 AI: 为 bot 动作,或在玩家超时时代替玩家叫地主和加倍.
+	// 玩家超时或断线时由每次从牌局记录创建的托管 AI 只根据玩家自己的信息代替玩家出牌
+	ai ai.AI
+	// 托管 AI 代替玩家出过牌,玩家重连后第一次轮到自己时有完整的动作时间
+	handback bool
+}
+
+// 玩家断线
+func (s *seat) offline() bool { return !s.bot && s.token != "" && s.conn == nil }
+
+func (s *seat) taken() bool { return s.bot || s.token != "" }
+
+// 牌桌
+type Table struct {
+	id   string
+	cfg  Config
+	opt  ai.Options
+	rand *mathrand.Rand
+	// 关闭牌桌时取消,用于停止 AI 的搜索
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	closed   bool
+	round    int
+	game     *game.Game
+	result   *game.Result
+	scores   [ai.NumPlayer]int
+	seats    [ai.NumPlayer]*seat
+	watchers map[*conn]struct{}
+	// 每次等待玩家动作时递增,用于忽略过期的计时器
+	seq      int
+	timer    *time.Timer
+	deadline time.Time
+	// 当前动作在玩家在线时的截止时间,断线后重连时恢复
+	turnDeadline time.Time
+	// 正在不持有锁搜索的动作的 seq
+	thinking int
+}
+
+func newTable(id string, cfg Config, opt ai.Options, bots []ai.Position) (*Table, error) {
+	t := &Table{
+		id:       id,
+		cfg:      cfg,
+		opt:      opt,
+		rand:     mathrand.New(mathrand.NewSource(cfg.Seed)),
+		watchers: make(map[*conn]struct{}),
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	for i := range t.seats {
+		t.seats[i] = new(seat)
+	}
+	for _, pos := range bots {
+		if !pos.Valid() {
+			return nil, fmt.Errorf("%w: %d", ErrBadSeat, pos)
+		}
+		t.seats[pos].bot = true
+	}
+	return t, nil
+}
+
+func (t *Table) ID() string { return t.id }
+
+// 当前状态(观察者视角)
+func (t *Table) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot(ai.BadPosition)
+}
+
+// 关闭牌桌,停止 AI 的搜索并断开所有连接
+func (t *Table) Close() {
+	// 先取消搜索,持有锁的搜索也能尽快结束
+	t.cancel()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.close()
+}
+
+func (t *Table) close() {
+	if t.closed {
+		return
+	}
+	t.closed = true
+	t.cancel()
+	t.stopTimer()
+	for _, s := range t.seats {
+		if s.conn != nil {
+			s.conn.close()
+		}
+	}
+	for c := range t.watchers {
+		c.close()
+	}
+}
+
+// 玩家加入座位: token 为空表示新加入,否则为断线重连
+func (t *Table) join(pos ai.Position, token string, c *conn) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return "", ErrTableClosed
+	}
+	if !pos.Valid() || t.seats[pos].bot {
+		return "", fmt.Errorf("%w: %d", ErrBadSeat, pos)
+	}
+	s := t.seats[pos]
+	if s.token != "" && s.token != token {
+		return "", ErrSeatTaken
+	}
+	if s.token == "" {
+		s.token = newToken()
+	}
+	if s.conn != nil {
+		s.conn.close()
+	}
+	reconnected := s.conn == nil
+	s.conn = c
+	if reconnected && t.waiting(pos) {
+		if s.handback {
+			// 托管后交还控制: 重新开始计时
+			t.resetTimer(t.cfg.TurnTimeout)
+		} else if d := time.Until(t.turnDeadline); d > time.Until(t.deadline) {
+			// 恢复断线前的截止时间,反复重连不能拖延时间
+			t.resetTimer(d)
+		}
+	}
+	if reconnected {
+		s.handback = false
+	}
+	c.push(Message{Type: MsgWelcome, Seat: pos, Token: s.token, Snapshot: t.snapshotPtr(pos)})
+	if t.game == nil {
+		t.maybeStart()
+	}
+	t.broadcastSnapshots()
+	return s.token, nil
+}
+
+// 玩家断开连接,座位保留,可以使用 token 重连
+func (t *Table) leave(pos ai.Position, c *conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s := t.seats[pos]; s.conn == c {
+		s.conn = nil
+		if d := t.cfg.OfflineTimeout; d > 0 && t.waiting(pos) && time.Until(t.deadline) > d {
+			t.resetTimer(d)
+		}
+		t.broadcastSnapshots()
+	}
+	c.close()
+}
+
+func (t *Table) watch(c *conn) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTableClosed
+	}
+	t.watchers[c] = struct{}{}
+	c.push(Message{Type: MsgSnapshot, Seat: ai.BadPosition, Snapshot: t.snapshotPtr(ai.BadPosition)})
+	return nil
+}
+
+func (t *Table) unwatch(c *conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.watchers, c)
+	c.close()
+}
+
+// 处理玩家消息
+func (t *Table) handle(pos ai.Position, c *conn, msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seats[pos].conn != c {
+		return ErrSeatTaken
+	}
+	if t.game == nil {
+		return game.ErrWrongPhase
+	}
+	var a action
+	switch msg.Type {
+	case MsgRob:
+		a = action{name: MsgRob, score: msg.Score}
+	case MsgDouble:
+		a = action{name: MsgDouble, multi: msg.Multi}
+	case MsgPlay:
+		kind, err := t.parseKind(pos, msg.Pokers)
+		if err != nil {
+			return err
+		}
+		a = action{name: MsgPlay, kind: kind}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrBadMessage, msg.Type)
+	}
+	return t.apply(pos, a, false)
+}
+
+func (t *Table) parseKind(pos ai.Position, pokers []int32) (ai.Kind, error) {
+	if len(pokers) == 0 {
+		return ai.Kind{}, nil
+	}
+	pset := ai.NewPokerSetWithInt32s(pokers)
+	if !ai.FullDeck.Contains(pset) || pset.Len() != len(pokers) {
+		return ai.Kind{}, fmt.Errorf("%w: duplicated or invalid pokers", ErrBadMessage)
+	}
+	return t.game.ParsePokers(pos, pset)
+}
+
+// 玩家动作
+type action struct {
+	name  string
+	score int
+	multi int
+	kind  ai.Kind
+}
+
+// 执行动作并通知所有座位的 AI 和连接
+func (t *Table) apply(pos ai.Position, a action, auto bool) error {
+	g := t.game
+	event := &Event{Round: t.round, Seat: pos, Action: a.name, Auto: auto}
+	switch a.name {
+	case MsgRob:
+		if err := g.Rob(pos, a.score); err != nil {
+			return err
+		}
+		event.Score = a.score
+		for _, s := range t.seats {
+			s.ai.Rob(pos, a.score)
+		}
+	case MsgDouble:
+		if err := g.Double(pos, a.multi); err != nil {
+			return err
+		}
+		event.Multi = a.multi
+		for _, s := range t.seats {
+			s.ai.Double(pos, a.multi)
+		}
+	case MsgPlay:
+		if err := g.Play(pos, a.kind); err != nil {
+			return err
+		}
+		event.Pokers = a.kind.Pokers().ToInt32s(nil)
+		tag := pos.Role(g.Landlord())
+		for _, s := range t.seats {
+			s.ai.Play(tag, pos, a.kind)
+		}
+	}
+	t.broadcast(Message{Type: MsgEvent, Seat: pos, Event: event})
+
+	switch g.Phase() {
+	case game.PhaseDouble:
+		if a.name == MsgRob {
+			for _, s := range t.seats {
+				s.ai.SetLandlord(g.Landlord())
+				s.ai.SetLastPokers(g.LastPokers())
+			}
+		}
+	case game.PhasePlay:
+		if a.name == MsgDouble {
+			var hands [ai.NumPlayer]ai.PokerSet
+			for i := range hands {
+				hands[i] = g.StartHand(ai.Position(i))
+			}
+			for _, s := range t.seats {
+				s.ai.Start(hands)
+			}
+		}
+	case game.PhaseOver:
+		if !g.Landlord().Valid() {
+			// 没有人叫地主,重新发牌
+			t.startRound()
+			return nil
+		}
+		result, err := g.Settle()
+		if err != nil {
+			return err
+		}
+		t.result = &result
+		for i, score := range result.Scores {
+			t.scores[i] += score
+		}
+		for _, s := range t.seats {
+			s.ai.Stop()
+		}
+	}
+	t.schedule()
+	t.broadcastSnapshots()
+	return nil
+}
+
+// 所有座位都有人时开始新的一局
+func (t *Table) maybeStart() {
+	for _, s := range t.seats {
+		if !s.taken() {
+			return
+		}
+	}
+	t.startRound()
+}
+
+func (t *Table) startRound() {
+	t.round++
+	t.result = nil
+	t.game = game.New(t.opt, t.rand)
+	for i, s := range t.seats {
+		s.ai = t.cfg.NewAI(t.opt)
+		s.ai.SetSelf(ai.Position(i))
+		s.handback = false
+		if h, ok := s.ai.(ai.HandSetter); ok {
+			h.SetHand(t.game.Hand(ai.Position(i)))
+		}
+	}
+	t.schedule()
+	t.broadcastSnapshots()
+}
+
+func (t *Table) stopTimer() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.deadline = time.Time{}
+	t.turnDeadline = time.Time{}
+}
+
+// 等待下一个动作: 内置 AI 延迟 BotDelay 后出牌,玩家超过 TurnTimeout (断线时为 OfflineTimeout) 后由 AI 代替出牌
+func (t *Table) schedule() {
+	t.stopTimer()
+	if t.closed {
+		return
+	}
+	t.seq++
+	seq := t.seq
+	if t.game.Phase() == game.PhaseOver {
+		if t.cfg.RoundDelay >= 0 {
+			t.timer = time.AfterFunc(t.cfg.RoundDelay, func() { t.nextRound(seq) })
+		}
+		return
+	}
+	s := t.seats[t.game.Turn()]
+	if s.bot {
+		t.timer = time.AfterFunc(t.cfg.BotDelay, func() { t.auto(seq) })
+		return
+	}
+	delay := t.cfg.TurnTimeout
+	if s.offline() && t.cfg.OfflineTimeout > 0 {
+		delay = t.cfg.OfflineTimeout
+	}
+	t.resetTimer(delay)
+	t.turnDeadline = time.Now().Add(t.cfg.TurnTimeout)
+}
+
+// 是否正在等待玩家 pos 的动作
+func (t *Table) waiting(pos ai.Position) bool {
+	return !t.closed && t.game != nil && t.game.Phase() != game.PhaseOver && t.game.Turn() == pos
+}
+
+// 重新开始当前玩家动作的计时,不改变 seq
+func (t *Table) resetTimer(delay time.Duration) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	seq := t.seq
+	t.deadline = time.Now().Add(delay)
+	t.timer = time.AfterFunc(delay, func() { t.auto(seq) })
+}
+
+func (t *Table) nextRound(seq int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if seq == t.seq && !t.closed {
+		t.startRound()
+	}
+}
+
+// 由 AI 完成当前的动作. 出牌时在不持有锁时创建和搜索 AI, 搜索期间牌局有变化时丢弃结果
+func (t *Table) auto(seq int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if seq != t.seq || t.closed || t.thinking == seq {
+		return
+	}
+	pos := t.game.Turn()
+	var a action
+	if newBot := t.detach(pos); newBot != nil {
+		tag := pos.Role(t.game.Landlord())
+		t.thinking = seq
+		t.mu.Unlock()
+		kind, ok := recommendPlay(t.ctx, newBot, tag)
+		t.mu.Lock()
+		if seq != t.seq || t.closed {
+			return
+		}
+		a = action{name: MsgPlay, kind: kind}
+		if !ok {
+			a = t.fallback(pos)
+		}
+	} else {
+		a = t.recommend(pos)
+	}
+	auto := !t.seats[pos].bot
+	if auto && a.name == MsgPlay {
+		t.seats[pos].handback = true
+	}
+	if err := t.apply(pos, a, auto); err != nil {
+		// AI 的建议不合法时使用最保守的动作
+		a = t.fallback(pos)
+		if err := t.apply(pos, a, auto); err != nil {
+			// 牌局已经无法继续,只关闭这个牌桌
+			log.Error().String("table", t.id).Error("error", err).Print("fallback action failed, close table")
+			t.close()
+		}
+	}
+}
+
+// 出牌阶段创建代替玩家 pos 出牌的 AI 的函数,可以在不持有锁时调用: 内置 AI 从快照创建副本,
+// 玩家从牌局记录创建托管 AI. 不是出牌阶段或内置 AI 不支持快照时返回 nil
+func (t *Table) detach(pos ai.Position) func() ai.AI {
+	s := t.seats[pos]
+	if t.game.Phase() != game.PhasePlay {
+		return nil
+	}
+	if !s.bot {
+		var (
+			record     = t.record(pos)
+			newTrustee = t.cfg.NewTrustee
+			opt        = t.opt
+		)
+		return func() ai.AI {
+			trustee, err := newTrustee(record, opt)
+			if err != nil {
+				return nil
+			}
+			return trustee
+		}
+	}
+	snapshotter, ok := s.ai.(ai.Snapshotter)
+	if !ok {
+		return nil
+	}
+	var (
+		snapshot = snapshotter.Snapshot()
+		newAI    = t.cfg.NewAI
+		opt      = t.opt
+	)
+	return func() ai.AI {
+		bot := newAI(opt)
+		if r, ok := bot.(ai.Snapshotter); !ok || r.Restore(snapshot) != nil {
+			return nil
+		}
+		return bot
+	}
+}
+
+// 使用 newBot 创建的 AI 建议出牌, ctx 结束时尽快返回. AI 创建失败或 panic 时返回 false
+func recommendPlay(ctx context.Context, newBot func() ai.AI, tag string) (kind ai.Kind, ok bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			ok = false
+		}
+	}()
+	bot := newBot()
+	if bot == nil {
+		return ai.Kind{}, false
+	}
+	if analyzer, ok := bot.(ai.Analyzer); ok {
+		kind, _ = analyzer.AnalyzeContext(ctx, tag)
+		return kind, true
+	}
+	return bot.RecommendPlay(tag), true
+}
+
+// 持有锁时由内置 AI 建议叫地主,加倍,或者在内置 AI 不支持快照时建议出牌
+func (t *Table) recommend(pos ai.Position) (a action) {
+	defer func() {
+		if e := recover(); e != nil {
+			a = t.fallback(pos)
+		}
+	}()
+	bot := t.seats[pos].ai
+	switch t.game.Phase() {
+	case game.PhaseBid:
+		score := bot.RecommendRob()
+		if score <= t.game.Bid() || score > game.MaxBid {
+			score = 0
+		}
+		return action{name: MsgRob, score: score}
+	case game.PhaseDouble:
+		multi := 0
+		if bot.RecommendDouble() > 1 {
+			multi = game.DoubleMultiple
+		}
+		return action{name: MsgDouble, multi: multi}
+	}
+	kind, ok := recommendPlay(t.ctx, func() ai.AI { return bot }, pos.Role(t.game.Landlord()))
+	if !ok {
+		return t.fallback(pos)
+	}
+	return action{name: MsgPlay, kind: kind}
+}
+
+// 玩家 pos 视角的牌局记录
+func (t *Table) record(pos ai.Position) ai.Record {
+	g := t.game
+	return ai.Record{
+		Self:       pos,
+		Landlord:   g.Landlord(),
+		Hand:       g.StartHand(pos),
+		LastPokers: g.LastPokers(),
+		History:    append([]ai.Action(nil), g.History()...),
+	}
+}
+
+// 保守动作: 不叫,不加倍,出第一个提示
+func (t *Table) fallback(pos ai.Position) action {
+	switch t.game.Phase() {
+	case game.PhaseBid:
+		return action{name: MsgRob}
+	case game.PhaseDouble:
+		return action{name: MsgDouble}
+	}
+	lead, leader := t.game.Lead()
+	return action{name: MsgPlay, kind: ai.FirstHint(t.game.Hand(pos), lead, leader, t.game.Landlord(), pos, t.opt)}
+}
+
+func (t *Table) broadcast(msg Message) {
+	for _, s := range t.seats {
+		if s.conn != nil {
+			s.conn.push(msg)
+		}
+	}
+	for c := range t.watchers {
+		c.push(msg)
+	}
+}
+
+func (t *Table) broadcastSnapshots() {
+	for i, s := range t.seats {
+		if s.conn != nil {
+			s.conn.push(Message{Type: MsgSnapshot, Seat: ai.Position(i), Snapshot: t.snapshotPtr(ai.Position(i))})
+		}
+	}
+	if len(t.watchers) > 0 {
+		snapshot := t.snapshotPtr(ai.BadPosition)
+		for c := range t.watchers {
+			c.push(Message{Type: MsgSnapshot, Seat: ai.BadPosition, Snapshot: snapshot})
+		}
+	}
+}
+
+func (t *Table) snapshotPtr(viewer ai.Position) *Snapshot {
+	snapshot := t.snapshot(viewer)
+	return &snapshot
+}
+
+// viewer 视角的牌桌状态, viewer 为 BadPosition 表示观察者
+func (t *Table) snapshot(viewer ai.Position) Snapshot {
+	snapshot := Snapshot{
+		Table:    t.id,
+		Round:    t.round,
+		Seq:      t.seq,
+		Phase:    "waiting",
+		Turn:     ai.BadPosition,
+		Landlord: ai.BadPosition,
+		Leader:   ai.BadPosition,
+		Result:   t.result,
+		Scores:   t.scores,
+	}
+	if !t.deadline.IsZero() {
+		deadline := t.deadline
+		snapshot.Deadline = &deadline
+	}
+	for i, s := range t.seats {
+		snapshot.Seats[i] = SeatInfo{Bot: s.bot, Taken: s.taken(), Online: s.bot || s.conn != nil}
+	}
+	g := t.game
+	if g == nil {
+		return snapshot
+	}
+	snapshot.Phase = g.Phase().String()
+	snapshot.Turn = g.Turn()
+	snapshot.Landlord = g.Landlord()
+	snapshot.Bid = g.Bid()
+	snapshot.Bids = g.Bids()
+	snapshot.Doubles = g.Doubles()
+	for i := range snapshot.NumPokers {
+		snapshot.NumPokers[i] = g.NumPokers(ai.Position(i))
+	}
+	if viewer.Valid() {
+		snapshot.Hand = g.Hand(viewer).ToInt32s(nil)
+	}
+	if g.Landlord().Valid() {
+		snapshot.Last = g.LastPokers().ToInt32s(nil)
+	}
+	if lead, leader := g.Lead(); lead.Len() > 0 {
+		snapshot.Lead = lead.Pokers().ToInt32s(nil)
+		snapshot.Leader = leader
+	}
+	return snapshot
+}
+
+func newToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
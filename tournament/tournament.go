@@ -0,0 +1,260 @@
+// 对战评测: 两个 AI 在相同的牌上交换角色对局,比较双方的胜率和得分.
+//
+// 每副牌(deal)打两局: 一局由 A 做地主, B 控制两个农民; 另一局交换. 不叫地主,
+// 地主固定叫 1 分,都不加倍,所以两局的差别只来自双方出牌的水平
+package tournament
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/gopherd/landlord/ai"
+	"github.com/gopherd/landlord/game"
+)
+
+// 参赛者
+type Player struct {
+	Name string
+	// 为每局的每个座位创建一个新的 AI, r 为该 AI 使用的随机数, opt 为评测的规则
+	New func(r *rand.Rand, opt ai.Options) ai.AI
+}
+
+// 评测配置
+type Config struct {
+	// 牌的副数,每副牌打两局
+	Deals int
+	// 并行数, 0 表示 CPU 核数
+	Workers int
+	// 随机数种子,第 i 副牌使用 Seed+i, 所以结果与并行数无关
+	Seed int64
+	// 规则,零值表示 ai.DefaultOptions
+	Options ai.Options
+}
+
+// 参赛者的成绩
+type Standing struct {
+	Name string `json:"name"`
+	// 对局数和获胜局数
+	Games int `json:"games"`
+	Wins  int `json:"wins"`
+	// 做地主的局数和获胜局数
+	LandlordGames int `json:"landlord_games"`
+	LandlordWins  int `json:"landlord_wins"`
+	// 总得分
+	Score int `json:"score"`
+}
+
+// 胜率
+func (s Standing) WinRate() float64 { return ratio(s.Wins, s.Games) }
+
+// 做地主的胜率
+func (s Standing) LandlordWinRate() float64 { return ratio(s.LandlordWins, s.LandlordGames) }
+
+// 做农民的胜率
+func (s Standing) FarmerWinRate() float64 {
+	return ratio(s.Wins-s.LandlordWins, s.Games-s.LandlordGames)
+}
+
+// 平均每局得分
+func (s Standing) AvgScore() float64 { return ratio(s.Score, s.Games) }
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func (s Standing) String() string {
+	return fmt.Sprintf("%s: %d games, win %.1f%% (landlord %.1f%%, farmer %.1f%%), score %+d (%+.3f/game)",
+		s.Name, s.Games, 100*s.WinRate(), 100*s.LandlordWinRate(), 100*s.FarmerWinRate(), s.Score, s.AvgScore())
+}
+
+// 评测结果
+type Report struct {
+	Deals     int         `json:"deals"`
+	Standings [2]Standing `json:"standings"`
+}
+
+// A 相对 B 的平均每局得分
+func (r Report) Margin() float64 { return r.Standings[0].AvgScore() }
+
+func (r Report) String() string {
+	return fmt.Sprintf("%d deals\n%v\n%v", r.Deals, r.Standings[0], r.Standings[1])
+}
+
+// 一副牌两局的结果
+type dealResult struct {
+	standings [2]Standing
+	err       error
+}
+
+// 进行 A 与 B 的对战评测. ctx 取消后不再开始新的牌,返回已完成部分的结果
+func Match(ctx context.Context, cfg Config, a, b Player) (Report, error) {
+	report := Report{Standings: [2]Standing{{Name: a.Name}, {Name: b.Name}}}
+	opt := cfg.Options
+	if opt == (ai.Options{}) {
+		opt = ai.DefaultOptions
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	var (
+		jobs    = make(chan int)
+		results = make(chan dealResult, workers)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for deal := range jobs {
+				results <- playDeal(cfg.Seed+int64(deal), opt, [2]Player{a, b})
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := 0; i < cfg.Deals; i++ {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var err error
+	for result := range results {
+		if result.err != nil {
+			if err == nil {
+				err = result.err
+			}
+			continue
+		}
+		report.Deals++
+		for i := range report.Standings {
+			s, r := &report.Standings[i], result.standings[i]
+			s.Games += r.Games
+			s.Wins += r.Wins
+			s.LandlordGames += r.LandlordGames
+			s.LandlordWins += r.LandlordWins
+			s.Score += r.Score
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return report, err
+}
+
+// 用种子 seed 发一副牌,双方在规则 opt 下轮流做地主各打一局
+func playDeal(seed int64, opt ai.Options, players [2]Player) dealResult {
+	var (
+		result dealResult
+		r      = rand.New(rand.NewSource(seed))
+		deal   = game.New(opt, r)
+		first  = deal.First()
+	)
+	for landlord := range players {
+		g := game.NewWithHands(opt, deal.Deal(), deal.LastPokers(), first)
+		var bots [ai.NumPlayer]ai.AI
+		for i := range bots {
+			player := players[1-landlord]
+			if ai.Position(i) == first {
+				player = players[landlord]
+			}
+			bots[i] = player.New(rand.New(rand.NewSource(r.Int63())), opt)
+		}
+		res, err := Play(g, bots)
+		if err != nil {
+			result.err = fmt.Errorf("tournament: seed %d: %w", seed, err)
+			return result
+		}
+		for i := range players {
+			s := &result.standings[i]
+			s.Games++
+			var pos ai.Position
+			if i == landlord {
+				pos = first
+				s.LandlordGames++
+			} else {
+				pos = first.Next()
+			}
+			s.Score += res.Scores[pos]
+			if i != landlord {
+				s.Score += res.Scores[pos.Next()]
+			}
+			if res.Winner.IsFriend(first, pos) {
+				s.Wins++
+				if i == landlord {
+					s.LandlordWins++
+				}
+			}
+		}
+	}
+	return result
+}
+
+// 由 bots 完成一局游戏: 第一个叫地主的玩家叫 1 分成为地主,其他玩家不叫,都不加倍
+func Play(g *game.Game, bots [ai.NumPlayer]ai.AI) (game.Result, error) {
+	for i, bot := range bots {
+		bot.SetSelf(ai.Position(i))
+	}
+	for g.Phase() == game.PhaseBid {
+		pos, score := g.Turn(), 0
+		if pos == g.First() {
+			score = 1
+		}
+		if err := g.Rob(pos, score); err != nil {
+			return game.Result{}, err
+		}
+		for _, bot := range bots {
+			bot.Rob(pos, score)
+		}
+	}
+	landlord := g.Landlord()
+	for _, bot := range bots {
+		bot.SetLandlord(landlord)
+		bot.SetLastPokers(g.LastPokers())
+	}
+	for g.Phase() == game.PhaseDouble {
+		pos := g.Turn()
+		if err := g.Double(pos, 0); err != nil {
+			return game.Result{}, err
+		}
+		for _, bot := range bots {
+			bot.Double(pos, 0)
+		}
+	}
+	var hands [ai.NumPlayer]ai.PokerSet
+	for i := range hands {
+		hands[i] = g.StartHand(ai.Position(i))
+	}
+	for _, bot := range bots {
+		bot.Start(hands)
+	}
+	for g.Phase() == game.PhasePlay {
+		pos := g.Turn()
+		tag := pos.Role(landlord)
+		kind := bots[pos].RecommendPlay(tag)
+		if err := g.Play(pos, kind); err != nil {
+			return game.Result{}, fmt.Errorf("%v played %v: %w", pos, kind, err)
+		}
+		for _, bot := range bots {
+			bot.Play(tag, pos, kind)
+		}
+	}
+	for _, bot := range bots {
+		bot.Stop()
+	}
+	return g.Settle()
+}
@@ -0,0 +1,58 @@
+package tournament
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/gopherd/landlord/ai"
+)
+
+func newHintAI(r *rand.Rand, opt ai.Options) ai.AI { return ai.NewHintAI(opt) }
+
+func TestMatch(t *testing.T) {
+	var (
+		a   = Player{Name: "a", New: newHintAI}
+		b   = Player{Name: "b", New: newHintAI}
+		cfg = Config{Deals: 10, Workers: 3, Seed: 1}
+	)
+	report, err := Match(context.Background(), cfg, a, b)
+	if err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	if report.Deals != cfg.Deals {
+		t.Fatalf("want %d deals, got %d", cfg.Deals, report.Deals)
+	}
+	sa, sb := report.Standings[0], report.Standings[1]
+	if sa.Name != "a" || sb.Name != "b" {
+		t.Fatalf("bad names %q and %q", sa.Name, sb.Name)
+	}
+	for _, s := range report.Standings {
+		if s.Games != 2*cfg.Deals || s.LandlordGames != cfg.Deals {
+			t.Fatalf("bad standing %+v", s)
+		}
+	}
+	// 每局都是零和的,相同的 AI 交换角色后结果也相同
+	if sa.Score+sb.Score != 0 || sa.Wins+sb.Wins != 2*cfg.Deals {
+		t.Fatalf("games should be zero-sum: %+v %+v", sa, sb)
+	}
+	if sa.Score != 0 || sa.LandlordWins != sb.LandlordWins {
+		t.Fatalf("same AI should get the same result on swapped roles: %+v %+v", sa, sb)
+	}
+
+	cfg.Workers = 1
+	again, err := Match(context.Background(), cfg, a, b)
+	if err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	if !reflect.DeepEqual(report, again) {
+		t.Fatalf("result should not depend on number of workers")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Match(ctx, cfg, a, b); err != context.Canceled {
+		t.Fatalf("canceled match should return context.Canceled, got %v", err)
+	}
+}